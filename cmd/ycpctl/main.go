@@ -0,0 +1,21 @@
+// Command ycpctl is the command line client of ycp, see pkg/ctl
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/yodo-io/ycp/pkg/ctl"
+)
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		cancel()
+	}()
+	os.Exit(ctl.Main(ctx, os.Args[1:], os.Stdin, os.Stdout, os.Stderr, os.Getenv))
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/yodo-io/ycp/pkg/config"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+// Subcommands, invoked as `ycp [flags] <command> [args]`. Without a command, ycp starts the server.
+var commands = map[string]func(cfg *config.Config, args []string) error{
+	"migrate": migrate,
+}
+
+func runCommand(cfg *config.Config, name string, args []string) error {
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("Unknown command: %s", name)
+	}
+	return cmd(cfg, args)
+}
+
+// migrate up|down|status
+func migrate(cfg *config.Config, args []string) error {
+	if len(args) != 1 {
+		return errors.New("Usage: ycp migrate up|down|status")
+	}
+	db, err := openDB(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	switch args[0] {
+	case "up":
+		n, err := model.MigrateUp(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migration(s)\n", n)
+	case "down":
+		m, err := model.MigrateDown(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Reverted migration %d (%s)\n", m.Version, m.Name)
+	case "status":
+		states, err := model.MigrationStatus(db)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+		for _, s := range states {
+			applied := "pending"
+			if !s.Pending() {
+				applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("Unknown migrate command: %s", args[0])
+	}
+	return nil
+}
@@ -1,5 +1,7 @@
 module github.com/yodo-io/ycp
 
+go 1.11
+
 require (
 	github.com/davecgh/go-spew v1.1.1
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
@@ -14,9 +16,12 @@ require (
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd
 	github.com/modern-go/reflect2 v0.0.0-20180701023420-4b7aa43c6742
 	github.com/pmezard/go-difflib v1.0.0
+	github.com/prometheus/client_golang v0.9.2
 	github.com/stretchr/testify v1.2.2
 	github.com/ugorji/go v1.1.1
+	golang.org/x/crypto v0.0.0-20180904163835-0709b304e793
 	golang.org/x/sys v0.0.0-20180831094639-fa5fdf94c789
+	gopkg.in/check.v1 v0.0.0-20161208181325-20d25e280405 // indirect
 	gopkg.in/go-playground/validator.v8 v8.18.2
 	gopkg.in/yaml.v2 v2.2.1
 )
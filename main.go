@@ -1,58 +1,193 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
 	"github.com/yodo-io/ycp/pkg/api"
 	"github.com/yodo-io/ycp/pkg/api/v1"
+	"github.com/yodo-io/ycp/pkg/api/v1/audit"
 	"github.com/yodo-io/ycp/pkg/api/v1/auth"
 	"github.com/yodo-io/ycp/pkg/api/v1/rbac"
+	"github.com/yodo-io/ycp/pkg/config"
+	"github.com/yodo-io/ycp/pkg/logging"
+	"github.com/yodo-io/ycp/pkg/metrics"
 	"github.com/yodo-io/ycp/pkg/model"
+	"github.com/yodo-io/ycp/pkg/provision"
 )
 
-// TODO: flags for these
-var sampleData = true
-var dbDriver = "sqlite3"
-var dbString = ":memory:"
-var addr = ":9000"
-
 func main() {
-	db, err := setupDB()
+	cfg, err := config.Load(os.Args[0], os.Args[1:], os.Getenv)
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(cfg.Args) > 0 {
+		if err := runCommand(cfg, cfg.Args[0], cfg.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+
+	logger := setupLogger(cfg)
+	db, err := setupDB(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-	g, err := setupGin(db)
+	g, err := setupGin(cfg, db, logger)
 	if err != nil {
 		log.Fatal(err)
 	}
-	g.Run(addr)
+	startWorker(cfg, db, logger)
+	g.Run(cfg.Addr)
 }
 
-func setupDB() (*gorm.DB, error) {
-	db, err := gorm.Open(dbDriver, dbString)
+func setupDB(cfg *config.Config) (*gorm.DB, error) {
+	db, err := openDB(cfg)
 	if err != nil {
 		return nil, err
 	}
-	if err := model.Setup(db, sampleData); err != nil {
+	if err := model.Setup(db, cfg.SampleData); err != nil {
 		return nil, err
 	}
 	return db, nil
 }
 
-func setupGin(db *gorm.DB) (*gin.Engine, error) {
-	secret := []byte("secret")
+func openDB(cfg *config.Config) (*gorm.DB, error) {
+	return model.Open(cfg.DBDriver, cfg.DBString)
+}
 
-	g := gin.Default()
+// Log JSON lines to stderr, the level has been validated along with the config
+func setupLogger(cfg *config.Config) *logging.Logger {
+	level, _ := logging.ParseLevel(cfg.LogLevel)
+	logging.Default = logging.New(os.Stderr, level)
+	return logging.Default
+}
+
+func setupGin(cfg *config.Config, db *gorm.DB, logger *logging.Logger) (*gin.Engine, error) {
+	secret := []byte(cfg.Secret)
+
+	// probes are polled every few seconds, don't log them
+	g := gin.New()
+	g.Use(logging.Middleware(logger, "/healthz", "/readyz"), gin.Recovery())
+	g.Use(metrics.Middleware(g))
 	g.NoRoute(api.NotFound)
+	setupMetrics(db)
+	g.GET("/metrics", gin.WrapH(metrics.Handler()))
+	api.HealthRoutes(g, db)
 
-	g.POST("/auth/token", auth.Handler(db, secret))
+	auth.Routes(g.Group("/auth"), db, secret)
+
+	policy, err := setupPolicy(cfg, db, logger)
+	if err != nil {
+		return nil, err
+	}
+	auditLog, err := setupAudit(cfg, db)
+	if err != nil {
+		return nil, err
+	}
 
 	rg := g.Group("/v1")
-	rg.Use(auth.Middleware(secret))
-	rg.Use(rbac.Middleware())
+	rg.Use(auth.Middleware(db, secret))
+	rg.Use(audit.Middleware(auditLog)) // before RBAC, so denied requests are recorded as well
+	rg.Use(rbac.Middleware(policy))
+	rg.Use(rbac.Reloader(policy, `/v\d+/roles`))
 	v1.Routes(rg, db)
 
 	return g, nil
 }
+
+// Provision resources in the background. There are no real backends yet, so all categories are
+// provisioned by the fake provider.
+func startWorker(cfg *config.Config, db *gorm.DB, logger *logging.Logger) {
+	fake := provision.NewFake()
+	fake.Latency = cfg.FakeLatency
+	fake.FailureRate = cfg.FakeFailureRate
+	// resources created before the server started, e.g. the sample data, only exist in the database
+	if err := fake.Load(db); err != nil {
+		log.Fatalf("Failed to load resources into the fake provider: %v", err)
+	}
+	w := provision.NewWorker(db, provision.NewRegistry(fake))
+	w.Log = logger
+	go func() {
+		if err := w.Run(context.Background()); err != nil {
+			log.Fatalf("Provisioning worker failed: %v", err)
+		}
+	}()
+}
+
+// Export gauges read from the DB on every scrape, in addition to the request metrics
+func setupMetrics(db *gorm.DB) {
+	metrics.NewGaugeFunc("ycp_resources", "Resources by catalog type, not counting deleted ones", func() ([]metrics.Sample, error) {
+		counts, err := model.CountResourcesByType(db)
+		if err != nil {
+			return nil, err
+		}
+		var res []metrics.Sample
+		for tp, n := range counts {
+			res = append(res, metrics.Sample{Labels: []string{tp}, Value: float64(n)})
+		}
+		return res, nil
+	}, "type")
+	metrics.NewGaugeFunc("ycp_users", "Users by role", func() ([]metrics.Sample, error) {
+		counts, err := model.CountUsersByRole(db)
+		if err != nil {
+			return nil, err
+		}
+		var res []metrics.Sample
+		for r, n := range counts {
+			res = append(res, metrics.Sample{Labels: []string{string(r)}, Value: float64(n)})
+		}
+		return res, nil
+	}, "role")
+}
+
+// Audit records are stored in the DB, and appended to a file if configured
+func setupAudit(cfg *config.Config, db *gorm.DB) (*audit.Log, error) {
+	if cfg.AuditLog == "" {
+		return audit.NewLog(db, nil), nil
+	}
+	f, err := os.OpenFile(cfg.AuditLog, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open audit log: %v", err)
+	}
+	return audit.NewLog(db, f), nil
+}
+
+// Load RBAC policy from file or DB, reload it on SIGHUP
+func setupPolicy(cfg *config.Config, db *gorm.DB, logger *logging.Logger) (*rbac.Policy, error) {
+	src := rbac.DBSource(db)
+	if cfg.RBACPolicy != "" {
+		src = rbac.FileSource(cfg.RBACPolicy)
+	}
+	p, err := rbac.NewPolicy(src)
+	if err != nil {
+		return nil, err
+	}
+	p.Memberships = rbac.DBMemberships(db)
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := p.Reload(); err != nil {
+				logger.Error("Failed to reload RBAC policy, keeping current rules", logging.Fields{"error": err})
+				continue
+			}
+			logger.Info("Reloaded RBAC policy")
+		}
+	}()
+	return p, nil
+}
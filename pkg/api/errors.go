@@ -0,0 +1,186 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/yodo-io/ycp/pkg/logging"
+	validator "gopkg.in/go-playground/validator.v8"
+)
+
+// Code identifies the kind of an error. Codes are part of the API, clients should check them instead
+// of messages, which may change.
+type Code string
+
+// Error codes, errors without a code of their own get one matching their status
+const (
+	CodeBadRequest           Code = "BAD_REQUEST"
+	CodeValidationFailed     Code = "VALIDATION_FAILED"
+	CodeAuthenticationFailed Code = "AUTHENTICATION_FAILED"
+	CodeUnauthorized         Code = "UNAUTHORIZED"
+	CodeTokenExpired         Code = "TOKEN_EXPIRED"
+	CodeForbidden            Code = "FORBIDDEN"
+	CodeNotFound             Code = "NOT_FOUND"
+	CodeAlreadyExists        Code = "ALREADY_EXISTS"
+	CodeConflict             Code = "CONFLICT"
+	CodeInvalidState         Code = "INVALID_STATE"
+	CodeQuotaExceeded        Code = "QUOTA_EXCEEDED"
+	CodeInvalidResourceType  Code = "INVALID_RESOURCE_TYPE"
+	CodeInternal             Code = "INTERNAL"
+	CodeUnavailable          Code = "UNAVAILABLE"
+)
+
+var statusCodes = map[int]Code{
+	http.StatusBadRequest:          CodeBadRequest,
+	http.StatusUnauthorized:        CodeUnauthorized,
+	http.StatusForbidden:           CodeForbidden,
+	http.StatusNotFound:            CodeNotFound,
+	http.StatusConflict:            CodeConflict,
+	http.StatusInternalServerError: CodeInternal,
+	http.StatusServiceUnavailable:  CodeUnavailable,
+}
+
+// ErrorResponse is the body of all error responses
+type ErrorResponse struct {
+	Code Code `json:"code"`
+	// Error is a message for humans
+	Error     string       `json:"error"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"requestId,omitempty"`
+}
+
+// FieldError tells why a field of the request body is invalid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is an error with a code, for errors clients need to tell apart from others of the same status
+type Error struct {
+	Code    Code
+	Message string
+	Details []FieldError
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// NewError creates an error with the given code and message
+func NewError(code Code, msg string) *Error {
+	return &Error{Code: code, Message: msg}
+}
+
+// Errorf creates an error with the given code and a formatted message
+func Errorf(code Code, format string, args ...interface{}) *Error {
+	return NewError(code, fmt.Sprintf(format, args...))
+}
+
+// AbortWithError aborts the request, responding with the given status and the error in an
+// ErrorResponse. The error is also reported to the context, so it is logged with the request.
+func AbortWithError(c *gin.Context, status int, err error) {
+	c.Error(err)
+	c.AbortWithStatusJSON(status, NewErrorResponse(c, status, err))
+}
+
+// NewErrorResponse converts an error into the response sent to the client. Errors from binding
+// request bodies are reported as VALIDATION_FAILED with details per field. Messages of internal
+// errors are not sent, as they may contain details about the system.
+func NewErrorResponse(c *gin.Context, status int, err error) ErrorResponse {
+	res := ErrorResponse{Code: statusCode(status), Error: err.Error(), RequestID: logging.RequestID(c)}
+	switch e := err.(type) {
+	case *Error:
+		res.Code, res.Error, res.Details = e.Code, e.Message, e.Details
+	case validator.ValidationErrors:
+		res.Code, res.Details = CodeValidationFailed, validationDetails(e)
+		msgs := make([]string, len(res.Details))
+		for i, d := range res.Details {
+			msgs[i] = d.Field + " " + d.Message
+		}
+		res.Error = "Invalid request: " + strings.Join(msgs, ", ")
+	case *json.UnmarshalTypeError:
+		res.Code, res.Error = CodeValidationFailed, "Invalid request: "+e.Field+" must be "+e.Type.String()
+		res.Details = []FieldError{{Field: e.Field, Message: "must be " + e.Type.String()}}
+	case *json.SyntaxError:
+		res.Error = "Invalid JSON: " + e.Error()
+	default:
+		if err == io.EOF {
+			res.Error = "Request body is missing"
+		} else if status == http.StatusInternalServerError {
+			res.Error = http.StatusText(status)
+		}
+	}
+	return res
+}
+
+func statusCode(status int) Code {
+	if code, ok := statusCodes[status]; ok {
+		return code
+	}
+	if status >= http.StatusInternalServerError {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// Messages of validation tags, other tags are reported as failed checks
+var tagMessages = map[string]string{
+	"required": "is required",
+	"exists":   "is required",
+	"email":    "must be a valid email address",
+}
+
+// Details of failed validations, ordered by field. Fields are named like in JSON, see bindingValidator.
+func validationDetails(errs validator.ValidationErrors) []FieldError {
+	res := make([]FieldError, 0, len(errs))
+	for _, fe := range errs {
+		msg, ok := tagMessages[fe.Tag]
+		if !ok {
+			msg = fmt.Sprintf("failed the %s check", fe.Tag)
+		}
+		res = append(res, FieldError{Field: fe.Name, Message: msg})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Field < res[j].Field
+	})
+	return res
+}
+
+func init() {
+	binding.Validator = &bindingValidator{}
+}
+
+// Validates request bodies like gin's default validator, but names fields by their json tag, so
+// validation errors name fields the same way as type errors and clients do
+type bindingValidator struct {
+	once     sync.Once
+	validate *validator.Validate
+}
+
+// ValidateStruct implements binding.StructValidator
+func (v *bindingValidator) ValidateStruct(obj interface{}) error {
+	value := reflect.ValueOf(obj)
+	if value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return nil
+	}
+	return v.Engine().(*validator.Validate).Struct(obj)
+}
+
+// Engine implements binding.StructValidator
+func (v *bindingValidator) Engine() interface{} {
+	v.once.Do(func() {
+		v.validate = validator.New(&validator.Config{TagName: "binding", FieldNameTag: "json"})
+	})
+	return v.validate
+}
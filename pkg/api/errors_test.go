@@ -0,0 +1,143 @@
+package api_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/api"
+	"github.com/yodo-io/ycp/pkg/api/test"
+)
+
+type signup struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required"`
+	Age      int    `json:"age"`
+}
+
+func TestErrorResponse(t *testing.T) {
+	r := test.NewRouter()
+	r.POST("/signup", func(c *gin.Context) {
+		var s signup
+		if err := c.ShouldBindJSON(&s); err != nil {
+			api.AbortWithError(c, http.StatusBadRequest, err)
+		}
+	})
+	r.GET("/quota", func(c *gin.Context) {
+		api.AbortWithError(c, http.StatusBadRequest, api.Errorf(api.CodeQuotaExceeded, "Quota of %s exceeded", "pot.instance.small"))
+	})
+	r.GET("/conflict", func(c *gin.Context) {
+		api.AbortWithError(c, http.StatusConflict, errors.New("Project still has 2 resources"))
+	})
+	r.GET("/fatal", func(c *gin.Context) {
+		api.Fatal(c, errors.New("no such table: users"))
+	})
+	r.GET("/forbidden", api.Forbidden)
+
+	tests := []struct {
+		method  string
+		path    string
+		body    interface{}
+		code    int
+		out     api.ErrorResponse
+		details []api.FieldError
+	}{
+		{
+			method: http.MethodPost, path: "/signup", body: map[string]string{"email": "joe"},
+			code: http.StatusBadRequest,
+			out:  api.ErrorResponse{Code: api.CodeValidationFailed, Error: "Invalid request: email must be a valid email address, password is required"},
+			details: []api.FieldError{
+				{Field: "email", Message: "must be a valid email address"},
+				{Field: "password", Message: "is required"},
+			},
+		},
+		{
+			method: http.MethodPost, path: "/signup", body: map[string]interface{}{"email": "joe@example.org", "age": "old"},
+			code:    http.StatusBadRequest,
+			out:     api.ErrorResponse{Code: api.CodeValidationFailed, Error: "Invalid request: age must be int"},
+			details: []api.FieldError{{Field: "age", Message: "must be int"}},
+		},
+		{
+			method: http.MethodGet, path: "/quota",
+			code: http.StatusBadRequest,
+			out:  api.ErrorResponse{Code: api.CodeQuotaExceeded, Error: "Quota of pot.instance.small exceeded"},
+		},
+		{
+			method: http.MethodGet, path: "/conflict",
+			code: http.StatusConflict,
+			out:  api.ErrorResponse{Code: api.CodeConflict, Error: "Project still has 2 resources"},
+		},
+		{
+			// internals are not leaked
+			method: http.MethodGet, path: "/fatal",
+			code: http.StatusInternalServerError,
+			out:  api.ErrorResponse{Code: api.CodeInternal, Error: "Internal Server Error"},
+		},
+		{
+			method: http.MethodGet, path: "/forbidden",
+			code: http.StatusForbidden,
+			out:  api.ErrorResponse{Code: api.CodeForbidden, Error: "Forbidden"},
+		},
+		{
+			method: http.MethodGet, path: "/nowhere",
+			code: http.StatusNotFound,
+			out:  api.ErrorResponse{Code: api.CodeNotFound, Error: "Not Found"},
+		},
+	}
+
+	for _, tt := range tests {
+		var body []interface{}
+		if tt.body != nil {
+			body = append(body, tt.body)
+		}
+		w := test.MustRecord(t, r, tt.method, tt.path, body...)
+		if !assert.Equal(t, tt.code, w.Code, tt.path) {
+			continue
+		}
+
+		var res api.ErrorResponse
+		test.MustBind(t, w, &res)
+		assert.Equal(t, tt.out.Code, res.Code, tt.path)
+		assert.Equal(t, tt.out.Error, res.Error, tt.path)
+		assert.Equal(t, tt.details, res.Details, tt.path)
+		assert.NotEmpty(t, res.RequestID, tt.path)
+		assert.Equal(t, w.Header().Get("X-Request-ID"), res.RequestID, tt.path)
+	}
+}
+
+func TestErrorResponseMalformedBody(t *testing.T) {
+	r := test.NewRouter()
+	r.POST("/signup", func(c *gin.Context) {
+		var s signup
+		if err := c.ShouldBindJSON(&s); err != nil {
+			api.AbortWithError(c, http.StatusBadRequest, err)
+		}
+	})
+
+	tests := []struct {
+		body string
+		err  string
+	}{
+		{body: "", err: "Request body is missing"},
+		{body: `{"email": "joe@example.org"]`, err: "Invalid JSON: invalid character"},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/signup", strings.NewReader(tt.body))
+		req.Header.Add("Content-type", "application/json")
+		r.ServeHTTP(w, req)
+		if !assert.Equal(t, http.StatusBadRequest, w.Code) {
+			continue
+		}
+
+		var res api.ErrorResponse
+		test.MustBind(t, w, &res)
+		assert.Equal(t, api.CodeBadRequest, res.Code)
+		assert.True(t, strings.HasPrefix(res.Error, tt.err), res.Error)
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/yodo-io/ycp/pkg/model"
+	"github.com/yodo-io/ycp/pkg/version"
+)
+
+// HealthRoutes registers unauthenticated probes for load balancers and orchestrators:
+// /healthz for liveness, /readyz for readiness and /version for build info.
+func HealthRoutes(g gin.IRoutes, db *gorm.DB) {
+	g.GET("/healthz", Healthz)
+	g.GET("/readyz", Readyz(db))
+	g.GET("/version", Version)
+}
+
+// Healthz responds OK as long as the server is able to handle requests
+func Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz checks the server can serve traffic: the database must be reachable and its schema must be
+// up to date. Responds with 503 Service Unavailable otherwise.
+func Readyz(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := ready(db); err != nil {
+			AbortWithError(c, http.StatusServiceUnavailable, NewError(CodeUnavailable, err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+func ready(db *gorm.DB) error {
+	if err := db.DB().Ping(); err != nil {
+		return fmt.Errorf("Database unreachable: %v", err)
+	}
+	pending, err := model.PendingMigrations(db)
+	if err != nil {
+		return fmt.Errorf("Failed to check migrations: %v", err)
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%d migration(s) pending, starting with %d (%s)", len(pending), pending[0].Version, pending[0].Name)
+	}
+	return nil
+}
+
+// Version responds with the version and commit the server was built from
+func Version(c *gin.Context) {
+	c.JSON(http.StatusOK, version.Get())
+}
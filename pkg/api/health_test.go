@@ -0,0 +1,64 @@
+package api_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/api"
+	"github.com/yodo-io/ycp/pkg/api/test"
+	"github.com/yodo-io/ycp/pkg/model"
+	"github.com/yodo-io/ycp/pkg/version"
+)
+
+func TestHealthRoutes(t *testing.T) {
+	migrated := model.MustInitTestDB(false)
+	defer migrated.Close()
+	empty, err := model.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty.LogMode(false)
+	defer empty.Close()
+	closed, err := model.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed.Close()
+
+	tests := []struct {
+		db   *gorm.DB
+		path string
+		code int
+	}{
+		{db: migrated, path: "/healthz", code: http.StatusOK},
+		{db: migrated, path: "/readyz", code: http.StatusOK},
+		{db: empty, path: "/healthz", code: http.StatusOK},
+		{db: empty, path: "/readyz", code: http.StatusServiceUnavailable},
+		{db: closed, path: "/readyz", code: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		r := test.NewRouter()
+		api.HealthRoutes(r, tt.db)
+		w := test.MustRecord(t, r, http.MethodGet, tt.path)
+		assert.Equal(t, tt.code, w.Code, tt.path)
+	}
+
+	// probes only read the schema, they don't create the migrations table
+	assert.False(t, empty.HasTable("schema_migrations"))
+}
+
+func TestVersion(t *testing.T) {
+	r := test.NewRouter()
+	api.HealthRoutes(r, nil)
+	w := test.MustRecord(t, r, http.MethodGet, "/version")
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		return
+	}
+
+	var out version.Info
+	test.MustBind(t, w, &out)
+	assert.Equal(t, version.Get(), out)
+}
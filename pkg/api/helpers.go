@@ -6,22 +6,17 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-type errorResponse struct {
-	Error string `json:"error"`
-}
-
-func Error(e error) errorResponse {
-	return errorResponse{e.Error()}
-}
-
-func ErrStr(s string) errorResponse {
-	return errorResponse{s}
+// Unauthorized aborts the request with 401 Unauthorized
+func Unauthorized(c *gin.Context) {
+	AbortWithError(c, http.StatusUnauthorized, NewError(CodeUnauthorized, "Unauthorized"))
 }
 
-func Unauthorized(c *gin.Context) {
-	c.AbortWithStatusJSON(http.StatusUnauthorized, ErrStr("Unauthorized"))
+// Forbidden aborts the request with 403 Forbidden
+func Forbidden(c *gin.Context) {
+	AbortWithError(c, http.StatusForbidden, NewError(CodeForbidden, "Forbidden"))
 }
 
+// Fatal aborts the request with 500 Internal Server Error, the error is logged but not sent to the client
 func Fatal(c *gin.Context, err error) {
-	c.AbortWithStatusJSON(http.StatusInternalServerError, err)
+	AbortWithError(c, http.StatusInternalServerError, err)
 }
@@ -1,10 +1,12 @@
 package api
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 )
 
 // NotFound is the default not found handler
 func NotFound(c *gin.Context) {
-	c.JSON(404, gin.H{"error": "Not Found"})
+	AbortWithError(c, http.StatusNotFound, NewError(CodeNotFound, "Not Found"))
 }
@@ -25,6 +25,12 @@ func NewRouter() *gin.Engine {
 // If encoding the payload or sending the request fail, will call t.Fatal(), terminating the current test
 // Only a single value is considered for payload, additional values are ignored.
 func MustRecord(t *testing.T, r *gin.Engine, method string, path string, payload ...interface{}) *httptest.ResponseRecorder {
+	return MustRecordWithToken(t, r, method, path, "", payload...)
+}
+
+// MustRecordWithToken is like MustRecord, but sends the given auth token in the request header.
+// If token is empty, no header is sent.
+func MustRecordWithToken(t *testing.T, r *gin.Engine, method string, path string, token string, payload ...interface{}) *httptest.ResponseRecorder {
 	w := httptest.NewRecorder()
 	var data io.Reader
 
@@ -41,6 +47,9 @@ func MustRecord(t *testing.T, r *gin.Engine, method string, path string, payload
 		t.Fatal(err)
 	}
 	req.Header.Add("Content-type", "application/json")
+	if token != "" {
+		req.Header.Add("Token", token)
+	}
 
 	r.ServeHTTP(w, req)
 	return w
@@ -0,0 +1,53 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+type auditLog struct {
+	db *gorm.DB
+}
+
+// Newest records come first, unless sorted otherwise
+var auditList = listQuery{
+	sort: map[string]string{"id": "id", "createdAt": "created_at", "actorId": "actor_id"},
+	filter: map[string]string{
+		"actorId":    "actor_id = ?",
+		"action":     "action = ?",
+		"method":     "method = ?",
+		"targetType": "target_type = ?",
+		"targetId":   "target_id = ?",
+		"outcome":    "outcome = ?",
+		"requestId":  "request_id = ?",
+	},
+	order: "id desc",
+}
+
+// List audit records, ?since= and ?until= limit them to a time range given as RFC 3339 timestamps
+func (ac *auditLog) list(c *gin.Context) (int, interface{}) {
+	db := ac.db
+	for param, cond := range map[string]string{"since": "created_at >= ?", "until": "created_at < ?"} {
+		v := c.Query(param)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return http.StatusBadRequest, fmt.Errorf("Invalid %s %q, must be an RFC 3339 timestamp", param, v)
+		}
+		// timestamps are stored in local time
+		db = db.Where(cond, t.Local())
+	}
+
+	var res []*model.AuditRecord
+	if code, err := auditList.find(c, db, &res); err != nil {
+		return code, err
+	}
+	return http.StatusOK, res
+}
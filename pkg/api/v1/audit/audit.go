@@ -0,0 +1,248 @@
+/*
+Package audit records every mutating API request, i.e. every POST, PUT, PATCH and DELETE, in the
+audit_records table and optionally as JSON lines in a file.
+
+Middleware records who made the request, what it did and how it went. To tell what it did to which
+object, handlers report the object before they change it and the object they respond with:
+
+	// in an update handler, once the object is found
+	audit.Before(c, r)
+	// ... change and respond, usually done by a generic response helper
+	audit.Respond(c, r)
+
+The target of a record is derived from the responded object, or the object before the change if the
+request failed. Deleted objects are reported as responses, their records show all fields removed.
+*/
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/yodo-io/ycp/pkg/api/v1/auth"
+	"github.com/yodo-io/ycp/pkg/logging"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+// Keys of values kept in the gin context
+const (
+	logKey      = "audit.log"
+	beforeKey   = "audit.before"
+	responseKey = "audit.response"
+)
+
+// Fields never written to the audit log, even if hashed
+var redacted = []string{"password"}
+
+// Log stores audit records in the database and, if a sink is given, also writes them to the sink as
+// JSON lines, e.g. for shipping them somewhere else
+type Log struct {
+	db   *gorm.DB
+	sink io.Writer
+	mu   sync.Mutex
+}
+
+// NewLog creates a log writing to db and sink, sink may be nil
+func NewLog(db *gorm.DB, sink io.Writer) *Log {
+	return &Log{db: db, sink: sink}
+}
+
+// Record stores a record. The database is the source of truth, the record is only written to the
+// sink once it has been stored.
+func (l *Log) Record(ar *model.AuditRecord) error {
+	if err := l.db.Create(ar).Error; err != nil {
+		return err
+	}
+	if l.sink == nil {
+		return nil
+	}
+	b, err := json.Marshal(ar)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.sink.Write(append(b, '\n'))
+	return err
+}
+
+// Middleware records mutating requests once they have been handled. It must come after the auth
+// middleware, so it knows the actor, and should come before access control so denied requests are
+// recorded as well.
+func Middleware(l *Log) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !mutating(c.Request.Method) {
+			c.Next()
+			return
+		}
+		logging.RequestID(c)
+		c.Set(logKey, l)
+		c.Next()
+
+		ar := l.newRecord(c)
+		if err := l.Record(&ar); err != nil {
+			logging.From(c).Error("Failed to write audit record", logging.Fields{"error": err})
+		}
+	}
+}
+
+// Before reports the state of an object before the request changes it. Objects are copied, so
+// they can be changed after reporting them. Does nothing if the request isn't audited.
+func Before(c *gin.Context, v interface{}) {
+	if o, ok := c.Get(logKey); ok {
+		c.Set(beforeKey, o.(*Log).snapshot(v))
+	}
+}
+
+// Respond reports the object a handler responded with, which may be an error
+func Respond(c *gin.Context, v interface{}) {
+	c.Set(responseKey, v)
+}
+
+func mutating(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// Build the record of a handled request from what was reported to the context
+func (l *Log) newRecord(c *gin.Context) model.AuditRecord {
+	ar := model.AuditRecord{
+		Action:    action(c),
+		Method:    c.Request.Method,
+		Path:      c.Request.URL.Path,
+		SourceIP:  c.ClientIP(),
+		RequestID: logging.RequestID(c),
+		Status:    c.Writer.Status(),
+		Diff:      model.AuditDiff{},
+	}
+	if o, ok := c.Get("claims"); ok {
+		if cl, ok := o.(auth.Claims); ok {
+			ar.ActorID, ar.ActorEmail = cl.UserID, cl.Email
+		}
+	}
+
+	resp, _ := c.Get(responseKey)
+	switch {
+	case ar.Status == http.StatusUnauthorized || ar.Status == http.StatusForbidden:
+		ar.Outcome = model.AuditDenied
+	case ar.Status >= http.StatusBadRequest:
+		ar.Outcome = model.AuditFailed
+	default:
+		ar.Outcome = model.AuditSucceeded
+	}
+	if ar.Outcome != model.AuditSucceeded {
+		ar.Error = http.StatusText(ar.Status)
+		if err, ok := resp.(error); ok {
+			ar.Error = err.Error()
+		}
+	}
+
+	o, _ := c.Get(beforeKey)
+	before, _ := o.(*state)
+	var after *state
+	if ar.Outcome == model.AuditSucceeded {
+		after = l.snapshot(resp)
+	}
+	// deleted objects are responded with, but are gone afterwards
+	if ar.Method == http.MethodDelete && before == nil {
+		before, after = after, nil
+	}
+
+	target := after
+	if target == nil {
+		target = before
+	}
+	if target != nil {
+		ar.TargetType, ar.TargetID = target.table, target.key
+	}
+	if ar.Outcome == model.AuditSucceeded {
+		ar.Diff = diff(before, after)
+	}
+	return ar
+}
+
+// Action of a request, the resource action if there is one, or derived from the method
+func action(c *gin.Context) string {
+	if a := c.Param("action"); a != "" {
+		return a
+	}
+	switch c.Request.Method {
+	case http.MethodPost:
+		return "create"
+	case http.MethodDelete:
+		return "delete"
+	}
+	return "update"
+}
+
+// Copy of an object as it would be sent to clients, along with its table and primary key
+type state struct {
+	table  string
+	key    string
+	fields map[string]interface{}
+}
+
+// Take a snapshot of a model object, nil for anything else
+func (l *Log) snapshot(v interface{}) *state {
+	if v == nil {
+		return nil
+	}
+	if _, ok := v.(error); ok {
+		return nil
+	}
+	if rv := reflect.Indirect(reflect.ValueOf(v)); rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil
+	}
+	for _, f := range redacted {
+		delete(fields, f)
+	}
+
+	scope := l.db.NewScope(v)
+	var keys []string
+	for _, f := range scope.PrimaryFields() {
+		keys = append(keys, fmt.Sprint(f.Field.Interface()))
+	}
+	return &state{table: scope.TableName(), key: strings.Join(keys, "/"), fields: fields}
+}
+
+// Fields which differ between two states, either may be nil
+func diff(before, after *state) model.AuditDiff {
+	d := model.AuditDiff{}
+	var from, to map[string]interface{}
+	if before != nil {
+		from = before.fields
+	}
+	if after != nil {
+		to = after.fields
+	}
+	for k, v := range from {
+		if !reflect.DeepEqual(v, to[k]) {
+			d[k] = model.AuditChange{From: v, To: to[k]}
+		}
+	}
+	for k, v := range to {
+		if _, ok := from[k]; !ok {
+			d[k] = model.AuditChange{To: v}
+		}
+	}
+	return d
+}
@@ -0,0 +1,173 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/api/test"
+	"github.com/yodo-io/ycp/pkg/api/v1/auth"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+func mustInitMiddleware(db *gorm.DB, sink io.Writer) *gin.Engine {
+	r := test.NewRouter()
+	r.Use(func(c *gin.Context) {
+		c.Set("claims", auth.Claims{UserID: 1, Email: "joe@example.org"})
+	})
+	r.Use(Middleware(NewLog(db, sink)))
+
+	r.GET("/resources/:id", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	r.POST("/resources", func(c *gin.Context) {
+		res := model.Resource{ID: 5, Name: "pot", Type: "pot.instance.small"}
+		Respond(c, res)
+		c.JSON(http.StatusCreated, res)
+	})
+	r.PATCH("/resources/:id", func(c *gin.Context) {
+		res := model.Resource{ID: 1, Name: "pot", Type: "pot.instance.small"}
+		Before(c, &res)
+		res.Name = "pan"
+		Respond(c, res)
+		c.JSON(http.StatusOK, res)
+	})
+	r.POST("/resources/:id/actions/:action", func(c *gin.Context) {
+		err := errors.New("Resource is stopped")
+		Respond(c, err)
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	})
+	r.DELETE("/resources/:id", func(c *gin.Context) {
+		c.AbortWithStatus(http.StatusForbidden)
+	})
+	r.PUT("/members/:pid/:uid", func(c *gin.Context) {
+		m := model.ProjectMember{ProjectID: 3, UserID: 1, Role: model.MemberOwner}
+		Respond(c, m)
+		c.JSON(http.StatusOK, m)
+	})
+	r.DELETE("/users/:id", func(c *gin.Context) {
+		u := model.User{ID: 2, Email: "admin@example.org", Password: "hash"}
+		Respond(c, u)
+		c.JSON(http.StatusOK, gin.H{})
+	})
+	return r
+}
+
+func TestMiddleware(t *testing.T) {
+	db := model.MustInitTestDB(false)
+	defer db.Close()
+	var sink bytes.Buffer
+	r := mustInitMiddleware(db, &sink)
+
+	tests := []struct {
+		method string
+		path   string
+		out    model.AuditRecord
+	}{
+		{
+			method: http.MethodPost, path: "/resources",
+			out: model.AuditRecord{Action: "create", TargetType: "resources", TargetID: "5", Status: http.StatusCreated, Outcome: model.AuditSucceeded,
+				Diff: model.AuditDiff{"Name": {To: "pot"}}},
+		},
+		{
+			method: http.MethodPatch, path: "/resources/1",
+			out: model.AuditRecord{Action: "update", TargetType: "resources", TargetID: "1", Status: http.StatusOK, Outcome: model.AuditSucceeded,
+				Diff: model.AuditDiff{"Name": {From: "pot", To: "pan"}}},
+		},
+		{
+			method: http.MethodPost, path: "/resources/1/actions/start",
+			out: model.AuditRecord{Action: "start", Status: http.StatusConflict, Outcome: model.AuditFailed, Error: "Resource is stopped",
+				Diff: model.AuditDiff{}},
+		},
+		{
+			method: http.MethodDelete, path: "/resources/1",
+			out: model.AuditRecord{Action: "delete", Status: http.StatusForbidden, Outcome: model.AuditDenied, Error: "Forbidden",
+				Diff: model.AuditDiff{}},
+		},
+		{
+			method: http.MethodPut, path: "/members/3/1",
+			out: model.AuditRecord{Action: "update", TargetType: "project_members", TargetID: "3/1", Status: http.StatusOK, Outcome: model.AuditSucceeded,
+				Diff: model.AuditDiff{"role": {To: "owner"}}},
+		},
+		{
+			method: http.MethodDelete, path: "/users/2",
+			out: model.AuditRecord{Action: "delete", TargetType: "users", TargetID: "2", Status: http.StatusOK, Outcome: model.AuditSucceeded,
+				Diff: model.AuditDiff{"email": {From: "admin@example.org"}}},
+		},
+	}
+
+	for _, tt := range tests {
+		w := test.MustRecord(t, r, tt.method, tt.path)
+		assert.NotEmpty(t, w.Header().Get("X-Request-ID"))
+
+		var ar model.AuditRecord
+		if err := db.Last(&ar).Error; err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, uint(1), ar.ActorID)
+		assert.Equal(t, "joe@example.org", ar.ActorEmail)
+		assert.Equal(t, tt.method, ar.Method)
+		assert.Equal(t, tt.path, ar.Path)
+		assert.Equal(t, w.Header().Get("X-Request-ID"), ar.RequestID)
+		assert.Equal(t, tt.out.Action, ar.Action, tt.path)
+		assert.Equal(t, tt.out.TargetType, ar.TargetType, tt.path)
+		assert.Equal(t, tt.out.TargetID, ar.TargetID, tt.path)
+		assert.Equal(t, tt.out.Status, ar.Status, tt.path)
+		assert.Equal(t, tt.out.Outcome, ar.Outcome, tt.path)
+		assert.Equal(t, tt.out.Error, ar.Error, tt.path)
+		for k, v := range tt.out.Diff {
+			assert.Equal(t, v, ar.Diff[k], "%s %s", tt.path, k)
+		}
+		if len(tt.out.Diff) == 0 {
+			assert.Empty(t, ar.Diff, tt.path)
+		}
+		// secrets are never recorded, not even hashed
+		_, ok := ar.Diff["password"]
+		assert.False(t, ok)
+	}
+
+	// reads aren't audited
+	w := test.MustRecord(t, r, http.MethodGet, "/resources/1")
+	assert.Empty(t, w.Header().Get("X-Request-ID"))
+	var n int
+	db.Model(&model.AuditRecord{}).Count(&n)
+	assert.Equal(t, len(tests), n)
+
+	// the sink has one JSON line per record
+	lines := 0
+	s := bufio.NewScanner(&sink)
+	for s.Scan() {
+		var ar model.AuditRecord
+		if assert.NoError(t, json.Unmarshal(s.Bytes(), &ar)) {
+			assert.Equal(t, tests[lines].path, ar.Path)
+		}
+		lines++
+	}
+	assert.Equal(t, len(tests), lines)
+}
+
+func TestRequestIDFromClient(t *testing.T) {
+	db := model.MustInitTestDB(false)
+	defer db.Close()
+	r := mustInitMiddleware(db, nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/resources", nil)
+	req.Header.Add("X-Request-ID", "abc-123")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "abc-123", w.Header().Get("X-Request-ID"))
+
+	var ar model.AuditRecord
+	if err := db.Last(&ar).Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "abc-123", ar.RequestID)
+}
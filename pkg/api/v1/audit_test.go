@@ -0,0 +1,83 @@
+package v1
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/api/test"
+	"github.com/yodo-io/ycp/pkg/api/v1/audit"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+func TestAuditLog(t *testing.T) {
+	db := model.MustInitTestDB(true)
+	defer db.Close()
+	r := test.NewRouter()
+	r.Use(audit.Middleware(audit.NewLog(db, nil)))
+	Routes(&r.RouterGroup, db)
+
+	start := time.Now().Add(-time.Second).Format(time.RFC3339)
+	reqs := []struct {
+		method string
+		path   string
+		in     interface{}
+		code   int
+	}{
+		{method: http.MethodPatch, path: "/resources/1/1", in: gin.H{"name": "soup pot"}, code: http.StatusOK},
+		{method: http.MethodPut, path: "/quotas/1/pot.instance.small", in: gin.H{"value": 20}, code: http.StatusOK},
+		{method: http.MethodDelete, path: "/projects/1", code: http.StatusConflict},
+		{method: http.MethodGet, path: "/resources/1/1", code: http.StatusOK},
+	}
+	for _, rq := range reqs {
+		w := test.MustRecord(t, r, rq.method, rq.path, rq.in)
+		if !assert.Equal(t, rq.code, w.Code, rq.path) {
+			return
+		}
+	}
+
+	tests := []struct {
+		query string
+		paths []string
+		code  int
+	}{
+		// newest first
+		{query: "", paths: []string{"/projects/1", "/quotas/1/pot.instance.small", "/resources/1/1"}, code: http.StatusOK},
+		{query: "?targetType=resources&targetId=1", paths: []string{"/resources/1/1"}, code: http.StatusOK},
+		{query: "?outcome=failed", paths: []string{"/projects/1"}, code: http.StatusOK},
+		{query: "?sort=id&limit=1", paths: []string{"/resources/1/1"}, code: http.StatusOK},
+		{query: "?since=" + url.QueryEscape(start), paths: []string{"/projects/1", "/quotas/1/pot.instance.small", "/resources/1/1"}, code: http.StatusOK},
+		{query: "?until=" + url.QueryEscape(start), paths: []string{}, code: http.StatusOK},
+		{query: "?since=yesterday", code: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := test.MustRecord(t, r, http.MethodGet, "/audit"+tt.query)
+		if !assert.Equal(t, tt.code, w.Code, tt.query) || w.Code != http.StatusOK {
+			continue
+		}
+		var res []model.AuditRecord
+		test.MustBind(t, w, &res)
+		paths := []string{}
+		for _, ar := range res {
+			paths = append(paths, ar.Path)
+		}
+		assert.Equal(t, tt.paths, paths, tt.query)
+	}
+
+	// changes are recorded field by field
+	var ar model.AuditRecord
+	if err := db.First(&ar, "target_type = ?", "quota").Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "update", ar.Action)
+	assert.Equal(t, model.AuditDiff{"Value": {From: float64(10), To: float64(20)}}, ar.Diff)
+
+	var rar model.AuditRecord
+	if err := db.First(&rar, "target_type = ?", "resources").Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, model.AuditChange{From: "pasta pot", To: "soup pot"}, rar.Diff["Name"])
+}
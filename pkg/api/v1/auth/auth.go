@@ -1,7 +1,6 @@
 package auth
 
 import (
-	"errors"
 	"net/http"
 	"time"
 
@@ -12,9 +11,13 @@ import (
 	"github.com/yodo-io/ycp/pkg/model"
 )
 
-var errAuthFailed = errors.New("Authentication failed")
+var (
+	errAuthFailed   = api.NewError(api.CodeAuthenticationFailed, "Authentication failed")
+	errTokenExpired = api.NewError(api.CodeTokenExpired, "Token expired")
+)
 
 var tokenLifetime = 15 * time.Minute
+var refreshTokenLifetime = 30 * 24 * time.Hour
 var tokenIssuer = "ycp"
 
 type tokenRequest struct {
@@ -23,7 +26,8 @@ type tokenRequest struct {
 }
 
 type tokenResponse struct {
-	Token string `json:"token" binding:"required"`
+	Token        string `json:"token" binding:"required"`
+	RefreshToken string `json:"refreshToken" binding:"required"`
 }
 
 // Auth implements authentication for the API
@@ -35,9 +39,9 @@ type Auth struct {
 // Claims contains claims attached to the auth token. They will be stored in the
 // gin.Context upon successful validation of the user provided token
 type Claims struct {
-	Role   model.Role `json:"role"`
-	UserID uint       `json:"userID"`
-	Email  string     `json:"email"`
+	Roles  []model.Role `json:"roles"`
+	UserID uint         `json:"userID"`
+	Email  string       `json:"email"`
 	jwt.StandardClaims
 }
 
@@ -46,8 +50,8 @@ func NewController(db *gorm.DB, secret []byte) *Auth {
 	return &Auth{db, secret}
 }
 
-func newResponse(tokenStr string) *tokenResponse {
-	return &tokenResponse{tokenStr}
+func newResponse(tokenStr, refreshStr string) *tokenResponse {
+	return &tokenResponse{tokenStr, refreshStr}
 }
 
 // NewRequest generate a new tokenRequest
@@ -58,28 +62,71 @@ func newRequest(email, password string) *tokenRequest {
 	}
 }
 
-func claimsFor(u *model.User) *Claims {
+func claimsFor(u *model.User, jti string) *Claims {
 	return &Claims{
-		Role:   u.Role,
+		Roles:  u.Roles,
 		Email:  u.Email,
 		UserID: u.ID,
 		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
 			ExpiresAt: time.Now().Add(tokenLifetime).Unix(),
 			Issuer:    tokenIssuer,
 		},
 	}
 }
 
-// TokenFor generates a new token for given tokenRequest
+// TokenFor generates a new access token for given credentials
 // Might be better to have this in a dedicated component (TokenProvider or sthg.) instead of making
 // the entire controller public.
 func (a *Auth) TokenFor(email, password string) (string, error) {
-	u, err := a.validateUser(email, password)
+	tr, err := a.login(email, password)
 	if err != nil {
 		return "", err
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claimsFor(u))
-	return token.SignedString(a.secret)
+	return tr.Token, nil
+}
+
+// Validate credentials and issue a new pair of access and refresh tokens
+func (a *Auth) login(email, password string) (*tokenResponse, error) {
+	u, err := a.validateUser(email, password)
+	if err != nil {
+		return nil, err
+	}
+	family, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+	return a.issueTokens(u, family)
+}
+
+// Issue a new pair of access and refresh tokens for the given user. The refresh token's hash
+// is stored along with the access token ID, so both can be revoked together.
+func (a *Auth) issueTokens(u *model.User, family string) (*tokenResponse, error) {
+	jti, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claimsFor(u, jti))
+	ts, err := token.SignedString(a.secret)
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	rt := model.RefreshToken{
+		Hash:      hashToken(rs),
+		Family:    family,
+		UserID:    u.ID,
+		AccessJTI: jti,
+		ExpiresAt: time.Now().Add(refreshTokenLifetime),
+	}
+	if err := a.db.Create(&rt).Error; err != nil {
+		return nil, err
+	}
+	return newResponse(ts, rs), nil
 }
 
 func (a *Auth) validateUser(email string, pw string) (*model.User, error) {
@@ -87,22 +134,32 @@ func (a *Auth) validateUser(email string, pw string) (*model.User, error) {
 	if err := a.db.Find(&u, "email = ?", email).Error; err != nil {
 		return nil, err
 	}
-	if len(u) == 0 || u[0].Password != pw {
+	if len(u) == 0 || !u[0].CheckPassword(pw) {
 		return nil, errAuthFailed
 	}
+	// upgrade legacy plaintext passwords now that we know the plaintext
+	if u[0].PasswordNeedsRehash() {
+		if err := u[0].SetPassword(pw); err != nil {
+			return nil, err
+		}
+		if err := a.db.Model(u[0]).Update("password", u[0].Password).Error; err != nil {
+			return nil, err
+		}
+	}
 	return u[0], nil
 }
 
 func (a *Auth) createToken(c *gin.Context) {
 	var tr tokenRequest
 	if err := c.ShouldBind(&tr); err != nil {
-		c.JSON(http.StatusBadRequest, api.Error(err))
+		api.AbortWithError(c, http.StatusBadRequest, err)
 		return
 	}
 
-	ts, err := a.TokenFor(tr.Email, tr.Password)
+	res, err := a.login(tr.Email, tr.Password)
 	if err == errAuthFailed {
-		c.JSON(http.StatusBadRequest, api.Error(err))
+		authFailures.WithLabelValues("bad_credentials").Inc()
+		api.AbortWithError(c, http.StatusBadRequest, err)
 		return
 	}
 	if err != nil {
@@ -110,5 +167,5 @@ func (a *Auth) createToken(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, newResponse(ts))
+	c.JSON(http.StatusOK, res)
 }
@@ -36,12 +36,10 @@ func TestAuth(t *testing.T) {
 	tests := []struct {
 		tr   tokenRequest
 		code int
-		role model.Role
 	}{
 		{
 			tr:   tokenRequest{Email: "joe@example.org", Password: "secret"},
 			code: http.StatusOK,
-			role: model.RoleUser,
 		},
 		{
 			tr:   tokenRequest{Email: "joe", Password: "secret"},
@@ -79,9 +77,41 @@ func TestAuth(t *testing.T) {
 			continue
 		}
 
-		assert.Equal(t, model.RoleUser, c.Role)
+		assert.Equal(t, []model.Role{model.RoleUser}, c.Roles)
 		assert.Equal(t, tt.tr.Email, c.Email)
 		assert.True(t, c.StandardClaims.ExpiresAt > time.Now().Unix())
+		assert.NotEmpty(t, c.StandardClaims.Id)
+		assert.NotEmpty(t, res.RefreshToken)
 	}
 
 }
+
+func TestAuthUpgradesPlaintextPassword(t *testing.T) {
+	db := model.MustInitTestDB(false)
+	defer db.Close()
+
+	// simulate a row created before passwords were hashed
+	u := model.User{Email: "legacy@example.org", Password: "secret", Roles: []model.Role{model.RoleUser}}
+	if err := db.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ac := NewController(db, secret)
+	if _, err := ac.TokenFor(u.Email, "guest"); err != errAuthFailed {
+		t.Errorf("Expected %v, got %v", errAuthFailed, err)
+	}
+	if _, err := ac.TokenFor(u.Email, "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	var res model.User
+	if err := db.First(&res, u.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, res.PasswordNeedsRehash())
+	assert.True(t, res.CheckPassword("secret"))
+
+	// can still log in after the upgrade
+	_, err := ac.TokenFor(u.Email, "secret")
+	assert.NoError(t, err)
+}
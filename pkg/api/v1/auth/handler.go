@@ -10,3 +10,12 @@ func Handler(db *gorm.DB, secret []byte) gin.HandlerFunc {
 	ac := NewController(db, secret)
 	return ac.createToken
 }
+
+// Routes registers all routes of the auth module with the provided `RouterGroup`:
+// POST /token to log in, POST /refresh to rotate a refresh token and POST /logout to revoke it
+func Routes(rg *gin.RouterGroup, db *gorm.DB, secret []byte) {
+	ac := NewController(db, secret)
+	rg.POST("/token", ac.createToken)
+	rg.POST("/refresh", ac.refreshToken)
+	rg.POST("/logout", ac.logout)
+}
@@ -6,15 +6,29 @@ import (
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/yodo-io/ycp/pkg/api"
+	"github.com/yodo-io/ycp/pkg/logging"
+	"github.com/yodo-io/ycp/pkg/model"
 )
 
-// Middleware returns a gin.HandlerFunc implementing auth middleware for the application
-func Middleware(secret []byte) gin.HandlerFunc {
+// Failed authentication attempts, by reason: missing, invalid, expired or revoked token, or bad
+// credentials and invalid refresh tokens on the auth routes
+var authFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "ycp_auth_failures_total",
+	Help: "Failed authentication attempts, by reason",
+}, []string{"reason"})
+
+// Middleware returns a gin.HandlerFunc implementing auth middleware for the application.
+// Tokens are rejected if their ID is on the revocation list in the given DB.
+func Middleware(db *gorm.DB, secret []byte) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token := c.GetHeader("Token")
 		if token == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, api.ErrStr("Unauthorized"))
+			authFailures.WithLabelValues("missing_token").Inc()
+			api.Unauthorized(c)
 			return
 		}
 		cl, err := parseToken(token, secret)
@@ -22,13 +36,37 @@ func Middleware(secret []byte) gin.HandlerFunc {
 			handleTokenError(c, err)
 			return
 		}
+		// tokens without ID predate revocation support and can't be revoked
+		if cl.Id == "" {
+			authFailures.WithLabelValues("revoked_token").Inc()
+			api.Unauthorized(c)
+			return
+		}
+		revoked, err := model.IsTokenRevoked(db, cl.Id)
+		if err != nil {
+			api.Fatal(c, err)
+			return
+		}
+		if revoked {
+			authFailures.WithLabelValues("revoked_token").Inc()
+			api.Unauthorized(c)
+			return
+		}
 		c.Set("claims", cl)
+		logging.With(c, logging.Fields{"userId": cl.UserID, "roles": cl.Roles})
 	}
 }
 
 func handleTokenError(c *gin.Context, err error) {
-	if _, ok := err.(*jwt.ValidationError); ok {
+	if ve, ok := err.(*jwt.ValidationError); ok {
+		if ve.Errors&jwt.ValidationErrorExpired != 0 {
+			authFailures.WithLabelValues("expired_token").Inc()
+			api.AbortWithError(c, http.StatusUnauthorized, errTokenExpired)
+			return
+		}
+		authFailures.WithLabelValues("invalid_token").Inc()
 		api.Unauthorized(c)
+		return
 	}
 	api.Fatal(c, err)
 }
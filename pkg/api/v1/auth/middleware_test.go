@@ -7,13 +7,16 @@ import (
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
 	"github.com/yodo-io/ycp/pkg/api/test"
 	"github.com/yodo-io/ycp/pkg/model"
 )
 
-func mustInitMiddleware() *gin.Engine {
+func mustInitMiddleware(db *gorm.DB) *gin.Engine {
 	r := test.NewRouter()
-	r.Use(Middleware(secret))
+	r.Use(Middleware(db, secret))
 	return r
 }
 
@@ -29,29 +32,39 @@ func TestAuthMiddleware(t *testing.T) {
 	}
 
 	tests := []struct {
-		token string
-		code  int
+		token  string
+		code   int
+		reason string
 	}{
 		{
 			token: tokenStr,
 			code:  http.StatusOK,
 		},
 		{
-			token: "",
-			code:  http.StatusUnauthorized,
+			token:  "",
+			code:   http.StatusUnauthorized,
+			reason: "missing_token",
 		},
 		{
-			token: "foobar",
-			code:  http.StatusUnauthorized,
+			token:  "foobar",
+			code:   http.StatusUnauthorized,
+			reason: "invalid_token",
 		},
 	}
 
 	for _, tt := range tests {
 		func() {
+			failures := testutil.ToFloat64(authFailures.WithLabelValues(tt.reason))
+			defer func() {
+				if tt.reason != "" {
+					assert.Equal(t, failures+1, testutil.ToFloat64(authFailures.WithLabelValues(tt.reason)), tt.reason)
+				}
+			}()
+
 			var claims interface{}
 
 			n := 0
-			r := mustInitMiddleware()
+			r := mustInitMiddleware(db)
 
 			r.GET("/private", func(c *gin.Context) {
 				n++
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yodo-io/ycp/pkg/api"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+var errInvalidRefreshToken = api.NewError(api.CodeUnauthorized, "Invalid refresh token")
+
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// Refresh rotates the given refresh token: it is marked as used and a new pair of tokens is issued
+// in the same family. Presenting a used token again means it has leaked, so the whole family is
+// revoked.
+func (a *Auth) Refresh(refreshToken string) (*tokenResponse, error) {
+	rt, err := a.lookupRefreshToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if rt.UsedAt != nil {
+		if err := model.RevokeTokenFamily(a.db, rt.Family); err != nil {
+			return nil, err
+		}
+		return nil, errInvalidRefreshToken
+	}
+
+	// mark as used, conditional update guards against concurrent rotation of the same token
+	res := a.db.Model(&model.RefreshToken{}).
+		Where("id = ? and used_at is null", rt.ID).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		if err := model.RevokeTokenFamily(a.db, rt.Family); err != nil {
+			return nil, err
+		}
+		return nil, errInvalidRefreshToken
+	}
+
+	var u []*model.User
+	if err := a.db.Find(&u, "id = ?", rt.UserID).Error; err != nil {
+		return nil, err
+	}
+	if len(u) == 0 {
+		return nil, errInvalidRefreshToken
+	}
+	return a.issueTokens(u[0], rt.Family)
+}
+
+// Logout revokes the given refresh token along with all tokens issued from the same login
+func (a *Auth) Logout(refreshToken string) error {
+	rt, err := a.lookupRefreshToken(refreshToken)
+	if err != nil {
+		return err
+	}
+	return model.RevokeTokenFamily(a.db, rt.Family)
+}
+
+// Find a refresh token that is neither expired nor revoked. Used tokens are returned for reuse detection.
+func (a *Auth) lookupRefreshToken(refreshToken string) (*model.RefreshToken, error) {
+	var rts []*model.RefreshToken
+	if err := a.db.Find(&rts, "hash = ?", hashToken(refreshToken)).Error; err != nil {
+		return nil, err
+	}
+	if len(rts) == 0 {
+		return nil, errInvalidRefreshToken
+	}
+	rt := rts[0]
+	if rt.RevokedAt != nil || time.Now().After(rt.ExpiresAt) {
+		return nil, errInvalidRefreshToken
+	}
+	return rt, nil
+}
+
+func (a *Auth) refreshToken(c *gin.Context) {
+	var rr refreshRequest
+	if err := c.ShouldBind(&rr); err != nil {
+		api.AbortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	res, err := a.Refresh(rr.RefreshToken)
+	if err == errInvalidRefreshToken {
+		authFailures.WithLabelValues("invalid_refresh_token").Inc()
+		api.AbortWithError(c, http.StatusUnauthorized, err)
+		return
+	}
+	if err != nil {
+		api.Fatal(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, res)
+}
+
+func (a *Auth) logout(c *gin.Context) {
+	var rr refreshRequest
+	if err := c.ShouldBind(&rr); err != nil {
+		api.AbortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	err := a.Logout(rr.RefreshToken)
+	if err == errInvalidRefreshToken {
+		authFailures.WithLabelValues("invalid_refresh_token").Inc()
+		api.AbortWithError(c, http.StatusUnauthorized, err)
+		return
+	}
+	if err != nil {
+		api.Fatal(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// Generate a random URL-safe string from n random bytes
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Refresh tokens are stored as hashes, they carry enough entropy to not need a salt
+func hashToken(t string) string {
+	h := sha256.Sum256([]byte(t))
+	return hex.EncodeToString(h[:])
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/api/test"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+func mustInitAuthRouter() (*gin.Engine, *gorm.DB) {
+	db := model.MustInitTestDB(true)
+	r := test.NewRouter()
+	Routes(r.Group("/auth"), db, secret)
+	rg := r.Group("/v1")
+	rg.Use(Middleware(db, secret))
+	rg.GET("/private", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "hello"})
+	})
+	return r, db
+}
+
+func mustLogin(t *testing.T, r *gin.Engine) tokenResponse {
+	w := test.MustRecord(t, r, http.MethodPost, "/auth/token", newRequest("joe@example.org", "secret"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Login failed with %d", w.Code)
+	}
+	var res tokenResponse
+	test.MustBind(t, w, &res)
+	return res
+}
+
+// Perform GET /v1/private with given access token and return the status code
+func private(t *testing.T, r *gin.Engine, token string) int {
+	w := test.MustRecordWithToken(t, r, http.MethodGet, "/v1/private", token)
+	return w.Code
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	r, db := mustInitAuthRouter()
+	defer db.Close()
+
+	tr := mustLogin(t, r)
+
+	w := test.MustRecord(t, r, http.MethodPost, "/auth/refresh", refreshRequest{tr.RefreshToken})
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		return
+	}
+	var res tokenResponse
+	test.MustBind(t, w, &res)
+
+	assert.NotEmpty(t, res.Token)
+	assert.NotEqual(t, tr.RefreshToken, res.RefreshToken)
+	assert.Equal(t, http.StatusOK, private(t, r, res.Token))
+
+	// new refresh token can be rotated again
+	w = test.MustRecord(t, r, http.MethodPost, "/auth/refresh", refreshRequest{res.RefreshToken})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	r, db := mustInitAuthRouter()
+	defer db.Close()
+
+	tr := mustLogin(t, r)
+	other := mustLogin(t, r)
+
+	w := test.MustRecord(t, r, http.MethodPost, "/auth/refresh", refreshRequest{tr.RefreshToken})
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		return
+	}
+	var rotated tokenResponse
+	test.MustBind(t, w, &rotated)
+
+	// reusing the old token must fail and revoke everything issued from this login
+	w = test.MustRecord(t, r, http.MethodPost, "/auth/refresh", refreshRequest{tr.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = test.MustRecord(t, r, http.MethodPost, "/auth/refresh", refreshRequest{rotated.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, http.StatusUnauthorized, private(t, r, tr.Token))
+	assert.Equal(t, http.StatusUnauthorized, private(t, r, rotated.Token))
+
+	// other logins are not affected
+	assert.Equal(t, http.StatusOK, private(t, r, other.Token))
+}
+
+func TestRefreshValidation(t *testing.T) {
+	r, db := mustInitAuthRouter()
+	defer db.Close()
+
+	w := test.MustRecord(t, r, http.MethodPost, "/auth/refresh", refreshRequest{})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = test.MustRecord(t, r, http.MethodPost, "/auth/refresh", refreshRequest{"foobar"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestLogout(t *testing.T) {
+	r, db := mustInitAuthRouter()
+	defer db.Close()
+
+	tr := mustLogin(t, r)
+	assert.Equal(t, http.StatusOK, private(t, r, tr.Token))
+
+	w := test.MustRecord(t, r, http.MethodPost, "/auth/logout", refreshRequest{tr.RefreshToken})
+	if !assert.Equal(t, http.StatusNoContent, w.Code) {
+		return
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, private(t, r, tr.Token))
+	w = test.MustRecord(t, r, http.MethodPost, "/auth/refresh", refreshRequest{tr.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = test.MustRecord(t, r, http.MethodPost, "/auth/logout", refreshRequest{tr.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestRevokedUserTokens(t *testing.T) {
+	r, db := mustInitAuthRouter()
+	defer db.Close()
+
+	tr := mustLogin(t, r)
+	if err := model.RevokeUserTokens(db, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, private(t, r, tr.Token))
+	w := test.MustRecord(t, r, http.MethodPost, "/auth/refresh", refreshRequest{tr.RefreshToken})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -1,25 +1,204 @@
 package v1
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
+	"github.com/yodo-io/ycp/pkg/api"
+	"github.com/yodo-io/ycp/pkg/api/v1/audit"
 	"github.com/yodo-io/ycp/pkg/model"
 )
 
+var errCatalogItemNotFound = errors.New("Catalog item not found")
+
 type catalog struct {
 	db *gorm.DB
 }
 
+var catalogList = listQuery{
+	sort: map[string]string{
+		"name":        "name",
+		"category":    "category",
+		"hourlyPrice": "hourly_price",
+		"status":      "status",
+	},
+	filter: map[string]string{"category": "category = ?", "status": "status = ?"},
+	order:  "name",
+}
+
 func (cc *catalog) list(c *gin.Context) (int, interface{}) {
 	var entries []*model.Catalog
-	if err := cc.db.Find(&entries).Error; err != nil {
-		return http.StatusInternalServerError, err
+	if code, err := catalogList.find(c, cc.db, &entries); err != nil {
+		return code, err
 	}
 	return http.StatusOK, entries
 }
 
+// Pointers tell "not set" from "set to zero value", the name can't be changed as resources refer to it
+type catalogPatch struct {
+	DisplayName *string              `json:"displayName"`
+	Description *string              `json:"description"`
+	Category    *string              `json:"category"`
+	Specs       *model.Specs         `json:"specs"`
+	HourlyPrice *int64               `json:"hourlyPrice"`
+	Currency    *string              `json:"currency"`
+	Status      *model.CatalogStatus `json:"status"`
+	// FreeWhenStopped applies to existing stopped resources right away, so usage can end up above quota
+	FreeWhenStopped *bool `json:"freeWhenStopped"`
+}
+
+func (cc *catalog) get(c *gin.Context) (int, interface{}) {
+	cat, err := lookupCatalog(cc.db, c.Param("name"))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if cat == nil {
+		return http.StatusNotFound, errCatalogItemNotFound
+	}
+	return http.StatusOK, cat
+}
+
+func (cc *catalog) create(c *gin.Context) (int, interface{}) {
+	var cat model.Catalog
+	if err := c.ShouldBind(&cat); err != nil {
+		return http.StatusBadRequest, err
+	}
+	// binding:"required" can't be used on the model, it is embedded in resources and quotas
+	if cat.Name == "" {
+		return http.StatusBadRequest, errors.New("Name is required")
+	}
+	if cat.Status != "" && !cat.Status.Valid() {
+		return http.StatusBadRequest, fmt.Errorf("Invalid status: %s", cat.Status)
+	}
+
+	// ensure item doesn't exist yet
+	ex, err := lookupCatalog(cc.db, cat.Name)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if ex != nil {
+		return http.StatusConflict, api.NewError(api.CodeAlreadyExists, "Catalog item already exists")
+	}
+
+	if err := cc.db.Create(&cat).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusCreated, cat
+}
+
+func (cc *catalog) update(c *gin.Context) (int, interface{}) {
+	cat, err := lookupCatalog(cc.db, c.Param("name"))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if cat == nil {
+		return http.StatusNotFound, errCatalogItemNotFound
+	}
+	audit.Before(c, cat)
+
+	var cp catalogPatch
+	if err := c.ShouldBind(&cp); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if cp.Status != nil && !cp.Status.Valid() {
+		return http.StatusBadRequest, fmt.Errorf("Invalid status: %s", *cp.Status)
+	}
+
+	up := gin.H{}
+	if cp.DisplayName != nil {
+		up["display_name"] = *cp.DisplayName
+	}
+	if cp.Description != nil {
+		up["description"] = *cp.Description
+	}
+	if cp.Category != nil {
+		up["category"] = *cp.Category
+	}
+	if cp.Specs != nil {
+		up["spec_capacity"] = cp.Specs.Capacity
+		up["spec_size"] = cp.Specs.Size
+	}
+	if cp.HourlyPrice != nil {
+		up["hourly_price"] = *cp.HourlyPrice
+	}
+	if cp.Currency != nil {
+		up["currency"] = *cp.Currency
+	}
+	if cp.Status != nil {
+		up["status"] = *cp.Status
+	}
+	if cp.FreeWhenStopped != nil {
+		up["free_when_stopped"] = *cp.FreeWhenStopped
+	}
+	if len(up) > 0 {
+		if err := cc.db.Model(&model.Catalog{}).Where("name = ?", cat.Name).Updates(up).Error; err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+
+	// find updated record and return
+	if cat, err = lookupCatalog(cc.db, cat.Name); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, cat
+}
+
+func (cc *catalog) delete(c *gin.Context) (int, interface{}) {
+	cat, err := lookupCatalog(cc.db, c.Param("name"))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if cat == nil {
+		return http.StatusNotFound, errCatalogItemNotFound
+	}
+
+	// items still in use can't be deleted, but they can be retired. The item is locked while checking
+	// and deleting, so no resources or quotas start using it meanwhile.
+	err = model.Transaction(cc.db, func(tx *gorm.DB) error {
+		if err := lockCatalog(tx, cat.Name); err != nil {
+			return err
+		}
+		var nr, nq int
+		err := tx.Model(&model.Resource{}).Where("type = ? and status <> ?", cat.Name, model.ResourceDeleted).Count(&nr).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Quota{}).Where("type = ?", cat.Name).Count(&nq).Error; err != nil {
+			return err
+		}
+		if nr > 0 || nq > 0 {
+			return api.Errorf(api.CodeConflict, "Catalog item is used by %d resource(s) and %d quota(s), retire it instead", nr, nq)
+		}
+		return tx.Delete(cat, "name = ?", cat.Name).Error
+	})
+	if err == errCatalogItemNotFound {
+		return http.StatusNotFound, err
+	}
+	if e, ok := err.(*api.Error); ok {
+		return http.StatusConflict, e
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, cat
+}
+
+// Lock a catalog item until the transaction ends, so it can't be deleted while resources or quotas
+// start using it. Fails with errCatalogItemNotFound if it has been deleted already.
+func lockCatalog(tx *gorm.DB, name string) error {
+	res := tx.Exec(`UPDATE "catalogs" SET "name" = "name" WHERE "name" = ?`, name)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errCatalogItemNotFound
+	}
+	return nil
+}
+
 func lookupCatalog(db *gorm.DB, name string) (*model.Catalog, error) {
 	var c []*model.Catalog
 	if err := db.Find(&c, "name = ?", name).Error; err != nil {
@@ -30,3 +209,15 @@ func lookupCatalog(db *gorm.DB, name string) (*model.Catalog, error) {
 	}
 	return c[0], nil
 }
+
+// Check if new resources can be created from a catalog item. Retired items are refused, deprecated
+// items are fine but a warning is added to the response.
+func checkCatalogStatus(c *gin.Context, cat *model.Catalog) error {
+	switch cat.Status {
+	case model.CatalogRetired:
+		return api.Errorf(api.CodeInvalidResourceType, "Resource type %s is retired", cat.Name)
+	case model.CatalogDeprecated:
+		c.Header("Warning", fmt.Sprintf(`299 - "Resource type %s is deprecated"`, cat.Name))
+	}
+	return nil
+}
@@ -4,6 +4,8 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
 	"github.com/yodo-io/ycp/pkg/api/test"
 	"github.com/yodo-io/ycp/pkg/model"
@@ -14,7 +16,7 @@ func TestGetCatalog(t *testing.T) {
 	defer td()
 
 	w := test.MustRecord(t, r, http.MethodGet, "/catalog")
-	if assert.Equal(t, http.StatusOK, w.Code) {
+	if !assert.Equal(t, http.StatusOK, w.Code) {
 		return
 	}
 
@@ -23,5 +25,225 @@ func TestGetCatalog(t *testing.T) {
 	assert.NotEmpty(t, res)
 	for _, c := range res {
 		assert.NotEmpty(t, c.Name)
+		assert.NotEmpty(t, c.DisplayName)
+		assert.NotEmpty(t, c.Description)
+		assert.NotEmpty(t, c.Category)
+		assert.NotZero(t, c.Specs.Capacity)
+		assert.NotZero(t, c.Specs.Size)
+		assert.NotZero(t, c.HourlyPrice)
+		assert.NotEmpty(t, c.Currency)
+		assert.Equal(t, model.CatalogAvailable, c.Status)
+	}
+}
+
+func TestGetCatalogItem(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	w := test.MustRecord(t, r, http.MethodGet, "/catalog/pan.instance.wok")
+	if assert.Equal(t, http.StatusOK, w.Code) {
+		var res model.Catalog
+		test.MustBind(t, w, &res)
+		assert.Equal(t, "pan.instance.wok", res.Name)
+		assert.Equal(t, "pan", res.Category)
 	}
+
+	w = test.MustRecord(t, r, http.MethodGet, "/catalog/pitchfork.instance.3s")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCreateCatalogItem(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	tests := []struct {
+		in   gin.H
+		code int
+	}{
+		// ok, with defaults
+		{in: gin.H{"name": "kettle.instance.s"}, code: http.StatusCreated},
+		// ok, fully specified
+		{
+			in: gin.H{
+				"name":        "kettle.instance.l",
+				"displayName": "Large kettle",
+				"description": "For tea parties",
+				"category":    "kettle",
+				"specs":       gin.H{"capacity": 2, "size": 18},
+				"hourlyPrice": 3,
+				"currency":    "USD",
+				"status":      "deprecated",
+			},
+			code: http.StatusCreated,
+		},
+		// exists already
+		{in: gin.H{"name": "pan.instance.wok"}, code: http.StatusConflict},
+		// missing name
+		{in: gin.H{"displayName": "Nameless"}, code: http.StatusBadRequest},
+		// invalid status
+		{in: gin.H{"name": "kettle.instance.m", "status": "gone"}, code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		w := test.MustRecord(t, r, http.MethodPost, "/catalog", tt.in)
+		if !assert.Equal(t, tt.code, w.Code, tt.in) {
+			continue
+		}
+		if w.Code != http.StatusCreated {
+			continue
+		}
+
+		var res model.Catalog
+		test.MustBind(t, w, &res)
+		assert.Equal(t, tt.in["name"], res.Name)
+		assert.Equal(t, "kettle", res.Category)
+		assert.NotEmpty(t, res.DisplayName)
+		assert.NotEmpty(t, res.Status)
+
+		// new items can be used right away
+		w = test.MustRecord(t, r, http.MethodPost, "/resources/2", model.Resource{Name: "a kettle", Type: res.Name})
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+}
+
+func TestUpdateCatalogItem(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	tests := []struct {
+		name string
+		in   gin.H
+		code int
+		out  func(t *testing.T, c model.Catalog)
+	}{
+		{
+			name: "pan.instance.wok",
+			in:   gin.H{"displayName": "Big wok", "hourlyPrice": 6},
+			code: http.StatusOK,
+			out: func(t *testing.T, c model.Catalog) {
+				assert.Equal(t, "Big wok", c.DisplayName)
+				assert.Equal(t, int64(6), c.HourlyPrice)
+				// unchanged
+				assert.Equal(t, "For stir fries at high heat", c.Description)
+				assert.Equal(t, 36, c.Specs.Size)
+			},
+		},
+		{
+			name: "pan.instance.wok",
+			in:   gin.H{"status": "retired", "specs": gin.H{"capacity": 5, "size": 40}},
+			code: http.StatusOK,
+			out: func(t *testing.T, c model.Catalog) {
+				assert.Equal(t, model.CatalogRetired, c.Status)
+				assert.Equal(t, model.Specs{Capacity: 5, Size: 40}, c.Specs)
+			},
+		},
+		{
+			name: "pan.instance.s",
+			in:   gin.H{"freeWhenStopped": true},
+			code: http.StatusOK,
+			out: func(t *testing.T, c model.Catalog) {
+				assert.True(t, c.FreeWhenStopped)
+			},
+		},
+		{name: "pan.instance.wok", in: gin.H{"status": "gone"}, code: http.StatusBadRequest},
+		{name: "pitchfork.instance.3s", in: gin.H{"displayName": "Pitchfork"}, code: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		w := test.MustRecord(t, r, http.MethodPatch, "/catalog/"+tt.name, tt.in)
+		if !assert.Equal(t, tt.code, w.Code, tt.in) {
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+
+		var res model.Catalog
+		test.MustBind(t, w, &res)
+		assert.Equal(t, tt.name, res.Name)
+		tt.out(t, res)
+	}
+}
+
+// Items can be changed with the fields they are read with
+func TestCatalogRoundTrip(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	var item map[string]interface{}
+	w := test.MustRecord(t, r, http.MethodGet, "/catalog/pan.instance.wok")
+	test.MustBind(t, w, &item)
+	for _, k := range []string{"displayName", "description", "category", "specs", "hourlyPrice", "currency", "status", "freeWhenStopped"} {
+		assert.Contains(t, item, k)
+	}
+
+	item["displayName"] = "Big wok"
+	item["hourlyPrice"] = 6
+	item["specs"] = map[string]interface{}{"capacity": 5, "size": 40}
+	w = test.MustRecord(t, r, http.MethodPatch, "/catalog/pan.instance.wok", item)
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		return
+	}
+
+	var res model.Catalog
+	w = test.MustRecord(t, r, http.MethodGet, "/catalog/pan.instance.wok")
+	test.MustBind(t, w, &res)
+	assert.Equal(t, "Big wok", res.DisplayName)
+	assert.Equal(t, int64(6), res.HourlyPrice)
+	assert.Equal(t, model.Specs{Capacity: 5, Size: 40}, res.Specs)
+}
+
+func TestDeleteCatalogItem(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	tests := []struct {
+		name string
+		code int
+	}{
+		// not in use
+		{name: "pan.instance.xl", code: http.StatusOK},
+		// used by resources
+		{name: "pan.instance.wok", code: http.StatusConflict},
+		// used by quotas
+		{name: "pot.instance.small", code: http.StatusConflict},
+		{name: "pitchfork.instance.3s", code: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		w := test.MustRecord(t, r, http.MethodDelete, "/catalog/"+tt.name)
+		if !assert.Equal(t, tt.code, w.Code, tt.name) {
+			continue
+		}
+
+		// test if item was really deleted, or kept
+		w = test.MustRecord(t, r, http.MethodGet, "/catalog/"+tt.name)
+		if tt.code == http.StatusConflict {
+			assert.Equal(t, http.StatusOK, w.Code)
+		} else {
+			assert.Equal(t, http.StatusNotFound, w.Code)
+		}
+	}
+}
+
+// Catalog items are locked while resources or quotas start using them or they are deleted, locking
+// deleted ones fails
+func TestLockCatalog(t *testing.T) {
+	_, db, td := mustInitRouterDB(false)
+	defer td()
+	if err := db.Create(&model.Catalog{Name: "pot.instance.small"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	err := model.Transaction(db, func(tx *gorm.DB) error {
+		if err := lockCatalog(tx, "pot.instance.small"); err != nil {
+			return err
+		}
+		return tx.Delete(&model.Catalog{}, "name = ?", "pot.instance.small").Error
+	})
+	assert.NoError(t, err)
+	err = model.Transaction(db, func(tx *gorm.DB) error {
+		return lockCatalog(tx, "pot.instance.small")
+	})
+	assert.Equal(t, errCatalogItemNotFound, err)
 }
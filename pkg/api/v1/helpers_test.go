@@ -1,20 +1,52 @@
 package v1
 
 import (
+	"testing"
+
 	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
 	"github.com/yodo-io/ycp/pkg/api/test"
+	"github.com/yodo-io/ycp/pkg/api/v1/auth"
 	"github.com/yodo-io/ycp/pkg/model"
+	"github.com/yodo-io/ycp/pkg/provision"
 )
 
 // Init router for HTTP tests. Calls v1.Setup() to make sure all routes are registered
 // In case of any errors this will panic, so it's not intended for use outside of test code
 // Clients must defer the returned teardown function to execute any shutdown functionality
 func mustInitRouter(sampleData bool) (*gin.Engine, func()) {
+	r, _, teardown := mustInitRouterDB(sampleData)
+	return r, teardown
+}
+
+// Like mustInitRouter, but also returns the DB for tests that need to prepare data not exposed by the API
+func mustInitRouterDB(sampleData bool) (*gin.Engine, *gorm.DB, func()) {
 	db := model.MustInitTestDB(sampleData)
 	teardown := func() {
 		db.Close()
 	}
 	r := test.NewRouter()
 	Routes(&r.RouterGroup, db)
+	return r, db, teardown
+}
+
+// Like mustInitRouter, but requests are made as the sample admin, for routes checking claims themselves
+func mustInitAdminRouter(sampleData bool) (*gin.Engine, func()) {
+	db := model.MustInitTestDB(sampleData)
+	teardown := func() {
+		db.Close()
+	}
+	r := test.NewRouter()
+	r.Use(func(c *gin.Context) {
+		c.Set("claims", auth.Claims{UserID: 2, Roles: []model.Role{model.RoleAdmin}})
+	})
+	Routes(&r.RouterGroup, db)
 	return r, teardown
 }
+
+// Run all pending operations, as the provisioning worker would in the background
+func mustProvision(t *testing.T, db *gorm.DB) {
+	if _, err := provision.NewWorker(db, provision.Nop()).ProcessPending(); err != nil {
+		t.Fatal(err)
+	}
+}
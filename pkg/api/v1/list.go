@@ -0,0 +1,125 @@
+package v1
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// Page size used if none is given, and the largest page size allowed
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// listQuery describes how a list endpoint can be paged, sorted and filtered. All list endpoints
+// follow the same convention:
+//
+//	?limit=50             return at most 50 items, see defaultLimit and maxLimit
+//	?cursor=...           continue where a previous page ended, see Link header below
+//	?sort=-name,id        sort by name descending, then id ascending
+//	?type=pan.instance.s  only return items matching a filter, filters are specific to the endpoint
+//
+// The total number of matching items is returned in the X-Total-Count header. If there are more
+// items, the Link header holds the URL of the next page, e.g. </v1/users?cursor=MTAw>; rel="next"
+type listQuery struct {
+	// columns that can be sorted by, keyed by query name
+	sort map[string]string
+	// conditions with a single placeholder for the value, keyed by query name
+	filter map[string]string
+	// order applied after any requested sort order, should be unique so pages are stable
+	order string
+}
+
+// Find a page of items matching the request's query into out, which must be a pointer to a slice.
+// Returns http.StatusOK, or a status code and error to respond with.
+func (lq *listQuery) find(c *gin.Context, db *gorm.DB, out interface{}) (int, error) {
+	limit, offset, err := parsePage(c)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	for name, cond := range lq.filter {
+		if v, ok := c.GetQuery(name); ok {
+			db = db.Where(cond, v)
+		}
+	}
+
+	var total int
+	if err := db.Model(out).Count(&total).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	if s := c.Query("sort"); s != "" {
+		for _, f := range strings.Split(s, ",") {
+			dir := "asc"
+			if strings.HasPrefix(f, "-") {
+				f, dir = f[1:], "desc"
+			}
+			col, ok := lq.sort[f]
+			if !ok {
+				return http.StatusBadRequest, fmt.Errorf("Invalid sort field: %s", f)
+			}
+			db = db.Order(col + " " + dir)
+		}
+	}
+	if err := db.Order(lq.order).Limit(limit).Offset(offset).Find(out).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	c.Header("X-Total-Count", strconv.Itoa(total))
+	if offset+limit < total {
+		u := *c.Request.URL
+		q := u.Query()
+		q.Set("cursor", encodeCursor(offset+limit))
+		u.RawQuery = q.Encode()
+		c.Header("Link", fmt.Sprintf(`<%s>; rel="next"`, u.RequestURI()))
+	}
+	return http.StatusOK, nil
+}
+
+// Read limit and offset from the request query
+func parsePage(c *gin.Context) (int, int, error) {
+	limit := defaultLimit
+	if l := c.Query("limit"); l != "" {
+		v, err := strconv.Atoi(l)
+		if err != nil || v < 1 || v > maxLimit {
+			return 0, 0, fmt.Errorf("Invalid limit %q, must be between 1 and %d", l, maxLimit)
+		}
+		limit = v
+	}
+	offset := 0
+	if cur := c.Query("cursor"); cur != "" {
+		v, err := decodeCursor(cur)
+		if err != nil {
+			return 0, 0, fmt.Errorf("Invalid cursor %q", cur)
+		}
+		offset = v
+	}
+	return limit, offset, nil
+}
+
+// Cursors are opaque to clients, so the paging implementation can change without breaking them
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodeCursor(cur string) (int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cur)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.Atoi(string(b))
+	if err != nil {
+		return 0, err
+	}
+	if v < 0 {
+		return 0, fmt.Errorf("Negative offset %d", v)
+	}
+	return v, nil
+}
@@ -0,0 +1,127 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/api/test"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+var nextLinkRegexp = regexp.MustCompile(`^<(.+)>; rel="next"$`)
+
+func TestListPaging(t *testing.T) {
+	r, db, td := mustInitRouterDB(true)
+	defer td()
+
+	// 2 sample users plus 23 more
+	for i := 0; i < 23; i++ {
+		u := model.User{Email: fmt.Sprintf("user%02d@example.org", i), Password: "secret", Roles: []model.Role{model.RoleUser}}
+		if err := db.Create(&u).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// follow next links until the last page
+	var ids []uint
+	pages := 0
+	path := "/users?limit=10"
+	for path != "" {
+		w := test.MustRecord(t, r, http.MethodGet, path)
+		if !assert.Equal(t, http.StatusOK, w.Code) {
+			return
+		}
+		assert.Equal(t, "25", w.Header().Get("X-Total-Count"))
+
+		var res []model.User
+		test.MustBind(t, w, &res)
+		for _, u := range res {
+			ids = append(ids, u.ID)
+		}
+		pages++
+
+		path = ""
+		if m := nextLinkRegexp.FindStringSubmatch(w.Header().Get("Link")); m != nil {
+			path = m[1]
+		}
+	}
+	assert.Equal(t, 3, pages)
+	assert.Len(t, ids, 25)
+	for i, id := range ids {
+		assert.Equal(t, uint(i+1), id)
+	}
+}
+
+func TestListQuery(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	tests := []struct {
+		path  string
+		code  int
+		total int
+		first string
+	}{
+		// filters
+		{path: "/users?email=admin@example.org", code: http.StatusOK, total: 1, first: "admin@example.org"},
+		{path: "/users?role=user", code: http.StatusOK, total: 1, first: "joe@example.org"},
+		{path: "/users?role=auditor", code: http.StatusOK, total: 0},
+		{path: "/resources/1?type=pot.instance.large", code: http.StatusOK, total: 1, first: "pasta pot"},
+		{path: "/quotas/1?type=pan.instance.wok", code: http.StatusOK, total: 0},
+		{path: "/catalog?category=pan", code: http.StatusOK, total: 4, first: "pan.instance.m"},
+		// sorting
+		{path: "/users?sort=-email", code: http.StatusOK, total: 2, first: "joe@example.org"},
+		{path: "/resources/1?sort=-name", code: http.StatusOK, total: 2, first: "rice pot"},
+		{path: "/catalog?sort=-hourlyPrice,name", code: http.StatusOK, total: 7, first: "pot.instance.xlarge"},
+		{path: "/catalog?category=pan&sort=-status,hourlyPrice,name&limit=1", code: http.StatusOK, total: 4, first: "pan.instance.s"},
+		// invalid parameters
+		{path: "/users?sort=password", code: http.StatusBadRequest},
+		{path: "/users?limit=0", code: http.StatusBadRequest},
+		{path: "/users?limit=5000", code: http.StatusBadRequest},
+		{path: "/users?limit=ten", code: http.StatusBadRequest},
+		{path: "/users?cursor=nope", code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		w := test.MustRecord(t, r, http.MethodGet, tt.path)
+		if !assert.Equal(t, tt.code, w.Code, tt.path) {
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		assert.Equal(t, strconv.Itoa(tt.total), w.Header().Get("X-Total-Count"), tt.path)
+
+		// items differ per endpoint, compare the first item's identifying field
+		var res []map[string]interface{}
+		test.MustBind(t, w, &res)
+		if tt.first == "" {
+			assert.Empty(t, res, tt.path)
+			continue
+		}
+		if !assert.NotEmpty(t, res, tt.path) {
+			continue
+		}
+		first := res[0]["email"]
+		if first == nil {
+			first = res[0]["Name"]
+		}
+		assert.Equal(t, tt.first, first, tt.path)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	for _, off := range []int{0, 1, 100, 12345} {
+		v, err := decodeCursor(encodeCursor(off))
+		assert.NoError(t, err)
+		assert.Equal(t, off, v)
+	}
+	for _, cur := range []string{"", "!!", encodeCursor(-1), "YWJj"} {
+		_, err := decodeCursor(cur)
+		assert.Error(t, err, cur)
+	}
+}
@@ -0,0 +1,292 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/yodo-io/ycp/pkg/api"
+	"github.com/yodo-io/ycp/pkg/api/v1/audit"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+var errOrganizationNotFound = errors.New("Organization not found")
+
+type orgs struct {
+	db *gorm.DB
+}
+
+type orgPatch struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description"`
+}
+
+var orgList = listQuery{
+	sort:   map[string]string{"id": "id", "name": "name"},
+	filter: map[string]string{"name": "name = ?"},
+	order:  "id",
+}
+
+func (oc *orgs) list(c *gin.Context) (int, interface{}) {
+	var res []*model.Organization
+	if code, err := orgList.find(c, oc.db, &res); err != nil {
+		return code, err
+	}
+	return http.StatusOK, res
+}
+
+// Create an organization, the user creating it becomes its owner
+func (oc *orgs) create(c *gin.Context) (int, interface{}) {
+	var o model.Organization
+	if err := c.ShouldBind(&o); err != nil {
+		return http.StatusBadRequest, err
+	}
+	o.ID = 0
+
+	err := model.Transaction(oc.db, func(tx *gorm.DB) error {
+		if err := tx.Create(&o).Error; err != nil {
+			return err
+		}
+		uid := claimedUserID(c)
+		if uid == 0 {
+			return nil
+		}
+		return tx.Create(&model.OrganizationMember{OrganizationID: o.ID, UserID: uid, Role: model.MemberOwner}).Error
+	})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusCreated, o
+}
+
+func (oc *orgs) get(c *gin.Context) (int, interface{}) {
+	o, err := lookupOrganization(oc.db, c.Param("oid"))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if o == nil {
+		return http.StatusNotFound, errOrganizationNotFound
+	}
+	return http.StatusOK, o
+}
+
+func (oc *orgs) update(c *gin.Context) (int, interface{}) {
+	var op orgPatch
+	if err := c.ShouldBind(&op); err != nil {
+		return http.StatusBadRequest, err
+	}
+	o, err := lookupOrganization(oc.db, c.Param("oid"))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if o == nil {
+		return http.StatusNotFound, errOrganizationNotFound
+	}
+	audit.Before(c, o)
+
+	up := gin.H{}
+	if op.Name != "" {
+		up["name"] = op.Name
+	}
+	if op.Description != nil {
+		up["description"] = *op.Description
+	}
+	if len(up) > 0 {
+		if err := oc.db.Model(&model.Organization{}).Where("id = ?", o.ID).Updates(up).Error; err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+	if err := oc.db.First(o, o.ID).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, o
+}
+
+// Organizations can only be deleted once all their projects are, members go along with the organization
+func (oc *orgs) delete(c *gin.Context) (int, interface{}) {
+	o, err := lookupOrganization(oc.db, c.Param("oid"))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if o == nil {
+		return http.StatusNotFound, errOrganizationNotFound
+	}
+
+	// check and delete in one transaction, with the organization locked so no projects are created meanwhile
+	err = model.Transaction(oc.db, func(tx *gorm.DB) error {
+		if err := lockOrganization(tx, o.ID); err != nil {
+			return err
+		}
+		var n int
+		if err := tx.Model(&model.Project{}).Where("organization_id = ?", o.ID).Count(&n).Error; err != nil {
+			return err
+		}
+		if n > 0 {
+			return api.Errorf(api.CodeConflict, "Organization still has %d projects", n)
+		}
+		if err := tx.Delete(&model.OrganizationMember{}, "organization_id = ?", o.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(o).Error
+	})
+	if err == errOrganizationNotFound {
+		return http.StatusNotFound, err
+	}
+	if e, ok := err.(*api.Error); ok {
+		return http.StatusConflict, e
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, o
+}
+
+func (oc *orgs) listMembers(c *gin.Context) (int, interface{}) {
+	o, err := lookupOrganization(oc.db, c.Param("oid"))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if o == nil {
+		return http.StatusNotFound, errOrganizationNotFound
+	}
+
+	var ms []*model.OrganizationMember
+	if err := oc.db.Order("user_id").Find(&ms, "organization_id = ?", o.ID).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, ms
+}
+
+// Add a member or change their role, members hold their role in all of the organization's projects
+func (oc *orgs) putMember(c *gin.Context) (int, interface{}) {
+	var mp memberPatch
+	if err := c.ShouldBind(&mp); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if !mp.Role.Valid() {
+		return http.StatusBadRequest, fmt.Errorf("Invalid role: %s", mp.Role)
+	}
+	o, err := lookupOrganization(oc.db, c.Param("oid"))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if o == nil {
+		return http.StatusNotFound, errOrganizationNotFound
+	}
+	u, err := lookupUser(oc.db, c.Param("uid"))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if u == nil {
+		return http.StatusBadRequest, errors.New("Invalid user")
+	}
+
+	var ex []*model.OrganizationMember
+	if err := oc.db.Find(&ex, "organization_id = ? and user_id = ?", o.ID, u.ID).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if len(ex) > 0 {
+		audit.Before(c, ex[0])
+	}
+
+	m := model.OrganizationMember{OrganizationID: o.ID, UserID: u.ID, Role: mp.Role}
+	if err := oc.db.Save(&m).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, m
+}
+
+func (oc *orgs) deleteMember(c *gin.Context) (int, interface{}) {
+	var ms []*model.OrganizationMember
+	if err := oc.db.Find(&ms, "organization_id = ? and user_id = ?", c.Param("oid"), c.Param("uid")).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if len(ms) == 0 {
+		return http.StatusNotFound, errors.New("Member not found")
+	}
+	if err := oc.db.Delete(ms[0]).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, ms[0]
+}
+
+func (oc *orgs) listProjects(c *gin.Context) (int, interface{}) {
+	o, err := lookupOrganization(oc.db, c.Param("oid"))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if o == nil {
+		return http.StatusNotFound, errOrganizationNotFound
+	}
+
+	var ps []*model.Project
+	if code, err := projectList.find(c, oc.db.Where("organization_id = ?", o.ID), &ps); err != nil {
+		return code, err
+	}
+	return http.StatusOK, ps
+}
+
+func (oc *orgs) createProject(c *gin.Context) (int, interface{}) {
+	var p model.Project
+	if err := c.ShouldBind(&p); err != nil {
+		return http.StatusBadRequest, err
+	}
+	o, err := lookupOrganization(oc.db, c.Param("oid"))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if o == nil {
+		return http.StatusNotFound, errOrganizationNotFound
+	}
+
+	// ensure name is unique in the organization
+	ex, err := findProject(oc.db, o.ID, p.Name)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if ex != nil {
+		return http.StatusConflict, api.Errorf(api.CodeAlreadyExists, "Project %s already exists", p.Name)
+	}
+
+	// the organization is locked, so it can't be deleted before the project is created
+	p.ID, p.OrganizationID = 0, o.ID
+	err = model.Transaction(oc.db, func(tx *gorm.DB) error {
+		if err := lockOrganization(tx, o.ID); err != nil {
+			return err
+		}
+		return tx.Create(&p).Error
+	})
+	if err == errOrganizationNotFound {
+		return http.StatusNotFound, err
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusCreated, p
+}
+
+// Lock an organization until the transaction ends, so it can't be deleted while projects are added to
+// it. Fails with errOrganizationNotFound if it has been deleted already.
+func lockOrganization(tx *gorm.DB, id uint) error {
+	res := tx.Exec(`UPDATE "organizations" SET "id" = "id" WHERE "id" = ?`, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errOrganizationNotFound
+	}
+	return nil
+}
+
+func lookupOrganization(db *gorm.DB, id string) (*model.Organization, error) {
+	var res []*model.Organization
+	if err := db.Find(&res, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	if len(res) == 0 {
+		return nil, nil
+	}
+	return res[0], nil
+}
@@ -0,0 +1,191 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/api/test"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+func TestGetOrganization(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	w := test.MustRecord(t, r, http.MethodGet, "/orgs?name=Yodo%20Kitchen")
+	if assert.Equal(t, http.StatusOK, w.Code) {
+		var res []model.Organization
+		test.MustBind(t, w, &res)
+		if assert.Len(t, res, 1) {
+			assert.Equal(t, uint(3), res[0].ID)
+		}
+	}
+
+	w = test.MustRecord(t, r, http.MethodGet, "/orgs/1")
+	if assert.Equal(t, http.StatusOK, w.Code) {
+		var res model.Organization
+		test.MustBind(t, w, &res)
+		assert.Equal(t, "joe@example.org", res.Name)
+	}
+
+	w = test.MustRecord(t, r, http.MethodGet, "/orgs/30")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCreateOrganization(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	tests := []struct {
+		in   gin.H
+		code int
+	}{
+		{in: gin.H{"name": "Bakery", "description": "Bread and cake"}, code: http.StatusCreated},
+		{in: gin.H{"id": 1, "name": "Bakery"}, code: http.StatusCreated},
+		{in: gin.H{"description": "No name"}, code: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := test.MustRecord(t, r, http.MethodPost, "/orgs", tt.in)
+		if !assert.Equal(t, tt.code, w.Code) || w.Code != http.StatusCreated {
+			continue
+		}
+		var res model.Organization
+		test.MustBind(t, w, &res)
+		assert.True(t, res.ID > 3)
+		assert.Equal(t, tt.in["name"], res.Name)
+	}
+}
+
+func TestUpdateOrganization(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	w := test.MustRecord(t, r, http.MethodPatch, "/orgs/3", gin.H{"name": "Yodo Bistro"})
+	if assert.Equal(t, http.StatusOK, w.Code) {
+		var res model.Organization
+		test.MustBind(t, w, &res)
+		assert.Equal(t, "Yodo Bistro", res.Name)
+		assert.Equal(t, "The team running the canteen", res.Description)
+	}
+
+	w = test.MustRecord(t, r, http.MethodPatch, "/orgs/30", gin.H{"name": "Yodo Bistro"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDeleteOrganization(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		// still has the canteen project
+		{method: http.MethodDelete, path: "/orgs/3", code: http.StatusConflict},
+		{method: http.MethodDelete, path: "/projects/3", code: http.StatusOK},
+		{method: http.MethodDelete, path: "/orgs/3", code: http.StatusOK},
+		{method: http.MethodGet, path: "/orgs/3", code: http.StatusNotFound},
+		{method: http.MethodGet, path: "/orgs/3/members", code: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := test.MustRecord(t, r, tt.method, tt.path)
+		assert.Equal(t, tt.code, w.Code, "%s %s", tt.method, tt.path)
+	}
+}
+
+// Organizations are locked while projects are added to them or they are deleted, locking deleted ones fails
+func TestLockOrganization(t *testing.T) {
+	_, db, td := mustInitRouterDB(true)
+	defer td()
+
+	err := model.Transaction(db, func(tx *gorm.DB) error {
+		if err := lockOrganization(tx, 3); err != nil {
+			return err
+		}
+		return tx.Delete(&model.Organization{}, "id = ?", 3).Error
+	})
+	assert.NoError(t, err)
+	err = model.Transaction(db, func(tx *gorm.DB) error {
+		return lockOrganization(tx, 3)
+	})
+	assert.Equal(t, errOrganizationNotFound, err)
+}
+
+func TestOrganizationMembers(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	tests := []struct {
+		method string
+		path   string
+		in     interface{}
+		code   int
+	}{
+		{method: http.MethodPut, path: "/orgs/3/members/1", in: gin.H{"role": "viewer"}, code: http.StatusOK},
+		{method: http.MethodPut, path: "/orgs/3/members/1", in: gin.H{"role": "editor"}, code: http.StatusOK},
+		{method: http.MethodPut, path: "/orgs/3/members/1", in: gin.H{"role": "boss"}, code: http.StatusBadRequest},
+		{method: http.MethodPut, path: "/orgs/3/members/20", in: gin.H{"role": "viewer"}, code: http.StatusBadRequest},
+		{method: http.MethodPut, path: "/orgs/30/members/1", in: gin.H{"role": "viewer"}, code: http.StatusNotFound},
+		{method: http.MethodDelete, path: "/orgs/3/members/2", code: http.StatusOK},
+		{method: http.MethodDelete, path: "/orgs/3/members/2", code: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := test.MustRecord(t, r, tt.method, tt.path, tt.in)
+		assert.Equal(t, tt.code, w.Code, "%s %s", tt.method, tt.path)
+	}
+
+	w := test.MustRecord(t, r, http.MethodGet, "/orgs/3/members")
+	if assert.Equal(t, http.StatusOK, w.Code) {
+		var res []model.OrganizationMember
+		test.MustBind(t, w, &res)
+		assert.Equal(t, []model.OrganizationMember{{OrganizationID: 3, UserID: 1, Role: model.MemberEditor}}, res)
+	}
+
+	w = test.MustRecord(t, r, http.MethodGet, "/users/1/memberships")
+	if assert.Equal(t, http.StatusOK, w.Code) {
+		var res userMemberships
+		test.MustBind(t, w, &res)
+		assert.Len(t, res.Organizations, 2)
+		assert.Len(t, res.Projects, 1)
+	}
+}
+
+func TestCreateOrganizationProject(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	tests := []struct {
+		oid  uint
+		in   gin.H
+		code int
+	}{
+		{oid: 3, in: gin.H{"name": "bakery"}, code: http.StatusCreated},
+		{oid: 3, in: gin.H{"name": "canteen"}, code: http.StatusConflict},
+		// same name in another organization is fine
+		{oid: 1, in: gin.H{"name": "canteen", "organizationId": 3}, code: http.StatusCreated},
+		{oid: 3, in: gin.H{}, code: http.StatusBadRequest},
+		{oid: 30, in: gin.H{"name": "bakery"}, code: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := test.MustRecord(t, r, http.MethodPost, fmt.Sprintf("/orgs/%d/projects", tt.oid), tt.in)
+		if !assert.Equal(t, tt.code, w.Code) || w.Code != http.StatusCreated {
+			continue
+		}
+		var res model.Project
+		test.MustBind(t, w, &res)
+		assert.Equal(t, tt.oid, res.OrganizationID)
+		assert.Equal(t, tt.in["name"], res.Name)
+	}
+
+	w := test.MustRecord(t, r, http.MethodGet, "/orgs/3/projects")
+	if assert.Equal(t, http.StatusOK, w.Code) {
+		var res []model.Project
+		test.MustBind(t, w, &res)
+		assert.Len(t, res, 2)
+	}
+}
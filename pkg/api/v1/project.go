@@ -0,0 +1,294 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/yodo-io/ycp/pkg/api"
+	"github.com/yodo-io/ycp/pkg/api/v1/audit"
+	"github.com/yodo-io/ycp/pkg/api/v1/auth"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+var errProjectNotFound = errors.New("Project not found")
+
+// Finds the project a request to resources or quotas acts on, nil if it doesn't exist, along with
+// the id of the user acting, 0 if unknown
+type projectScope func(db *gorm.DB, c *gin.Context) (*model.Project, uint, error)
+
+// Scope of /projects/:pid routes, the acting user is taken from the token claims
+func projectParam(db *gorm.DB, c *gin.Context) (*model.Project, uint, error) {
+	p, err := lookupProject(db, c.Param("pid"))
+	return p, claimedUserID(c), err
+}
+
+// Scope of /resources/:uid and /quotas/:uid routes, which act on the user's personal project.
+// Users that don't exist have no project.
+func personalProject(db *gorm.DB, c *gin.Context) (*model.Project, uint, error) {
+	u, err := lookupUser(db, c.Param("uid"))
+	if err != nil || u == nil {
+		return nil, 0, err
+	}
+	p, err := lookupProject(db, u.ProjectID)
+	return p, u.ID, err
+}
+
+type projects struct {
+	db *gorm.DB
+}
+
+// only name and description can be changed, projects can't move between organizations
+type projectPatch struct {
+	Name        string  `json:"name"`
+	Description *string `json:"description"`
+}
+
+// role of a member, the user is given in the path
+type memberPatch struct {
+	Role model.MemberRole `json:"role" binding:"required"`
+}
+
+var projectList = listQuery{
+	sort:   map[string]string{"id": "id", "name": "name"},
+	filter: map[string]string{"name": "name = ?", "organizationId": "organization_id = ?"},
+	order:  "id",
+}
+
+func (pc *projects) list(c *gin.Context) (int, interface{}) {
+	var ps []*model.Project
+	if code, err := projectList.find(c, pc.db, &ps); err != nil {
+		return code, err
+	}
+	return http.StatusOK, ps
+}
+
+func (pc *projects) get(c *gin.Context) (int, interface{}) {
+	p, err := lookupProject(pc.db, c.Param("pid"))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if p == nil {
+		return http.StatusNotFound, errProjectNotFound
+	}
+	return http.StatusOK, p
+}
+
+func (pc *projects) update(c *gin.Context) (int, interface{}) {
+	var pp projectPatch
+	if err := c.ShouldBind(&pp); err != nil {
+		return http.StatusBadRequest, err
+	}
+	p, err := lookupProject(pc.db, c.Param("pid"))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if p == nil {
+		return http.StatusNotFound, errProjectNotFound
+	}
+	audit.Before(c, p)
+
+	up := gin.H{}
+	if pp.Name != "" && pp.Name != p.Name {
+		ex, err := findProject(pc.db, p.OrganizationID, pp.Name)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if ex != nil {
+			return http.StatusConflict, api.Errorf(api.CodeAlreadyExists, "Project %s already exists", pp.Name)
+		}
+		up["name"] = pp.Name
+	}
+	if pp.Description != nil {
+		up["description"] = *pp.Description
+	}
+	if len(up) > 0 {
+		if err := pc.db.Model(&model.Project{}).Where("id = ?", p.ID).Updates(up).Error; err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+	if err := pc.db.First(p, p.ID).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, p
+}
+
+// Projects can only be deleted once all their resources are, quotas and members go along with the project
+func (pc *projects) delete(c *gin.Context) (int, interface{}) {
+	p, err := lookupProject(pc.db, c.Param("pid"))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if p == nil {
+		return http.StatusNotFound, errProjectNotFound
+	}
+
+	// check and delete in one transaction, with the project locked so no resources are created meanwhile
+	err = model.Transaction(pc.db, func(tx *gorm.DB) error {
+		if err := lockProject(tx, p.ID); err != nil {
+			return err
+		}
+		var n int
+		err := tx.Model(&model.Resource{}).
+			Where("project_id = ? and status <> ?", p.ID, model.ResourceDeleted).
+			Count(&n).Error
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return api.Errorf(api.CodeConflict, "Project still has %d resources", n)
+		}
+		// personal projects can't be replaced, users would lose their /resources/:uid routes
+		var users int
+		if err := tx.Model(&model.User{}).Where("project_id = ?", p.ID).Count(&users).Error; err != nil {
+			return err
+		}
+		if users > 0 {
+			return api.NewError(api.CodeConflict, "Personal projects can't be deleted")
+		}
+
+		if err := tx.Delete(&model.Quota{}, "project_id = ?", p.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&model.ProjectMember{}, "project_id = ?", p.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(p).Error
+	})
+	if err == errProjectNotFound {
+		return http.StatusNotFound, err
+	}
+	if e, ok := err.(*api.Error); ok {
+		return http.StatusConflict, e
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, p
+}
+
+// Lock a project until the transaction ends, so it can't be deleted while resources are added to it.
+// Fails with errProjectNotFound if it has been deleted already.
+func lockProject(tx *gorm.DB, id uint) error {
+	res := tx.Exec(`UPDATE "projects" SET "id" = "id" WHERE "id" = ?`, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errProjectNotFound
+	}
+	return nil
+}
+
+func (pc *projects) listMembers(c *gin.Context) (int, interface{}) {
+	p, err := lookupProject(pc.db, c.Param("pid"))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if p == nil {
+		return http.StatusNotFound, errProjectNotFound
+	}
+
+	var ms []*model.ProjectMember
+	if err := pc.db.Order("user_id").Find(&ms, "project_id = ?", p.ID).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, ms
+}
+
+// Add a member or change their role
+func (pc *projects) putMember(c *gin.Context) (int, interface{}) {
+	var mp memberPatch
+	if err := c.ShouldBind(&mp); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if !mp.Role.Valid() {
+		return http.StatusBadRequest, fmt.Errorf("Invalid role: %s", mp.Role)
+	}
+	p, err := lookupProject(pc.db, c.Param("pid"))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if p == nil {
+		return http.StatusNotFound, errProjectNotFound
+	}
+	u, err := lookupUser(pc.db, c.Param("uid"))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if u == nil {
+		return http.StatusBadRequest, errors.New("Invalid user")
+	}
+
+	var ex []*model.ProjectMember
+	if err := pc.db.Find(&ex, "project_id = ? and user_id = ?", p.ID, u.ID).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if len(ex) > 0 {
+		audit.Before(c, ex[0])
+	}
+
+	m := model.ProjectMember{ProjectID: p.ID, UserID: u.ID, Role: mp.Role}
+	if err := pc.db.Save(&m).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, m
+}
+
+func (pc *projects) deleteMember(c *gin.Context) (int, interface{}) {
+	var ms []*model.ProjectMember
+	if err := pc.db.Find(&ms, "project_id = ? and user_id = ?", c.Param("pid"), c.Param("uid")).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if len(ms) == 0 {
+		return http.StatusNotFound, errors.New("Member not found")
+	}
+	if err := pc.db.Delete(ms[0]).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, ms[0]
+}
+
+func lookupProject(db *gorm.DB, id interface{}) (*model.Project, error) {
+	var ps []*model.Project
+	if err := db.Find(&ps, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	if len(ps) == 0 {
+		return nil, nil
+	}
+	return ps[0], nil
+}
+
+// Find a project by organization and name, returns nil if there is none
+func findProject(db *gorm.DB, orgID uint, name string) (*model.Project, error) {
+	var ps []*model.Project
+	if err := db.Find(&ps, "organization_id = ? and name = ?", orgID, name).Error; err != nil {
+		return nil, err
+	}
+	if len(ps) == 0 {
+		return nil, nil
+	}
+	return ps[0], nil
+}
+
+// ID of the user making the request according to their token, 0 if there is none, e.g. in tests
+func claimedUserID(c *gin.Context) uint {
+	cl, ok := requestClaims(c)
+	if !ok {
+		return 0
+	}
+	return cl.UserID
+}
+
+// Claims of the token the request was made with, if it passed the auth middleware
+func requestClaims(c *gin.Context) (auth.Claims, bool) {
+	o, ok := c.Get("claims")
+	if !ok {
+		return auth.Claims{}, false
+	}
+	cl, ok := o.(auth.Claims)
+	return cl, ok
+}
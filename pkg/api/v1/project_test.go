@@ -0,0 +1,206 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/api/test"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+func TestGetProject(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	w := test.MustRecord(t, r, http.MethodGet, "/projects")
+	if assert.Equal(t, http.StatusOK, w.Code) {
+		var res []model.Project
+		test.MustBind(t, w, &res)
+		assert.Len(t, res, 3) // two personal projects and the canteen
+	}
+
+	w = test.MustRecord(t, r, http.MethodGet, "/projects/3")
+	if assert.Equal(t, http.StatusOK, w.Code) {
+		var res model.Project
+		test.MustBind(t, w, &res)
+		assert.Equal(t, "canteen", res.Name)
+		assert.Equal(t, uint(3), res.OrganizationID)
+	}
+
+	w = test.MustRecord(t, r, http.MethodGet, "/projects/30")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUpdateProject(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	tests := []struct {
+		id   uint
+		in   gin.H
+		name string
+		code int
+	}{
+		{id: 3, in: gin.H{"name": "kitchen", "description": "Lunch and dinner"}, name: "kitchen", code: http.StatusOK},
+		{id: 3, in: gin.H{"description": ""}, name: "kitchen", code: http.StatusOK},
+		{id: 1, in: gin.H{"name": "default"}, name: "default", code: http.StatusOK},
+		{id: 30, in: gin.H{"name": "kitchen"}, code: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		w := test.MustRecord(t, r, http.MethodPatch, fmt.Sprintf("/projects/%d", tt.id), tt.in)
+		if !assert.Equal(t, tt.code, w.Code) || w.Code != http.StatusOK {
+			continue
+		}
+		var res model.Project
+		test.MustBind(t, w, &res)
+		assert.Equal(t, tt.id, res.ID)
+		assert.Equal(t, tt.name, res.Name)
+	}
+
+	// names are unique within an organization
+	w := test.MustRecord(t, r, http.MethodPost, "/orgs/3/projects", gin.H{"name": "bakery"})
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		return
+	}
+	w = test.MustRecord(t, r, http.MethodPatch, "/projects/3", gin.H{"name": "bakery"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestDeleteProject(t *testing.T) {
+	r, db, td := mustInitRouterDB(true)
+	defer td()
+
+	tests := []struct {
+		id   uint
+		code int
+	}{
+		// personal project with resources
+		{id: 1, code: http.StatusConflict},
+		{id: 3, code: http.StatusOK},
+		{id: 3, code: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := test.MustRecord(t, r, http.MethodDelete, fmt.Sprintf("/projects/%d", tt.id))
+		assert.Equal(t, tt.code, w.Code)
+	}
+
+	var n int
+	db.Model(&model.ProjectMember{}).Where("project_id = ?", 3).Count(&n)
+	assert.Zero(t, n)
+
+	// personal projects can't be deleted even without resources
+	w := test.MustRecord(t, r, http.MethodPost, "/users", gin.H{"email": "jane@example.org", "password": "pass"})
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		return
+	}
+	var u model.User
+	test.MustBind(t, w, &u)
+	w = test.MustRecord(t, r, http.MethodDelete, fmt.Sprintf("/projects/%d", u.ProjectID))
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+// Projects are locked while resources are added to them or they are deleted, locking deleted ones fails
+func TestLockProject(t *testing.T) {
+	_, db, td := mustInitRouterDB(true)
+	defer td()
+
+	err := model.Transaction(db, func(tx *gorm.DB) error {
+		if err := lockProject(tx, 3); err != nil {
+			return err
+		}
+		return tx.Delete(&model.Project{}, "id = ?", 3).Error
+	})
+	assert.NoError(t, err)
+	err = model.Transaction(db, func(tx *gorm.DB) error {
+		return lockProject(tx, 3)
+	})
+	assert.Equal(t, errProjectNotFound, err)
+}
+
+func TestProjectMembers(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	tests := []struct {
+		method string
+		path   string
+		in     interface{}
+		code   int
+	}{
+		{method: http.MethodPut, path: "/projects/3/members/2", in: gin.H{"role": "viewer"}, code: http.StatusOK},
+		// changes role
+		{method: http.MethodPut, path: "/projects/3/members/1", in: gin.H{"role": "owner"}, code: http.StatusOK},
+		{method: http.MethodPut, path: "/projects/3/members/1", in: gin.H{"role": "boss"}, code: http.StatusBadRequest},
+		{method: http.MethodPut, path: "/projects/3/members/1", in: gin.H{}, code: http.StatusBadRequest},
+		{method: http.MethodPut, path: "/projects/3/members/20", in: gin.H{"role": "viewer"}, code: http.StatusBadRequest},
+		{method: http.MethodPut, path: "/projects/30/members/1", in: gin.H{"role": "viewer"}, code: http.StatusNotFound},
+		{method: http.MethodDelete, path: "/projects/3/members/2", code: http.StatusOK},
+		{method: http.MethodDelete, path: "/projects/3/members/2", code: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := test.MustRecord(t, r, tt.method, tt.path, tt.in)
+		assert.Equal(t, tt.code, w.Code, "%s %s", tt.method, tt.path)
+	}
+
+	w := test.MustRecord(t, r, http.MethodGet, "/projects/3/members")
+	if assert.Equal(t, http.StatusOK, w.Code) {
+		var res []model.ProjectMember
+		test.MustBind(t, w, &res)
+		assert.Equal(t, []model.ProjectMember{{ProjectID: 3, UserID: 1, Role: model.MemberOwner}}, res)
+	}
+}
+
+func TestProjectResources(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	// one wok for the canteen
+	w := test.MustRecord(t, r, http.MethodPut, "/projects/3/quotas/pan.instance.wok", gin.H{"value": 1})
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		return
+	}
+
+	in := model.Resource{Name: "canteen wok", Type: "pan.instance.wok"}
+	w = test.MustRecord(t, r, http.MethodPost, "/projects/3/resources", in)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		return
+	}
+	var rc model.Resource
+	test.MustBind(t, w, &rc)
+	assert.Equal(t, uint(3), rc.ProjectID)
+	assert.Regexp(t, fmt.Sprintf(`/projects/3/resources/%d/operations/\d+$`, rc.ID), w.Header().Get("Operation-Location"))
+
+	w = test.MustRecord(t, r, http.MethodPost, "/projects/3/resources", in)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = test.MustRecord(t, r, http.MethodGet, "/projects/3/resources")
+	if assert.Equal(t, http.StatusOK, w.Code) {
+		var res []model.Resource
+		test.MustBind(t, w, &res)
+		assert.Len(t, res, 1)
+	}
+
+	w = test.MustRecord(t, r, http.MethodGet, "/projects/3/usage")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = test.MustRecord(t, r, http.MethodGet, "/projects/3/quotas/usage")
+	if assert.Equal(t, http.StatusOK, w.Code) {
+		var res []quotaUsage
+		test.MustBind(t, w, &res)
+		for _, qu := range res {
+			if qu.Type == "pan.instance.wok" {
+				assert.Equal(t, 1, qu.Used)
+			}
+		}
+	}
+
+	// resources of other projects are not found
+	w = test.MustRecord(t, r, http.MethodGet, fmt.Sprintf("/projects/1/resources/%d", rc.ID))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = test.MustRecord(t, r, http.MethodGet, "/projects/30/resources")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -2,55 +2,152 @@ package v1
 
 import (
 	"errors"
-	"log"
+	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
+	"github.com/yodo-io/ycp/pkg/api"
+	"github.com/yodo-io/ycp/pkg/api/v1/audit"
 	"github.com/yodo-io/ycp/pkg/model"
 )
 
+var errQuotaExists = api.NewError(api.CodeAlreadyExists, "Quota already exists")
+
 type quotas struct {
-	db *gorm.DB
+	db      *gorm.DB
+	project projectScope
+}
+
+// Usage of a project's quota for a single resource type. Types without a quota are unlimited,
+// limit and remaining are omitted for those.
+type quotaUsage struct {
+	ProjectID uint   `json:"projectId"`
+	Type      string `json:"type"`
+	Limit     *int   `json:"limit,omitempty"`
+	Used      int    `json:"used"`
+	Remaining *int   `json:"remaining,omitempty"`
+	Unlimited bool   `json:"unlimited"`
 }
 
-// we can only update the value, so binding a Quota would fail for PATCH
+// we can only update the value, so binding a Quota would fail for PATCH. The value is a pointer, so
+// leaving it out is an error rather than setting the quota to 0.
 type quotaPatch struct {
-	Value int `json:"value"`
+	Value *int `json:"value" binding:"exists,min=0"`
 }
 
-func (qc *quotas) listForUser(c *gin.Context) (int, interface{}) {
+var quotaList = listQuery{
+	sort:   map[string]string{"id": "id", "type": "type", "value": "value"},
+	filter: map[string]string{"type": "type = ?"},
+	order:  "id",
+}
+
+func (qc *quotas) list(c *gin.Context) (int, interface{}) {
 	var qs []*model.Quota
-	uid := c.Param("uid")
 
-	// ensure user exists
-	u, err := lookupUser(qc.db, uid)
+	// ensure project exists
+	p, _, err := qc.project(qc.db, c)
 	if err != nil {
-		log.Println(err)
 		return http.StatusInternalServerError, err
 	}
-	if u == nil {
-		return http.StatusNotFound, err
+	if p == nil {
+		return http.StatusNotFound, errProjectNotFound
 	}
 
-	// find quotas for user
-	if err := qc.db.Find(&qs, "user_id = ?", uid).Error; err != nil {
-		return http.StatusInternalServerError, err
+	// find quotas for project
+	if code, err := quotaList.find(c, qc.db.Where("project_id = ?", p.ID), &qs); err != nil {
+		return code, err
 	}
 	return http.StatusOK, qs
 }
 
-func (qc *quotas) createForUser(c *gin.Context) (int, interface{}) {
-	uid := c.Param("uid")
+// List usage for every type in the catalog
+func (qc *quotas) projectUsage(c *gin.Context) (int, interface{}) {
+	// ensure project exists
+	p, _, err := qc.project(qc.db, c)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if p == nil {
+		return http.StatusNotFound, errProjectNotFound
+	}
+
+	var cat []*model.Catalog
+	if err := qc.db.Order("name").Find(&cat).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	res := make([]*quotaUsage, len(cat))
+	for i, ci := range cat {
+		if res[i], err = usageFor(qc.db, p.ID, ci.Name); err != nil {
+			return http.StatusInternalServerError, err
+		}
+	}
+	return http.StatusOK, res
+}
+
+// List usage of all quotas across projects, fullest first. With ?threshold=0.8, only quotas that are
+// at least 80% used are returned, to find projects near their limits.
+func (qc *quotas) usage(c *gin.Context) (int, interface{}) {
+	threshold := 0.0
+	if t := c.Query("threshold"); t != "" {
+		v, err := strconv.ParseFloat(t, 64)
+		if err != nil || v < 0 {
+			return http.StatusBadRequest, fmt.Errorf("Invalid threshold %q", t)
+		}
+		threshold = v
+	}
 
-	// ensure user exists
-	u, err := lookupUser(qc.db, uid)
+	rows, err := qc.db.Raw(`SELECT "quota"."project_id", "quota"."type", "quota"."value", COUNT("resources"."id")
+		FROM "quota" LEFT JOIN "resources" ON "resources"."project_id" = "quota"."project_id" AND "resources"."type" = "quota"."type"
+			AND "resources"."status" <> ?
+			AND NOT ("resources"."status" = ? AND EXISTS (SELECT 1 FROM "catalogs"
+				WHERE "catalogs"."name" = "resources"."type" AND "catalogs"."free_when_stopped" = ?))
+		GROUP BY "quota"."id", "quota"."project_id", "quota"."type", "quota"."value"`,
+		model.ResourceDeleted, model.ResourceStopped, true).Rows()
 	if err != nil {
-		log.Println(err)
 		return http.StatusInternalServerError, err
 	}
-	if u == nil {
-		return http.StatusNotFound, err
+	defer rows.Close()
+
+	res := []*quotaUsage{}
+	for rows.Next() {
+		var q model.Quota
+		var n int
+		if err := rows.Scan(&q.ProjectID, &q.Type, &q.Value, &n); err != nil {
+			return http.StatusInternalServerError, err
+		}
+		qu := newQuotaUsage(q.ProjectID, q.Type, &q, n)
+		if utilization(qu) >= threshold {
+			res = append(res, qu)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		ui, uj := utilization(res[i]), utilization(res[j])
+		if ui != uj {
+			return ui > uj
+		}
+		if res[i].ProjectID != res[j].ProjectID {
+			return res[i].ProjectID < res[j].ProjectID
+		}
+		return res[i].Type < res[j].Type
+	})
+	return http.StatusOK, res
+}
+
+func (qc *quotas) create(c *gin.Context) (int, interface{}) {
+	// ensure project exists
+	p, _, err := qc.project(qc.db, c)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if p == nil {
+		return http.StatusNotFound, errProjectNotFound
 	}
 
 	var q model.Quota
@@ -61,24 +158,116 @@ func (qc *quotas) createForUser(c *gin.Context) (int, interface{}) {
 	// ensure catalog item exists
 	cat, err := lookupCatalog(qc.db, q.Type)
 	if err != nil {
-		log.Println(err)
 		return http.StatusInternalServerError, err
 	}
 	if cat == nil {
-		return http.StatusBadRequest, errors.New("Invalid resource type")
+		return http.StatusBadRequest, errInvalidResourceType
 	}
 
-	// set userid and insert
-	q.UserID = u.ID
-	if err := qc.db.Create(&q).Error; err != nil {
-		log.Println(err)
+	// set project and insert, unless there already is a quota for the type
+	q.ProjectID = p.ID
+	err = model.Transaction(qc.db, func(tx *gorm.DB) error {
+		// the catalog item can't be deleted before the quota uses it
+		if err := lockCatalog(tx, q.Type); err != nil {
+			return err
+		}
+		if err := lockQuota(tx, p.ID, q.Type); err != nil {
+			return err
+		}
+		ex, err := lookupQuota(tx, p.ID, q.Type)
+		if err != nil {
+			return err
+		}
+		if ex != nil {
+			return errQuotaExists
+		}
+		// the lock doesn't cover quotas which don't exist yet, concurrent requests may insert first
+		err = tx.Create(&q).Error
+		if model.IsUniqueViolation(err) {
+			return errQuotaExists
+		}
+		return err
+	})
+	if err == errQuotaExists {
+		return http.StatusConflict, err
+	}
+	if err == errCatalogItemNotFound {
+		return http.StatusBadRequest, errInvalidResourceType
+	}
+	if err != nil {
 		return http.StatusInternalServerError, err
 	}
 	return http.StatusCreated, q
 }
 
-func (qc *quotas) updateForUser(c *gin.Context) (int, interface{}) {
-	uid := c.Param("uid")
+// Create or update the project's quota for a type, so provisioning scripts can set quotas idempotently
+func (qc *quotas) put(c *gin.Context) (int, interface{}) {
+	tp := c.Param("type")
+
+	// ensure project exists
+	p, _, err := qc.project(qc.db, c)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if p == nil {
+		return http.StatusNotFound, errProjectNotFound
+	}
+
+	var qp quotaPatch
+	if err := c.ShouldBind(&qp); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	// ensure catalog item exists
+	cat, err := lookupCatalog(qc.db, tp)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if cat == nil {
+		return http.StatusBadRequest, errInvalidResourceType
+	}
+
+	code := http.StatusOK
+	var q *model.Quota
+	err = model.Transaction(qc.db, func(tx *gorm.DB) error {
+		// the catalog item can't be deleted before the quota uses it
+		if err := lockCatalog(tx, cat.Name); err != nil {
+			return err
+		}
+		if err := lockQuota(tx, p.ID, cat.Name); err != nil {
+			return err
+		}
+		if q, err = lookupQuota(tx, p.ID, cat.Name); err != nil {
+			return err
+		}
+		if q == nil {
+			code = http.StatusCreated
+			nq := model.NewQuota(p.ID, cat.Name, *qp.Value)
+			q = &nq
+			// the lock doesn't cover quotas which don't exist yet, concurrent requests may insert first
+			err := tx.Create(q).Error
+			if model.IsUniqueViolation(err) {
+				return errQuotaExists
+			}
+			return err
+		}
+		audit.Before(c, q)
+		q.Value = *qp.Value
+		return tx.Model(&model.Quota{}).Where("id = ?", q.ID).Update("value", *qp.Value).Error
+	})
+	if err == errQuotaExists {
+		return http.StatusConflict, err
+	}
+	if err == errCatalogItemNotFound {
+		return http.StatusBadRequest, errInvalidResourceType
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return code, q
+}
+
+func (qc *quotas) update(c *gin.Context) (int, interface{}) {
 	qid := c.Param("qid")
 
 	var qp quotaPatch
@@ -86,18 +275,26 @@ func (qc *quotas) updateForUser(c *gin.Context) (int, interface{}) {
 	if err := c.ShouldBind(&qp); err != nil {
 		return http.StatusBadRequest, err
 	}
-	// if either id is wrong or id and userid don't match this will return []
-	if err := qc.db.Find(&qs, "user_id = ? and id = ?", uid, qid).Error; err != nil {
-		log.Println(err)
+	// ensure project exists
+	p, _, err := qc.project(qc.db, c)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if p == nil {
+		return http.StatusNotFound, errProjectNotFound
+	}
+	// if either id is wrong or id and project don't match this will return []
+	if err := qc.db.Find(&qs, "project_id = ? and id = ?", p.ID, qid).Error; err != nil {
 		return http.StatusInternalServerError, err
 	}
 	if len(qs) == 0 {
 		return http.StatusNotFound, errors.New("Quota not found")
 	}
+	audit.Before(c, qs[0])
 
 	// update, prevent accidental id change
 	up := gin.H{
-		"value": qp.Value,
+		"value": *qp.Value,
 	}
 	if err := qc.db.Model(&model.Quota{}).Where("id = ?", qid).Omit("id").Updates(up).Error; err != nil {
 		return http.StatusInternalServerError, err
@@ -109,14 +306,21 @@ func (qc *quotas) updateForUser(c *gin.Context) (int, interface{}) {
 	return http.StatusOK, qs[0]
 }
 
-func (qc *quotas) deleteForUser(c *gin.Context) (int, interface{}) {
-	uid := c.Param("uid")
+func (qc *quotas) delete(c *gin.Context) (int, interface{}) {
 	qid := c.Param("qid")
 
-	// lookup, make sure qid/uid are correct
+	// ensure project exists
+	p, _, err := qc.project(qc.db, c)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if p == nil {
+		return http.StatusNotFound, errProjectNotFound
+	}
+
+	// lookup, make sure qid and project are correct
 	var qs []*model.Quota
-	if err := qc.db.Find(&qs, "id = ? and user_id = ?", qid, uid).Error; err != nil {
-		log.Println(err)
+	if err := qc.db.Find(&qs, "id = ? and project_id = ?", qid, p.ID).Error; err != nil {
 		return http.StatusInternalServerError, err
 	}
 	if len(qs) == 0 {
@@ -130,9 +334,9 @@ func (qc *quotas) deleteForUser(c *gin.Context) (int, interface{}) {
 	return http.StatusOK, qs[0]
 }
 
-func lookupQuota(db *gorm.DB, uid uint, tp string) (*model.Quota, error) {
+func lookupQuota(db *gorm.DB, projectID uint, tp string) (*model.Quota, error) {
 	var q []*model.Quota
-	if err := db.Find(&q, "user_id = ? and type = ?", uid, tp).Error; err != nil {
+	if err := db.Find(&q, "project_id = ? and type = ?", projectID, tp).Error; err != nil {
 		return nil, err
 	}
 	if len(q) == 0 {
@@ -140,3 +344,61 @@ func lookupQuota(db *gorm.DB, uid uint, tp string) (*model.Quota, error) {
 	}
 	return q[0], nil
 }
+
+// Lock the quota row with a no-op write. Other transactions trying to lock the same quota block
+// until this one ends, so counting and inserting can't interleave. On SQLite, the write takes the
+// database lock, which serializes writers even if there is no quota row.
+func lockQuota(tx *gorm.DB, projectID uint, tp string) error {
+	return tx.Exec(`UPDATE "quota" SET "value" = "value" WHERE "project_id" = ? AND "type" = ?`, projectID, tp).Error
+}
+
+// Count the project's resources of a type and compare against its quota, see checkQuota
+func usageFor(db *gorm.DB, projectID uint, tp string) (*quotaUsage, error) {
+	q, err := lookupQuota(db, projectID, tp)
+	if err != nil {
+		return nil, err
+	}
+	cat, err := lookupCatalog(db, tp)
+	if err != nil {
+		return nil, err
+	}
+	free := []model.ResourceStatus{model.ResourceDeleted}
+	if cat != nil && cat.FreeWhenStopped {
+		free = append(free, model.ResourceStopped)
+	}
+	var n int
+	err = db.Model(&model.Resource{}).
+		Where("project_id = ? and type = ? and status not in (?)", projectID, tp, free).
+		Count(&n).Error
+	if err != nil {
+		return nil, err
+	}
+	return newQuotaUsage(projectID, tp, q, n), nil
+}
+
+// Create usage from a quota (nil if there is none) and number of resources in use
+func newQuotaUsage(projectID uint, tp string, q *model.Quota, used int) *quotaUsage {
+	qu := &quotaUsage{ProjectID: projectID, Type: tp, Used: used, Unlimited: q == nil}
+	if q == nil {
+		return qu
+	}
+	// quotas can be lowered below what is in use already
+	rem := q.Value - used
+	if rem < 0 {
+		rem = 0
+	}
+	limit := q.Value
+	qu.Limit, qu.Remaining = &limit, &rem
+	return qu
+}
+
+// Fraction of the quota in use, a quota of 0 is fully used
+func utilization(qu *quotaUsage) float64 {
+	if qu.Unlimited {
+		return 0
+	}
+	if *qu.Limit <= 0 {
+		return 1
+	}
+	return float64(qu.Used) / float64(*qu.Limit)
+}
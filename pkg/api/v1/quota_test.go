@@ -51,7 +51,7 @@ func TestGetQuotaForUser(t *testing.T) {
 		assert.Len(t, res, tt.len)
 		for _, q := range res {
 			assert.NotEmpty(t, q.Type)
-			assert.Equal(t, tt.userID, q.UserID)
+			assert.Equal(t, tt.userID, q.ProjectID)
 		}
 	}
 }
@@ -68,18 +68,24 @@ func TestCreateQuota(t *testing.T) {
 	}{
 		// ok
 		{
-			userID: 1,
+			userID: 2,
 			in:     model.Quota{Type: "pot.instance.small", Value: 20},
-			out:    model.Quota{Type: "pot.instance.small", Value: 20, UserID: 1},
+			out:    model.Quota{Type: "pot.instance.small", Value: 20, ProjectID: 2},
 			code:   http.StatusCreated,
 		},
-		// should fix userid mismatch
+		// should fix project id mismatch
 		{
 			userID: 1,
-			in:     model.Quota{Type: "pot.instance.small", Value: 20, UserID: 10},
-			out:    model.Quota{Type: "pot.instance.small", Value: 20, UserID: 1},
+			in:     model.Quota{Type: "pan.instance.wok", Value: 20, ProjectID: 10},
+			out:    model.Quota{Type: "pan.instance.wok", Value: 20, ProjectID: 1},
 			code:   http.StatusCreated,
 		},
+		// quota for type exists already
+		{
+			userID: 1,
+			in:     model.Quota{Type: "pot.instance.small", Value: 20},
+			code:   http.StatusConflict,
+		},
 		// non-existing resource
 		{
 			userID: 1,
@@ -108,7 +114,7 @@ func TestCreateQuota(t *testing.T) {
 		assert.NotZero(t, res.ID)
 		assert.Equal(t, tt.in.Type, res.Type)
 		assert.Equal(t, tt.in.Value, res.Value)
-		assert.Equal(t, tt.userID, res.UserID)
+		assert.Equal(t, tt.userID, res.ProjectID)
 	}
 }
 
@@ -139,7 +145,7 @@ func TestDeleteQuota(t *testing.T) {
 		test.MustBind(t, w, &q)
 		assert.NotEmpty(t, q)
 		assert.Equal(t, tt.id, q.ID)
-		assert.Equal(t, tt.userID, q.UserID)
+		assert.Equal(t, tt.userID, q.ProjectID)
 		assert.NotEmpty(t, q.Type)
 
 		// test if resource was really deleted
@@ -198,8 +204,182 @@ func TestUpdateQuota(t *testing.T) {
 		test.MustBind(t, w, &q)
 		assert.NotEmpty(t, q)
 		assert.Equal(t, tt.id, q.ID)
-		assert.Equal(t, tt.userID, q.UserID)
+		assert.Equal(t, tt.userID, q.ProjectID)
 		assert.Equal(t, tt.value, q.Value)
 		assert.NotEmpty(t, q.Type)
 	}
 }
+
+func TestPutQuota(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	tests := []struct {
+		userID uint
+		tp     string
+		value  int
+		code   int
+	}{
+		// update existing
+		{userID: 1, tp: "pot.instance.small", value: 20, code: http.StatusOK},
+		// create new
+		{userID: 2, tp: "pot.instance.small", value: 5, code: http.StatusCreated},
+		// same again is an update
+		{userID: 2, tp: "pot.instance.small", value: 5, code: http.StatusOK},
+		// non-existing resource
+		{userID: 1, tp: "pitchfork.instance.3s", value: 5, code: http.StatusBadRequest},
+		// non-existing user
+		{userID: 10, tp: "pot.instance.small", value: 5, code: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		in := gin.H{"value": tt.value}
+		w := test.MustRecord(t, r, http.MethodPut, fmt.Sprintf("/quotas/%d/%s", tt.userID, tt.tp), in)
+		if !assert.Equal(t, tt.code, w.Code) {
+			continue
+		}
+		if w.Code >= http.StatusBadRequest {
+			continue
+		}
+
+		var q model.Quota
+		test.MustBind(t, w, &q)
+		assert.NotZero(t, q.ID)
+		assert.Equal(t, tt.userID, q.ProjectID)
+		assert.Equal(t, tt.tp, q.Type)
+		assert.Equal(t, tt.value, q.Value)
+
+		// there is exactly one quota for the type
+		w = test.MustRecord(t, r, http.MethodGet, fmt.Sprintf("/quotas/%d", tt.userID))
+		var qs []model.Quota
+		test.MustBind(t, w, &qs)
+		n := 0
+		for _, q := range qs {
+			if q.Type == tt.tp {
+				n++
+				assert.Equal(t, tt.value, q.Value)
+			}
+		}
+		assert.Equal(t, 1, n)
+	}
+}
+
+func TestQuotaValueRequired(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	tests := []struct {
+		method string
+		path   string
+		in     gin.H
+	}{
+		// missing or misspelled values would set the quota to 0
+		{method: http.MethodPut, path: "/quotas/1/pot.instance.small", in: gin.H{}},
+		{method: http.MethodPut, path: "/quotas/1/pot.instance.small", in: gin.H{"vaule": 5}},
+		{method: http.MethodPatch, path: "/quotas/1/1", in: gin.H{}},
+		// negative values
+		{method: http.MethodPut, path: "/quotas/1/pot.instance.small", in: gin.H{"value": -1}},
+		{method: http.MethodPut, path: "/quotas/2/pot.instance.small", in: gin.H{"value": -1}},
+		{method: http.MethodPatch, path: "/quotas/1/1", in: gin.H{"value": -1}},
+		{method: http.MethodPost, path: "/quotas/2", in: gin.H{"type": "pot.instance.small", "value": -1}},
+	}
+	for _, tt := range tests {
+		w := test.MustRecord(t, r, tt.method, tt.path, tt.in)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "%s %s %v", tt.method, tt.path, tt.in)
+	}
+
+	// quotas are unchanged
+	w := test.MustRecord(t, r, http.MethodGet, "/quotas/1")
+	var qs []model.Quota
+	test.MustBind(t, w, &qs)
+	if assert.Len(t, qs, 1) {
+		assert.Equal(t, 10, qs[0].Value)
+	}
+	w = test.MustRecord(t, r, http.MethodGet, "/quotas/2")
+	test.MustBind(t, w, &qs)
+	assert.Empty(t, qs)
+}
+
+func TestQuotaUsageForUser(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	in := model.Resource{Name: "a small cooking pot", Type: "pot.instance.small"}
+	for i := 0; i < 3; i++ {
+		w := test.MustRecord(t, r, http.MethodPost, "/resources/1", in)
+		if !assert.Equal(t, http.StatusCreated, w.Code) {
+			return
+		}
+	}
+
+	w := test.MustRecord(t, r, http.MethodGet, "/quotas/1/usage")
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		return
+	}
+	var res []quotaUsage
+	test.MustBind(t, w, &res)
+
+	byType := map[string]quotaUsage{}
+	for _, qu := range res {
+		assert.Equal(t, uint(1), qu.ProjectID)
+		byType[qu.Type] = qu
+	}
+	assert.Len(t, byType, 7) // one per catalog item
+
+	small := byType["pot.instance.small"]
+	assert.False(t, small.Unlimited)
+	assert.Equal(t, 3, small.Used)
+	if assert.NotNil(t, small.Limit) && assert.NotNil(t, small.Remaining) {
+		assert.Equal(t, 10, *small.Limit)
+		assert.Equal(t, 7, *small.Remaining)
+	}
+
+	large := byType["pot.instance.large"]
+	assert.True(t, large.Unlimited)
+	assert.Equal(t, 1, large.Used)
+	assert.Nil(t, large.Limit)
+	assert.Nil(t, large.Remaining)
+
+	w = test.MustRecord(t, r, http.MethodGet, "/quotas/20/usage")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestQuotaUsage(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	// user 2 has used up their quota for woks
+	w := test.MustRecord(t, r, http.MethodPut, "/quotas/2/pan.instance.wok", gin.H{"value": 1})
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		return
+	}
+
+	tests := []struct {
+		query string
+		types []string
+		code  int
+	}{
+		{query: "", types: []string{"pan.instance.wok", "pot.instance.small"}, code: http.StatusOK},
+		{query: "?threshold=0.5", types: []string{"pan.instance.wok"}, code: http.StatusOK},
+		{query: "?threshold=2", types: []string{}, code: http.StatusOK},
+		{query: "?threshold=full", code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		w := test.MustRecord(t, r, http.MethodGet, "/usage"+tt.query)
+		if !assert.Equal(t, tt.code, w.Code) {
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+
+		var res []quotaUsage
+		test.MustBind(t, w, &res)
+		types := []string{}
+		for _, qu := range res {
+			types = append(types, qu.Type)
+		}
+		assert.Equal(t, tt.types, types, tt.query)
+	}
+}
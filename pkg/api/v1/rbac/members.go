@@ -0,0 +1,77 @@
+package rbac
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/yodo-io/ycp/pkg/api/v1/auth"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+// Memberships looks up the organizations and projects a user is a member of, see DBMemberships
+type Memberships interface {
+	// Orgs returns the IDs of organizations in which the user holds at least the given role
+	Orgs(userID uint, min model.MemberRole) ([]uint, error)
+	// Projects returns the IDs of projects in which the user holds at least the given role
+	Projects(userID uint, min model.MemberRole) ([]uint, error)
+}
+
+// Path templates are rendered against the subject of a request: the claims, plus the user's memberships.
+// Memberships are looked up at most once per role and request.
+type subject struct {
+	*auth.Claims
+	members Memberships
+	cache   map[string]string
+}
+
+func newSubject(cl *auth.Claims, m Memberships) *subject {
+	return &subject{Claims: cl, members: m, cache: map[string]string{}}
+}
+
+// Orgs renders a regex matching the IDs of organizations in which the user holds at least role min
+func (s *subject) Orgs(min string) (string, error) {
+	return s.lookup("orgs", min, func(m Memberships, r model.MemberRole) ([]uint, error) {
+		return m.Orgs(s.UserID, r)
+	})
+}
+
+// Projects renders a regex matching the IDs of projects in which the user holds at least role min
+func (s *subject) Projects(min string) (string, error) {
+	return s.lookup("projects", min, func(m Memberships, r model.MemberRole) ([]uint, error) {
+		return m.Projects(s.UserID, r)
+	})
+}
+
+func (s *subject) lookup(kind, min string, fn func(Memberships, model.MemberRole) ([]uint, error)) (string, error) {
+	role := model.MemberRole(min)
+	if !role.Valid() {
+		return "", fmt.Errorf("Unknown member role %q", min)
+	}
+	key := kind + ":" + min
+	if re, ok := s.cache[key]; ok {
+		return re, nil
+	}
+	var ids []uint
+	if s.members != nil {
+		var err error
+		if ids, err = fn(s.members, role); err != nil {
+			return "", err
+		}
+	}
+	re := idPattern(ids)
+	s.cache[key] = re
+	return re, nil
+}
+
+// Regex matching any of the given IDs, or nothing if there are none
+func idPattern(ids []uint) string {
+	if len(ids) == 0 {
+		return `[^\s\S]`
+	}
+	strs := make([]string, len(ids))
+	for i, id := range ids {
+		strs[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return "(?:" + strings.Join(strs, "|") + ")"
+}
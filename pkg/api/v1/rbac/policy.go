@@ -0,0 +1,222 @@
+package rbac
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sync"
+	"text/template"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/yodo-io/ycp/pkg/api/v1/auth"
+	"github.com/yodo-io/ycp/pkg/logging"
+	"github.com/yodo-io/ycp/pkg/model"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Rule grants access to requests matching Path and Action, see package doc for details
+type Rule struct {
+	Path   string `yaml:"path"`
+	Action string `yaml:"action"`
+}
+
+// Rules maps roles to the rules granted to them
+type Rules map[model.Role][]Rule
+
+// Source provides the rules of a Policy
+type Source interface {
+	Load() (Rules, error)
+}
+
+// Load implements Source, so a static set of rules can be used as a source
+func (r Rules) Load() (Rules, error) {
+	return r, nil
+}
+
+// FileSource reads rules from a YAML or JSON file, mapping role names to a list of rules:
+//
+//	user:
+//	  - path: /v\d+/catalog
+//	    action: GET
+func FileSource(path string) Source {
+	return fileSource(path)
+}
+
+type fileSource string
+
+func (f fileSource) Load() (Rules, error) {
+	b, err := ioutil.ReadFile(string(f))
+	if err != nil {
+		return nil, err
+	}
+	var r Rules
+	// JSON is a subset of YAML so a single parser will do
+	if err := yaml.UnmarshalStrict(b, &r); err != nil {
+		return nil, fmt.Errorf("Invalid policy file %s: %v", f, err)
+	}
+	return r, nil
+}
+
+// DBSource reads rules from the rbac_rules table, see model.RBACRule
+func DBSource(db *gorm.DB) Source {
+	return &dbSource{db}
+}
+
+type dbSource struct {
+	db *gorm.DB
+}
+
+func (s *dbSource) Load() (Rules, error) {
+	var rs []*model.RBACRule
+	if err := s.db.Order("id").Find(&rs).Error; err != nil {
+		return nil, err
+	}
+	r := Rules{}
+	for _, rr := range rs {
+		r[rr.Role] = append(r[rr.Role], Rule{Path: rr.Path, Action: rr.Action})
+	}
+	return r, nil
+}
+
+// DBMemberships looks up memberships in the database, see model.OrgsOf and model.ProjectsOf
+func DBMemberships(db *gorm.DB) Memberships {
+	return &dbMemberships{db}
+}
+
+type dbMemberships struct {
+	db *gorm.DB
+}
+
+func (m *dbMemberships) Orgs(userID uint, min model.MemberRole) ([]uint, error) {
+	return model.OrgsOf(m.db, userID, min)
+}
+
+func (m *dbMemberships) Projects(userID uint, min model.MemberRole) ([]uint, error) {
+	return model.ProjectsOf(m.db, userID, min)
+}
+
+// Policy holds the current set of rules loaded from a Source. It is safe for concurrent use.
+type Policy struct {
+	// Memberships is used by rules granting access by organization or project membership. If nil,
+	// those rules don't match. Must be set before the policy is used.
+	Memberships Memberships
+
+	src   Source
+	mu    sync.RWMutex
+	rules map[model.Role][]*compiledRule
+}
+
+// Rule with path template and action regex parsed once on load
+type compiledRule struct {
+	path   *template.Template
+	action *regexp.Regexp
+}
+
+// NewPolicy creates a policy and loads its rules from the given source
+func NewPolicy(src Source) (*Policy, error) {
+	p := &Policy{src: src}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload loads the rules from the policy's source again. If loading fails, the
+// current rules are kept and an error is returned.
+func (p *Policy) Reload() error {
+	r, err := p.src.Load()
+	if err != nil {
+		return err
+	}
+	rules, err := compileRules(r)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	p.rules = rules
+	p.mu.Unlock()
+	return nil
+}
+
+// Allowed checks if any rule of any of the claim's roles matches the given request method and path.
+// First rule that matches wins, if none matches, deny.
+func (p *Policy) Allowed(cl *auth.Claims, method, path string) (bool, error) {
+	p.mu.RLock()
+	var rules []*compiledRule
+	for _, role := range cl.Roles {
+		rules = append(rules, p.rules[role]...)
+	}
+	p.mu.RUnlock()
+
+	s := newSubject(cl, p.Memberships)
+	for _, r := range rules {
+		pm, err := pathMatcher(s, r)
+		if err != nil {
+			return false, err
+		}
+		if pm.MatchString(path) && r.action.MatchString(method) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Parse and validate all rules, so an invalid policy is rejected on load rather than on request
+func compileRules(r Rules) (map[model.Role][]*compiledRule, error) {
+	res := map[model.Role][]*compiledRule{}
+	for role, rules := range r {
+		for _, rule := range rules {
+			cr, err := compileRule(rule)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid rule %v for role %s: %v", rule, role, err)
+			}
+			res[role] = append(res[role], cr)
+		}
+	}
+	return res, nil
+}
+
+func compileRule(r Rule) (*compiledRule, error) {
+	tpl, err := template.New("").Option("missingkey=error").Parse(r.Path)
+	if err != nil {
+		return nil, err
+	}
+	// Actions are simply converted into regexes, no template parsing
+	ac, err := regexp.Compile(r.Action)
+	if err != nil {
+		return nil, err
+	}
+	cr := &compiledRule{path: tpl, action: ac}
+	// render against empty claims once to catch unknown fields, member roles and invalid regexes
+	if _, err := pathMatcher(newSubject(&auth.Claims{}, nil), cr); err != nil {
+		return nil, err
+	}
+	return cr, nil
+}
+
+// ValidateRule checks if a rule can be used in a policy, i.e. path is a valid template and
+// both path and action are valid regular expressions
+func ValidateRule(r Rule) error {
+	_, err := compileRule(r)
+	return err
+}
+
+// Reloader returns middleware reloading the policy after successful write requests to paths
+// matching the given pattern, so changes to DB based rules take effect immediately.
+func Reloader(p *Policy, pattern string) gin.HandlerFunc {
+	re := regexp.MustCompile(pattern)
+	return func(c *gin.Context) {
+		c.Next()
+		if c.Request.Method == http.MethodGet || !re.MatchString(c.Request.URL.Path) {
+			return
+		}
+		if c.Writer.Status() >= http.StatusBadRequest {
+			return
+		}
+		if err := p.Reload(); err != nil {
+			logging.From(c).Error("Failed to reload RBAC policy, keeping current rules", logging.Fields{"error": err})
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package rbac
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/api/v1/auth"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+const yamlPolicy = `
+user:
+  - path: /v\d+/catalog
+    action: GET
+`
+
+const jsonPolicy = `{"user": [{"path": "^/v\\d+/users/{{.UserID}}(/|$)", "action": ".*"}]}`
+
+func mustWritePolicy(t *testing.T, dir, content string) string {
+	path := filepath.Join(dir, "policy")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func mustTempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "ycp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func allowed(t *testing.T, p *Policy, cl *auth.Claims, method, path string) bool {
+	ok, err := p.Allowed(cl, method, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ok
+}
+
+func TestFilePolicyReload(t *testing.T) {
+	dir, td := mustTempDir(t)
+	defer td()
+
+	user := &auth.Claims{UserID: 1, Roles: []model.Role{model.RoleUser}}
+	path := mustWritePolicy(t, dir, yamlPolicy)
+
+	p, err := NewPolicy(FileSource(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, allowed(t, p, user, http.MethodGet, "/v1/catalog"))
+	assert.False(t, allowed(t, p, user, http.MethodGet, "/v1/users/1"))
+
+	// switch to JSON policy and reload
+	mustWritePolicy(t, dir, jsonPolicy)
+	if err := p.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, allowed(t, p, user, http.MethodGet, "/v1/catalog"))
+	assert.True(t, allowed(t, p, user, http.MethodGet, "/v1/users/1"))
+
+	// broken policy is rejected, current rules are kept
+	mustWritePolicy(t, dir, `user: [{path: "{{.Foo}}", action: ".*"}]`)
+	assert.Error(t, p.Reload())
+	assert.True(t, allowed(t, p, user, http.MethodGet, "/v1/users/1"))
+}
+
+func TestDBPolicyReload(t *testing.T) {
+	db := model.MustInitTestDB(false)
+	defer db.Close()
+
+	billing := &auth.Claims{UserID: 1, Roles: []model.Role{"billing"}}
+	p, err := NewPolicy(DBSource(db))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, allowed(t, p, billing, http.MethodGet, "/v1/users"))
+
+	rule := model.RBACRule{Role: "billing", Path: `/v\d+/.*`, Action: "GET"}
+	if err := db.Create(&rule).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	assert.True(t, allowed(t, p, billing, http.MethodGet, "/v1/users"))
+	assert.False(t, allowed(t, p, billing, http.MethodDelete, "/v1/users/1"))
+}
+
+func TestInvalidRules(t *testing.T) {
+	tests := []Rules{
+		{model.RoleUser: {{Path: "{{.UserID", Action: ".*"}}},
+		{model.RoleUser: {{Path: "{{.Unknown}}", Action: ".*"}}},
+		{model.RoleUser: {{Path: "/v1/(", Action: ".*"}}},
+		{model.RoleUser: {{Path: "/v1", Action: "GET("}}},
+		{model.RoleUser: {{Path: `/v1/projects/{{.Projects "boss"}}`, Action: ".*"}}},
+	}
+	for _, tt := range tests {
+		_, err := NewPolicy(tt)
+		assert.Error(t, err, "%v", tt)
+	}
+}
+
+func TestMultipleRoles(t *testing.T) {
+	p, err := NewPolicy(Rules{
+		model.RoleUser:    {{Path: `^/v\d+/users/{{.UserID}}(/|$)`, Action: ".*"}},
+		model.RoleAuditor: {{Path: `/v\d+/.*`, Action: "GET"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	both := &auth.Claims{UserID: 1, Roles: []model.Role{model.RoleUser, model.RoleAuditor}}
+	assert.True(t, allowed(t, p, both, http.MethodGet, "/v1/users/2"))
+	assert.True(t, allowed(t, p, both, http.MethodDelete, "/v1/users/1"))
+	assert.False(t, allowed(t, p, both, http.MethodDelete, "/v1/users/2"))
+	assert.False(t, allowed(t, p, both, http.MethodDelete, "/v1/users/10"))
+
+	none := &auth.Claims{UserID: 1}
+	assert.False(t, allowed(t, p, none, http.MethodGet, "/v1/users/1"))
+}
+
+type fakeMemberships map[model.MemberRole][]uint
+
+func (f fakeMemberships) Orgs(userID uint, min model.MemberRole) ([]uint, error) {
+	return nil, nil
+}
+
+func (f fakeMemberships) Projects(userID uint, min model.MemberRole) ([]uint, error) {
+	return f[min], nil
+}
+
+func TestMembershipRules(t *testing.T) {
+	p, err := NewPolicy(Rules{
+		model.RoleUser: {
+			{Path: `^/v\d+/projects/{{.Projects "viewer"}}$`, Action: "GET"},
+			{Path: `^/v\d+/projects/{{.Projects "owner"}}$`, Action: "DELETE"},
+			{Path: `^/v\d+/orgs/{{.Orgs "viewer"}}$`, Action: "GET"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &auth.Claims{UserID: 1, Roles: []model.Role{model.RoleUser}}
+
+	// without memberships nobody is a member of anything
+	assert.False(t, allowed(t, p, user, http.MethodGet, "/v1/projects/1"))
+
+	p.Memberships = fakeMemberships{
+		model.MemberViewer: {1, 12},
+		model.MemberOwner:  {12},
+	}
+	assert.True(t, allowed(t, p, user, http.MethodGet, "/v1/projects/1"))
+	assert.True(t, allowed(t, p, user, http.MethodGet, "/v1/projects/12"))
+	assert.False(t, allowed(t, p, user, http.MethodGet, "/v1/projects/2"))
+	assert.False(t, allowed(t, p, user, http.MethodGet, "/v1/projects/11"))
+	assert.True(t, allowed(t, p, user, http.MethodDelete, "/v1/projects/12"))
+	assert.False(t, allowed(t, p, user, http.MethodDelete, "/v1/projects/1"))
+	assert.False(t, allowed(t, p, user, http.MethodGet, "/v1/orgs/1"))
+}
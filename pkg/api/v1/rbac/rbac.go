@@ -19,9 +19,9 @@ URLs path component and HTTP method respectively.
 
 	// Path and action are matched as regex
 	// Additionally, path is parsed as template against claim
-	rule := &rule {
-		path: "/vd+/users/{{.UserID}}"
-		action: "GET|PATCH"
+	rule := Rule{
+		Path: "^/v\d+/users/{{.UserID}}(/|$)"
+		Action: "GET|PATCH"
 	}
 
 	// The following requests would match:
@@ -31,93 +31,86 @@ URLs path component and HTTP method respectively.
 
 	// These requests would not match:
 	// GET /v1/users/2
+	// GET /v1/users/10
 	// DELETE /v1/users/1
 	// POST /v1/users
 
 
-Currently only two role-based access levels are supported. Users with RoleAdmin are always allowed access
-(i.e. no rules are evaluated), users with RoleUser need to have at least one matching rule.
+Besides the claim's fields, path templates can match the organizations and projects the user is a
+member of. Orgs and Projects take the least role a member must hold and render as a regex matching the
+IDs found. Members of an organization hold their role in all of its projects.
 
-As of now, rules are harcoded in this package - however, the implementation would allow to easily serialize
-them in any structured document format (JSON, YAML) and keep them in a database or config file.
+	// Editors of project 3 can manage its resources, matches /v1/projects/3/resources/1
+	rule := Rule{
+		Path: `^/v\d+/projects/{{.Projects "editor"}}/resources(/|$)`
+		Action: ".*"
+	}
+
+Memberships are looked up per request, see Policy.Memberships, so changes take effect immediately.
+
+Rules are grouped by role, only the rules of the roles found in the claim are evaluated. There is no
+implicit super user, admins are granted access by a rule matching any path and action.
+
+Rules are kept in a Policy, which loads them from a Source: a YAML/JSON file (FileSource) or the
+rbac_rules table (DBSource). Calling Policy.Reload picks up changed rules without restarting the server.
 */
 package rbac
 
 import (
 	"errors"
-	"net/http"
 	"regexp"
 	"strings"
-	"text/template"
 
 	"github.com/yodo-io/ycp/pkg/api/v1/auth"
-	"github.com/yodo-io/ycp/pkg/model"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/yodo-io/ycp/pkg/api"
+	"github.com/yodo-io/ycp/pkg/metrics"
 )
 
-type rule struct {
-	Path   string
-	Action string
-}
-
-// We should store these rules in a config file or database, for simplicity they are hardcoded here
-var allow = []rule{
-	{Path: `/v\d+/catalog`, Action: `GET`},
-	{Path: `/v\d+/resources/{{.UserID}}`, Action: `.*`},
-	{Path: `/v\d+/quotas/{{.UserID}}`, Action: `GET`},
-	{Path: `/v\d+/users/{{.UserID}}`, Action: `.*`},
-}
+// Requests denied by RBAC, by method and route template
+var denials = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "ycp_rbac_denials_total",
+	Help: "Requests denied by RBAC, by method and route template",
+}, []string{"method", "route"})
 
-// Middleware creates new RBAC middleware
-func Middleware() gin.HandlerFunc {
+// Middleware creates new RBAC middleware, granting access based on the given policy
+func Middleware(p *Policy) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// No claims, no do
 		o, hasClaims := c.Get("claims")
 		if !hasClaims {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, api.ErrStr("Unauthorized"))
+			api.Unauthorized(c)
+			return
 		}
 		cl, ok := o.(auth.Claims)
 		if !ok {
 			api.Fatal(c, errors.New("Invalid claim type"))
+			return
 		}
 
-		// Admin can do anything
-		if cl.Role == model.RoleAdmin {
+		ok, err := p.Allowed(&cl, c.Request.Method, c.Request.URL.Path)
+		if err != nil {
+			api.Fatal(c, err)
 			return
 		}
-
-		// First rule that matches wins, if none matches, deny
-		// Evaluate path as go template to allow for "user can access their own stuff" type rules
-		// Match both as simple regexes
-		for _, r := range allow {
-			pm, err := pathMatcher(&cl, r)
-			if err != nil {
-				api.Fatal(c, err)
-			}
-			ac, err := actionMatcher(r)
-			if err != nil {
-				api.Fatal(c, err)
-			}
-			if pm.Match([]byte(c.Request.URL.Path)) && ac.Match([]byte(c.Request.Method)) {
-				return // pass
-			}
+		if ok {
+			return // pass
 		}
 
 		// No matching rules, no do
-		c.AbortWithStatusJSON(http.StatusForbidden, api.ErrStr("Forbidden"))
+		denials.WithLabelValues(c.Request.Method, metrics.Route(c)).Inc()
+		api.Forbidden(c)
 	}
 }
 
 // Render path as template from rule, then compile into a regex
-func pathMatcher(cl *auth.Claims, r rule) (*regexp.Regexp, error) {
+// Evaluating path as go template allows for "user can access their own stuff" type rules
+func pathMatcher(s *subject, r *compiledRule) (*regexp.Regexp, error) {
 	b := &strings.Builder{}
-	tpl, err := template.New("").Parse(r.Path)
-	if err != nil {
-		return nil, err
-	}
-	if err := tpl.Execute(b, cl); err != nil {
+	if err := r.path.Execute(b, s); err != nil {
 		return nil, err
 	}
 	re, err := regexp.Compile(b.String())
@@ -126,12 +119,3 @@ func pathMatcher(cl *auth.Claims, r rule) (*regexp.Regexp, error) {
 	}
 	return re, nil
 }
-
-// Actions are simply converted into regexes, no template parsing
-func actionMatcher(r rule) (*regexp.Regexp, error) {
-	re, err := regexp.Compile(r.Action)
-	if err != nil {
-		return nil, err
-	}
-	return re, nil
-}
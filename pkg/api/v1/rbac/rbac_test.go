@@ -24,12 +24,15 @@ func TestRBAC(t *testing.T) {
 	defer db.Close()
 	ac := auth.NewController(db, secret)
 	r := test.NewRouter()
+	p, err := NewPolicy(DBSource(db))
+	checkError(t, err)
+	p.Memberships = DBMemberships(db)
 
 	// middleware for token and rbac
 	rg := r.Group("/v1")
 	{
-		rg.Use(auth.Middleware(secret))
-		rg.Use(Middleware()) // RBAC middleware
+		rg.Use(auth.Middleware(db, secret))
+		rg.Use(Middleware(p)) // RBAC middleware
 
 		rg.GET("/users", dummy)
 		rg.GET("/users/:id", dummy)
@@ -42,12 +45,30 @@ func TestRBAC(t *testing.T) {
 		rg.POST("/resources/:uid", dummy)
 		rg.PATCH("/resources/:uid/:id", dummy)
 		rg.DELETE("/resources/:uid/:id", dummy)
+		rg.POST("/resources/:uid/:id/actions/:action", dummy)
 
 		rg.GET("/quotas/:uid", dummy)
 		rg.POST("/quotas/:uid", dummy)
 		rg.DELETE("/quotas/:uid", dummy)
 
 		rg.GET("/catalog", dummy)
+		rg.GET("/audit", dummy)
+
+		rg.GET("/orgs", dummy)
+		rg.POST("/orgs", dummy)
+		rg.GET("/orgs/:oid", dummy)
+		rg.DELETE("/orgs/:oid", dummy)
+		rg.GET("/orgs/:oid/projects", dummy)
+		rg.POST("/orgs/:oid/projects", dummy)
+
+		rg.GET("/projects/:pid", dummy)
+		rg.DELETE("/projects/:pid", dummy)
+		rg.GET("/projects/:pid/members", dummy)
+		rg.PUT("/projects/:pid/members/:uid", dummy)
+		rg.GET("/projects/:pid/resources", dummy)
+		rg.POST("/projects/:pid/resources", dummy)
+		rg.POST("/projects/:pid/resources/:rid/actions/:action", dummy)
+		rg.PUT("/projects/:pid/quotas/:type", dummy)
 	}
 
 	tests := []struct {
@@ -65,6 +86,16 @@ func TestRBAC(t *testing.T) {
 		{userID: 1, method: http.MethodPost, path: "/v1/users", code: http.StatusForbidden},
 		{userID: 1, method: http.MethodGet, path: "/v1/users/2", code: http.StatusForbidden},
 		{userID: 1, method: http.MethodDelete, path: "/v1/users/2", code: http.StatusForbidden},
+		// paths of user 1 are a prefix of those of user 10
+		{userID: 1, method: http.MethodGet, path: "/v1/users/10", code: http.StatusForbidden},
+		{userID: 1, method: http.MethodPatch, path: "/v1/users/10", code: http.StatusForbidden},
+		{userID: 1, method: http.MethodDelete, path: "/v1/users/10", code: http.StatusForbidden},
+		{userID: 1, method: http.MethodGet, path: "/v1/quotas/10", code: http.StatusForbidden},
+		{userID: 1, method: http.MethodGet, path: "/v1/resources/10", code: http.StatusForbidden},
+		{userID: 1, method: http.MethodPost, path: "/v1/resources/10/1/actions/stop", code: http.StatusForbidden},
+		// audit log is for admins and auditors only
+		{userID: 1, method: http.MethodGet, path: "/v1/audit", code: http.StatusForbidden},
+		{userID: 2, method: http.MethodGet, path: "/v1/audit", code: http.StatusOK},
 		// // catalog, user:1 - OK
 		{userID: 1, method: http.MethodGet, path: "/v1/catalog", code: http.StatusOK},
 		// // quotas, user:1 - OK
@@ -79,8 +110,34 @@ func TestRBAC(t *testing.T) {
 		{userID: 1, method: http.MethodPatch, path: "/v1/resources/1/1", code: http.StatusOK},
 		{userID: 1, method: http.MethodPost, path: "/v1/resources/1", code: http.StatusOK},
 		{userID: 1, method: http.MethodGet, path: "/v1/resources/1/1", code: http.StatusOK},
+		{userID: 1, method: http.MethodPost, path: "/v1/resources/1/1/actions/stop", code: http.StatusOK},
 		// // resources, user:1 - Nope
 		{userID: 1, method: http.MethodGet, path: "/v1/resources/2", code: http.StatusForbidden},
+		{userID: 1, method: http.MethodPost, path: "/v1/resources/2/1/actions/stop", code: http.StatusForbidden},
+		// orgs, user:1 owns their personal org 1 - OK
+		{userID: 1, method: http.MethodGet, path: "/v1/orgs/1", code: http.StatusOK},
+		{userID: 1, method: http.MethodGet, path: "/v1/orgs/1/projects", code: http.StatusOK},
+		{userID: 1, method: http.MethodDelete, path: "/v1/orgs/1", code: http.StatusOK},
+		// orgs, user:1 - Nope. Projects of their own would have no quotas, so only admins create them.
+		{userID: 1, method: http.MethodPost, path: "/v1/orgs", code: http.StatusForbidden},
+		{userID: 1, method: http.MethodPost, path: "/v1/orgs/1/projects", code: http.StatusForbidden},
+		{userID: 1, method: http.MethodGet, path: "/v1/orgs", code: http.StatusForbidden},
+		{userID: 1, method: http.MethodGet, path: "/v1/orgs/3", code: http.StatusForbidden},
+		{userID: 1, method: http.MethodPost, path: "/v1/orgs/3/projects", code: http.StatusForbidden},
+		// projects, user:1 owns project 1 and edits project 3 - OK
+		{userID: 1, method: http.MethodDelete, path: "/v1/projects/1", code: http.StatusOK},
+		{userID: 1, method: http.MethodGet, path: "/v1/projects/3", code: http.StatusOK},
+		{userID: 1, method: http.MethodGet, path: "/v1/projects/3/members", code: http.StatusOK},
+		{userID: 1, method: http.MethodGet, path: "/v1/projects/3/resources", code: http.StatusOK},
+		{userID: 1, method: http.MethodPost, path: "/v1/projects/3/resources", code: http.StatusOK},
+		{userID: 1, method: http.MethodPost, path: "/v1/projects/3/resources/3/actions/stop", code: http.StatusOK},
+		// projects, user:1 - Nope
+		{userID: 1, method: http.MethodDelete, path: "/v1/projects/3", code: http.StatusForbidden},
+		{userID: 1, method: http.MethodPut, path: "/v1/projects/3/members/1", code: http.StatusForbidden},
+		{userID: 1, method: http.MethodPut, path: "/v1/projects/3/quotas/pot.instance.small", code: http.StatusForbidden},
+		{userID: 1, method: http.MethodGet, path: "/v1/projects/2", code: http.StatusForbidden},
+		{userID: 1, method: http.MethodGet, path: "/v1/projects/13", code: http.StatusForbidden},
+		{userID: 1, method: http.MethodPost, path: "/v1/projects/2/resources", code: http.StatusForbidden},
 		// as admin
 		// user
 		{userID: 2, method: http.MethodGet, path: "/v1/users", code: http.StatusOK},
@@ -94,6 +151,13 @@ func TestRBAC(t *testing.T) {
 		{userID: 2, method: http.MethodPatch, path: "/v1/resources/2/1", code: http.StatusOK},
 		{userID: 2, method: http.MethodPost, path: "/v1/resources/1", code: http.StatusOK},
 		{userID: 2, method: http.MethodGet, path: "/v1/resources/1/1", code: http.StatusOK},
+		{userID: 2, method: http.MethodPost, path: "/v1/resources/1/1/actions/restart", code: http.StatusOK},
+		// projects and orgs of other users
+		{userID: 2, method: http.MethodGet, path: "/v1/orgs", code: http.StatusOK},
+		{userID: 2, method: http.MethodDelete, path: "/v1/orgs/1", code: http.StatusOK},
+		{userID: 2, method: http.MethodPost, path: "/v1/orgs", code: http.StatusOK},
+		{userID: 2, method: http.MethodPost, path: "/v1/orgs/1/projects", code: http.StatusOK},
+		{userID: 2, method: http.MethodPut, path: "/v1/projects/1/quotas/pot.instance.small", code: http.StatusOK},
 	}
 
 	for _, tt := range tests {
@@ -103,7 +167,7 @@ func TestRBAC(t *testing.T) {
 
 		t.Log(tt.userID, tt.method, tt.path)
 
-		tokenStr, err := ac.TokenFor(u.Email, u.Password)
+		tokenStr, err := ac.TokenFor(u.Email, "secret") // all sample users share this password
 		w := httptest.NewRecorder()
 		req, _ := http.NewRequest(tt.method, tt.path, nil)
 		req.Header.Add("Token", tokenStr)
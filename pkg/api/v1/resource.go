@@ -2,29 +2,50 @@ package v1
 
 import (
 	"errors"
-	"log"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/yodo-io/ycp/pkg/api"
+	"github.com/yodo-io/ycp/pkg/api/v1/audit"
 	"github.com/yodo-io/ycp/pkg/model"
 )
 
+var (
+	errQuotaExceeded       = api.NewError(api.CodeQuotaExceeded, "quota exceeded")
+	errInvalidResourceType = api.NewError(api.CodeInvalidResourceType, "Invalid resource type")
+	errResourceNotFound    = errors.New("Resource not found")
+)
+
+// Requests rejected because they would exceed the project's quota, by resource type
+var quotaRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "ycp_quota_exceeded_total",
+	Help: "Requests rejected for exceeding a quota, by resource type",
+}, []string{"type"})
+
 type resources struct {
-	db *gorm.DB
+	db      *gorm.DB
+	project projectScope
 }
 
-func (rc *resources) createForUser(c *gin.Context) (int, interface{}) {
-	uid := c.Param("uid")
+// only name and type can be changed, changing the type resizes the resource
+type resourcePatch struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
 
-	// ensure user exists
-	u, err := lookupUser(rc.db, uid)
+func (rc *resources) create(c *gin.Context) (int, interface{}) {
+	// ensure project exists
+	p, uid, err := rc.project(rc.db, c)
 	if err != nil {
-		log.Println(err)
 		return http.StatusInternalServerError, err
 	}
-	if u == nil {
-		return http.StatusNotFound, err
+	if p == nil {
+		return http.StatusNotFound, errProjectNotFound
 	}
 
 	var r model.Resource
@@ -34,100 +55,378 @@ func (rc *resources) createForUser(c *gin.Context) (int, interface{}) {
 	// lookup catalog
 	cat, err := lookupCatalog(rc.db, r.Type)
 	if err != nil {
-		log.Println(err)
 		return http.StatusInternalServerError, err
 	}
 	if cat == nil {
-		return http.StatusBadRequest, errors.New("Invalid resource type")
+		return http.StatusBadRequest, errInvalidResourceType
+	}
+	if err := checkCatalogStatus(c, cat); err != nil {
+		return http.StatusBadRequest, err
 	}
 
-	// check quota
-	ok, err := rc.checkQuota(u.ID, cat.Name)
-	if err != nil {
-		log.Println(err)
-		return http.StatusInternalServerError, err
+	// check quota and create resource in one transaction, so concurrent requests can't both pass the check.
+	// The resource is provisioned in the background, starting out as pending.
+	r.ProjectID, r.UserID = p.ID, uid
+	r.Status, r.StatusMessage = model.ResourcePending, ""
+	var op model.Operation
+	err = model.Transaction(rc.db, func(tx *gorm.DB) error {
+		// the project can't be deleted before the resource is created
+		if err := lockProject(tx, p.ID); err != nil {
+			return err
+		}
+		// nor the catalog item
+		if err := lockCatalog(tx, cat.Name); err != nil {
+			return err
+		}
+		ok, err := checkQuota(tx, p.ID, cat.Name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errQuotaExceeded
+		}
+		if err := tx.Create(&r).Error; err != nil {
+			return err
+		}
+		op = model.NewOperation(r.ID, model.OperationCreate)
+		return tx.Create(&op).Error
+	})
+	if err == errQuotaExceeded {
+		quotaRejections.WithLabelValues(cat.Name).Inc()
+		return http.StatusBadRequest, err
 	}
-	if !ok {
-		return http.StatusBadRequest, errors.New("quota exceeded")
+	if err == errProjectNotFound {
+		return http.StatusNotFound, err
 	}
-
-	// create resource
-	r.UserID = u.ID
-	if err := rc.db.Create(&r).Error; err != nil {
-		log.Println(err)
+	if err == errCatalogItemNotFound {
+		return http.StatusBadRequest, errInvalidResourceType
+	}
+	if err != nil {
 		return http.StatusInternalServerError, err
 	}
+	setOperationLocation(c, &r, &op)
 	return http.StatusCreated, r
 }
 
-func (rc *resources) checkQuota(uid uint, tp string) (bool, error) {
-	q, err := lookupQuota(rc.db, uid, tp)
+// Check if the project can have one more resource of the given type. Must be called in a transaction,
+// the project's quota for the type is locked until the transaction ends.
+func checkQuota(tx *gorm.DB, projectID uint, tp string) (bool, error) {
+	if err := lockQuota(tx, projectID, tp); err != nil {
+		return false, err
+	}
+	qu, err := usageFor(tx, projectID, tp)
 	if err != nil {
 		return false, err
 	}
-	if q == nil {
-		return true, nil
+	return qu.Unlimited || *qu.Remaining > 0, nil
+}
+
+var resourceList = listQuery{
+	sort:   map[string]string{"id": "id", "name": "name", "type": "type", "status": "status"},
+	filter: map[string]string{"name": "name = ?", "type": "type = ?", "status": "status = ?"},
+	order:  "id",
+}
+
+func (rc *resources) list(c *gin.Context) (int, interface{}) {
+	p, _, err := rc.project(rc.db, c)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if p == nil {
+		return http.StatusNotFound, errProjectNotFound
 	}
 
-	var n int
-	if err = rc.db.Model(&model.Resource{}).Where("user_id = ? and type = ?", uid, tp).Count(&n).Error; err != nil {
-		return false, err
+	var rs []*model.Resource
+	// deleted resources are kept for their operations, but no longer listed
+	db := rc.db.Where("project_id = ? and status <> ?", p.ID, model.ResourceDeleted)
+	if code, err := resourceList.find(c, db, &rs); err != nil {
+		return code, err
 	}
-	return n < q.Value, nil
+	return http.StatusOK, rs
 }
 
-func (rc *resources) listForUser(c *gin.Context) (int, interface{}) {
-	userID := c.Param("uid")
+func (rc *resources) get(c *gin.Context) (int, interface{}) {
+	r, err := rc.lookup(c)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if r == nil {
+		return http.StatusNotFound, errResourceNotFound
+	}
+	return http.StatusOK, r
+}
+
+func (rc *resources) update(c *gin.Context) (int, interface{}) {
+	var rp resourcePatch
+	if err := c.ShouldBind(&rp); err != nil {
+		return http.StatusBadRequest, err
+	}
 
-	u, err := lookupUser(rc.db, userID)
+	// lookup, make sure project and rid are correct
+	r, err := rc.lookup(c)
 	if err != nil {
-		log.Println(err)
 		return http.StatusInternalServerError, err
 	}
-	if u == nil {
-		return http.StatusNotFound, err
+	if r == nil {
+		return http.StatusNotFound, errResourceNotFound
+	}
+	if r.Status == model.ResourceDeleting || r.Status == model.ResourceDeleted {
+		return http.StatusConflict, api.Errorf(api.CodeInvalidState, "Resource is %s", r.Status)
 	}
+	audit.Before(c, r)
 
-	var rs []*model.Resource
-	if err := rc.db.Model(&u).Related(&rs).Error; err != nil {
-		log.Println(err)
+	up := gin.H{}
+	if rp.Name != "" {
+		up["name"] = rp.Name
+	}
+	resize := rp.Type != "" && rp.Type != r.Type
+	if resize {
+		if r.Status != model.ResourceRunning {
+			return http.StatusConflict, api.Errorf(api.CodeInvalidState, "Resource is %s, only running resources can be resized", r.Status)
+		}
+		cat, err := lookupCatalog(rc.db, rp.Type)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if cat == nil {
+			return http.StatusBadRequest, errInvalidResourceType
+		}
+		if err := checkCatalogStatus(c, cat); err != nil {
+			return http.StatusBadRequest, err
+		}
+		// providers are chosen by category, resources can't move between them
+		category, err := resourceCategory(rc.db, r)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		if cat.Category != category {
+			return http.StatusBadRequest, api.Errorf(api.CodeInvalidResourceType,
+				"Resource of category %s can't be resized to %s of category %s", category, cat.Name, cat.Category)
+		}
+		up["type"] = cat.Name
+	}
+
+	// quota check and update in one transaction, so concurrent requests can't both pass the check.
+	// The new type counts against the quota right away, the resource is resized in the background.
+	var op *model.Operation
+	err = model.Transaction(rc.db, func(tx *gorm.DB) error {
+		if resize {
+			// the new catalog item can't be deleted before the resource uses it
+			if err := lockCatalog(tx, rp.Type); err != nil {
+				return err
+			}
+			ok, err := checkQuota(tx, r.ProjectID, rp.Type)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return errQuotaExceeded
+			}
+			if err := r.SetStatus(tx, model.ResourceProvisioning, ""); err != nil {
+				return err
+			}
+			rop := model.NewOperation(r.ID, model.OperationResize)
+			if err := tx.Create(&rop).Error; err != nil {
+				return err
+			}
+			op = &rop
+		}
+		return tx.Model(&model.Resource{}).Where("id = ?", r.ID).Updates(up).Error
+	})
+	if err == errQuotaExceeded {
+		quotaRejections.WithLabelValues(rp.Type).Inc()
+		return http.StatusBadRequest, err
+	}
+	if err == errCatalogItemNotFound {
+		return http.StatusBadRequest, errInvalidResourceType
+	}
+	if _, ok := err.(*model.InvalidTransitionError); ok {
+		return http.StatusConflict, api.NewError(api.CodeInvalidState, err.Error())
+	}
+	if err != nil {
 		return http.StatusInternalServerError, err
 	}
-	return http.StatusOK, rs
+	if op != nil {
+		setOperationLocation(c, r, op)
+	}
+
+	// find updated record and return
+	if err := rc.db.First(r, r.ID).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, r
+}
+
+func (rc *resources) delete(c *gin.Context) (int, interface{}) {
+	// lookup, make sure project and rid are correct
+	r, err := rc.lookup(c)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if r == nil {
+		return http.StatusNotFound, errResourceNotFound
+	}
+	audit.Before(c, r)
+
+	// resource is deleted in the background
+	var op model.Operation
+	err = model.Transaction(rc.db, func(tx *gorm.DB) error {
+		if err := r.SetStatus(tx, model.ResourceDeleting, ""); err != nil {
+			return err
+		}
+		op = model.NewOperation(r.ID, model.OperationDelete)
+		return tx.Create(&op).Error
+	})
+	if _, ok := err.(*model.InvalidTransitionError); ok {
+		return http.StatusConflict, api.NewError(api.CodeInvalidState, err.Error())
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	setOperationLocation(c, r, &op)
+	return http.StatusAccepted, r
 }
 
-func (rc *resources) getForUser(c *gin.Context) (int, interface{}) {
-	uid := c.Param("uid")
-	rid := c.Param("rid")
+// Resource actions, by name in the request path: the status a resource needs to be in, the status it
+// moves to right away and the operation moving it on in the background
+var resourceActions = map[string]struct {
+	from   model.ResourceStatus
+	to     model.ResourceStatus
+	action string
+}{
+	"start":   {from: model.ResourceStopped, to: model.ResourceProvisioning, action: model.OperationStart},
+	"stop":    {from: model.ResourceRunning, to: model.ResourceStopping, action: model.OperationStop},
+	"restart": {from: model.ResourceRunning, to: model.ResourceStopping, action: model.OperationRestart},
+}
 
-	var rs []*model.Resource
-	if err := rc.db.Find(&rs, "id = ? and user_id = ?", rid, uid).Error; err != nil {
-		log.Println(err)
+func (rc *resources) action(c *gin.Context) (int, interface{}) {
+	act, ok := resourceActions[c.Param("action")]
+	if !ok {
+		return http.StatusNotFound, fmt.Errorf("Unknown action %s", c.Param("action"))
+	}
+
+	r, err := rc.lookup(c)
+	if err != nil {
 		return http.StatusInternalServerError, err
 	}
-	if len(rs) == 0 {
-		return http.StatusNotFound, errors.New("Resource not found")
+	if r == nil {
+		return http.StatusNotFound, errResourceNotFound
 	}
-	return http.StatusOK, rs[0]
+	if r.Status != act.from {
+		return http.StatusConflict, api.Errorf(api.CodeInvalidState, "Resource is %s, it must be %s to %s", r.Status, act.from, c.Param("action"))
+	}
+	audit.Before(c, r)
+	cat, err := lookupCatalog(rc.db, r.Type)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	// resources free when stopped count against the quota again once started
+	var op model.Operation
+	err = model.Transaction(rc.db, func(tx *gorm.DB) error {
+		if act.action == model.OperationStart && cat != nil && cat.FreeWhenStopped {
+			ok, err := checkQuota(tx, r.ProjectID, r.Type)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return errQuotaExceeded
+			}
+		}
+		if err := r.SetStatus(tx, act.to, ""); err != nil {
+			return err
+		}
+		op = model.NewOperation(r.ID, act.action)
+		return tx.Create(&op).Error
+	})
+	if err == errQuotaExceeded {
+		quotaRejections.WithLabelValues(r.Type).Inc()
+		return http.StatusBadRequest, err
+	}
+	if _, ok := err.(*model.InvalidTransitionError); ok {
+		return http.StatusConflict, api.NewError(api.CodeInvalidState, err.Error())
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	setOperationLocation(c, r, &op)
+	return http.StatusAccepted, r
 }
 
-func (rc *resources) deleteForUser(c *gin.Context) (int, interface{}) {
-	uid := c.Param("uid")
-	rid := c.Param("rid")
+func (rc *resources) listOperations(c *gin.Context) (int, interface{}) {
+	r, err := rc.lookup(c)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if r == nil {
+		return http.StatusNotFound, errResourceNotFound
+	}
 
-	// lookup, make sure rid/uid are correct
-	var rs []*model.Resource
-	if err := rc.db.Find(&rs, "id = ? and user_id = ?", rid, uid).Error; err != nil {
-		log.Println(err)
+	var ops []*model.Operation
+	if err := rc.db.Order("id").Find(&ops, "resource_id = ?", r.ID).Error; err != nil {
 		return http.StatusInternalServerError, err
 	}
-	if len(rs) == 0 {
-		return http.StatusNotFound, errors.New("Resource not found")
+	return http.StatusOK, ops
+}
+
+func (rc *resources) getOperation(c *gin.Context) (int, interface{}) {
+	r, err := rc.lookup(c)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if r == nil {
+		return http.StatusNotFound, errResourceNotFound
 	}
 
-	// delete resource
-	if err := rc.db.Delete(rs[0], "id = ?", rid).Error; err != nil {
+	var ops []*model.Operation
+	if err := rc.db.Find(&ops, "id = ? and resource_id = ?", c.Param("oid"), r.ID).Error; err != nil {
 		return http.StatusInternalServerError, err
 	}
-	return http.StatusOK, rs[0]
+	if len(ops) == 0 {
+		return http.StatusNotFound, errors.New("Operation not found")
+	}
+	return http.StatusOK, ops[0]
+}
+
+// Tell clients where to poll for the outcome of an operation on a resource
+func setOperationLocation(c *gin.Context, r *model.Resource, op *model.Operation) {
+	// strip everything from the resource id on, e.g. /v1/projects/1/resources/2/actions/stop -> /v1/projects/1/resources
+	base := c.Request.URL.Path
+	if rid := c.Param("rid"); rid != "" {
+		base = base[:strings.LastIndex(base, "/"+rid)]
+	}
+	c.Header("Operation-Location", fmt.Sprintf("%s/%d/operations/%d", base, r.ID, op.ID))
+}
+
+// Find the resource given by the request, returns nil if either its project or id is wrong
+func (rc *resources) lookup(c *gin.Context) (*model.Resource, error) {
+	p, _, err := rc.project(rc.db, c)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	return lookupResource(rc.db, p.ID, c.Param("rid"))
+}
+
+// Category of a resource's catalog item, derived from its type if the item is gone
+func resourceCategory(db *gorm.DB, r *model.Resource) (string, error) {
+	cat, err := lookupCatalog(db, r.Type)
+	if err != nil {
+		return "", err
+	}
+	if cat == nil || cat.Category == "" {
+		return model.CategoryOf(r.Type), nil
+	}
+	return cat.Category, nil
+}
+
+// Find a resource by project and resource id, returns nil if either is wrong
+func lookupResource(db *gorm.DB, projectID uint, rid string) (*model.Resource, error) {
+	var rs []*model.Resource
+	if err := db.Find(&rs, "id = ? and project_id = ?", rid, projectID).Error; err != nil {
+		return nil, err
+	}
+	if len(rs) == 0 {
+		return nil, nil
+	}
+	return rs[0], nil
 }
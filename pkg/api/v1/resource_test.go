@@ -2,13 +2,23 @@ package v1
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"regexp"
+	"runtime"
+	"sync"
 	"testing"
 
+	"github.com/jinzhu/gorm"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/api"
 	"github.com/yodo-io/ycp/pkg/api/test"
 	"github.com/yodo-io/ycp/pkg/model"
+	"github.com/yodo-io/ycp/pkg/provision"
 )
 
 func TestCreateResource(t *testing.T) {
@@ -48,10 +58,82 @@ func TestCreateResource(t *testing.T) {
 			assert.Equal(t, tt.userID, res.UserID)
 			assert.Equal(t, tt.in.Name, res.Name)
 			assert.Equal(t, tt.in.Type, res.Type)
+			assert.Equal(t, model.ResourcePending, res.Status)
+			assert.NotEmpty(t, w.Header().Get("Operation-Location"))
 		}()
 	}
 }
 
+func TestResourceLifecycle(t *testing.T) {
+	r, db, td := mustInitRouterDB(true)
+	defer td()
+	wk := provision.NewWorker(db, provision.Nop())
+
+	// returns the resource and the operation found at the location given in the response
+	poll := func(w *httptest.ResponseRecorder) (model.Resource, model.Operation) {
+		var res model.Resource
+		var op model.Operation
+		loc := w.Header().Get("Operation-Location")
+		if !assert.NotEmpty(t, loc) {
+			return res, op
+		}
+		ow := test.MustRecord(t, r, http.MethodGet, loc)
+		if assert.Equal(t, http.StatusOK, ow.Code) {
+			test.MustBind(t, ow, &op)
+		}
+		rw := test.MustRecord(t, r, http.MethodGet, fmt.Sprintf("/resources/2/%d", op.ResourceID))
+		if assert.Equal(t, http.StatusOK, rw.Code) {
+			test.MustBind(t, rw, &res)
+		}
+		return res, op
+	}
+
+	in := model.Resource{Name: "a small cooking pot", Type: "pot.instance.small"}
+	w := test.MustRecord(t, r, http.MethodPost, "/resources/2", in)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		return
+	}
+	res, op := poll(w)
+	assert.Equal(t, model.ResourcePending, res.Status)
+	assert.Equal(t, model.OperationCreate, op.Action)
+	assert.Equal(t, model.OperationPending, op.Status)
+
+	if _, err := wk.ProcessPending(); err != nil {
+		t.Fatal(err)
+	}
+	res, op = poll(w)
+	assert.Equal(t, model.ResourceRunning, res.Status)
+	assert.Equal(t, model.OperationSucceeded, op.Status)
+
+	w = test.MustRecord(t, r, http.MethodDelete, fmt.Sprintf("/resources/2/%d", res.ID))
+	if !assert.Equal(t, http.StatusAccepted, w.Code) {
+		return
+	}
+	if _, err := wk.ProcessPending(); err != nil {
+		t.Fatal(err)
+	}
+	res, op = poll(w)
+	assert.Equal(t, model.ResourceDeleted, res.Status)
+	assert.Equal(t, model.OperationDelete, op.Action)
+	assert.Equal(t, model.OperationSucceeded, op.Status)
+
+	// deleted resources are no longer listed, but their operations are kept
+	w = test.MustRecord(t, r, http.MethodGet, "/resources/2")
+	var rs []model.Resource
+	test.MustBind(t, w, &rs)
+	for _, rr := range rs {
+		assert.NotEqual(t, res.ID, rr.ID)
+	}
+	w = test.MustRecord(t, r, http.MethodGet, fmt.Sprintf("/resources/2/%d/operations", res.ID))
+	var ops []model.Operation
+	test.MustBind(t, w, &ops)
+	assert.Len(t, ops, 2)
+
+	// operations of other resources can't be looked up through this one
+	w = test.MustRecord(t, r, http.MethodGet, fmt.Sprintf("/resources/2/3/operations/%d", op.ID))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
 func TestResourceQuotaLimit(t *testing.T) {
 	r, td := mustInitRouter(true)
 	defer td()
@@ -71,16 +153,140 @@ func TestResourceQuotaLimit(t *testing.T) {
 		}
 	}
 
+	rejections := testutil.ToFloat64(quotaRejections.WithLabelValues(in.Type))
 	w := test.MustRecord(t, r, http.MethodPost, fmt.Sprintf("/resources/%d", userID), in)
 	if !assert.Equal(t, http.StatusBadRequest, w.Code) {
 		return
 	}
+	assert.Equal(t, rejections+1, testutil.ToFloat64(quotaRejections.WithLabelValues(in.Type)))
 
-	var e errorResponse
+	var e api.ErrorResponse
 	test.MustBind(t, w, &e)
+	assert.Equal(t, api.CodeQuotaExceeded, e.Code)
 	assert.Regexp(t, regexp.MustCompile("quota exceeded"), e.Error)
 }
 
+func TestConcurrentResourceQuotaLimit(t *testing.T) {
+	// make sure requests actually run in parallel, even on a single CPU
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+
+	dir, err := ioutil.TempDir("", "ycp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// a file DB opened without model.Open uses several connections, so creates really run in parallel
+	fileDB, err := gorm.Open("sqlite3", filepath.Join(dir, "ycp.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fileDB.Close()
+	fileDB.LogMode(false)
+	if err := model.Setup(fileDB, true); err != nil {
+		t.Fatal(err)
+	}
+
+	memDB := model.MustInitTestDB(true)
+	defer memDB.Close()
+
+	tests := []struct {
+		name string
+		db   *gorm.DB
+	}{
+		{name: "memory", db: memDB},
+		{name: "file", db: fileDB},
+	}
+
+	limit := 10
+	parallel := 30
+	path := "/resources/1"
+	in := model.Resource{
+		Name: "a small cooking pot",
+		Type: "pot.instance.small",
+	}
+
+	for _, tt := range tests {
+		r := test.NewRouter()
+		Routes(&r.RouterGroup, tt.db)
+
+		// fire all requests at once
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		codes := make(chan int, parallel)
+		for i := 0; i < parallel; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				codes <- test.MustRecord(t, r, http.MethodPost, path, in).Code
+			}()
+		}
+		close(start)
+		wg.Wait()
+		close(codes)
+
+		count := map[int]int{}
+		for c := range codes {
+			count[c]++
+		}
+		assert.Equal(t, limit, count[http.StatusCreated], tt.name)
+		assert.Equal(t, parallel-limit, count[http.StatusBadRequest], tt.name)
+
+		var n int
+		if err := tt.db.Model(&model.Resource{}).Where("user_id = ? and type = ?", 1, in.Type).Count(&n).Error; err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, limit, n, tt.name)
+	}
+}
+
+func TestCreateResourceCatalogStatus(t *testing.T) {
+	r, db, td := mustInitRouterDB(true)
+	defer td()
+
+	set := map[string]model.CatalogStatus{
+		"pot.instance.large":  model.CatalogDeprecated,
+		"pot.instance.xlarge": model.CatalogRetired,
+	}
+	for name, st := range set {
+		if err := db.Model(&model.Catalog{}).Where("name = ?", name).Update("status", st).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		tp      string
+		code    int
+		warning bool
+	}{
+		{tp: "pot.instance.small", code: http.StatusCreated},
+		{tp: "pot.instance.large", code: http.StatusCreated, warning: true},
+		{tp: "pot.instance.xlarge", code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		in := model.Resource{Name: "a pot", Type: tt.tp}
+		w := test.MustRecord(t, r, http.MethodPost, "/resources/2", in)
+		assert.Equal(t, tt.code, w.Code, tt.tp)
+		if tt.warning {
+			assert.Contains(t, w.Header().Get("Warning"), "deprecated", tt.tp)
+		} else {
+			assert.Empty(t, w.Header().Get("Warning"), tt.tp)
+		}
+
+		// resizing a pot into the type works the same
+		w = test.MustRecord(t, r, http.MethodPatch, "/resources/1/1", resourcePatch{Type: tt.tp})
+		mustProvision(t, db)
+		if tt.code == http.StatusCreated {
+			assert.Equal(t, http.StatusOK, w.Code, tt.tp)
+		} else {
+			assert.Equal(t, tt.code, w.Code, tt.tp)
+		}
+		assert.Equal(t, tt.warning, w.Header().Get("Warning") != "", tt.tp)
+	}
+}
+
 func TestResourceValidation(t *testing.T) {
 
 	tests := []struct {
@@ -201,17 +407,20 @@ func TestDeleteResourceForUser(t *testing.T) {
 		userID uint
 		code   int
 	}{
-		{userID: 1, id: 1, code: http.StatusOK},
-		{userID: 2, id: 3, code: http.StatusOK},
+		{userID: 1, id: 1, code: http.StatusAccepted},
+		{userID: 2, id: 3, code: http.StatusAccepted},
 		{userID: 1, id: 3, code: http.StatusNotFound},
+		// deletion is in progress already
+		{userID: 1, id: 1, code: http.StatusConflict},
 	}
 
 	for _, tt := range tests {
-		w := test.MustRecord(t, r, http.MethodDelete, fmt.Sprintf("/resources/%d/%d", tt.userID, tt.id))
+		path := fmt.Sprintf("/resources/%d/%d", tt.userID, tt.id)
+		w := test.MustRecord(t, r, http.MethodDelete, path)
 		if !assert.Equal(t, tt.code, w.Code) {
 			continue
 		}
-		if w.Code != http.StatusOK {
+		if w.Code != http.StatusAccepted {
 			continue
 		}
 
@@ -222,33 +431,79 @@ func TestDeleteResourceForUser(t *testing.T) {
 		assert.Equal(t, tt.userID, rc.UserID)
 		assert.NotEmpty(t, rc.Name)
 		assert.NotEmpty(t, rc.Type)
-
-		// test if resource was really deleted
-		w = test.MustRecord(t, r, http.MethodGet, fmt.Sprintf("/resources/%d/%d", tt.userID, tt.id))
-		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, model.ResourceDeleting, rc.Status)
+		assert.Regexp(t, "^"+path+"/operations/\\d+$", w.Header().Get("Operation-Location"))
+
+		// test if resource is being deleted
+		w = test.MustRecord(t, r, http.MethodGet, path)
+		if assert.Equal(t, http.StatusOK, w.Code) {
+			test.MustBind(t, w, &rc)
+			assert.Equal(t, model.ResourceDeleting, rc.Status)
+		}
 	}
 }
 
 func TestUpdateResource(t *testing.T) {
-	r, td := mustInitRouter(true)
+	r, db, td := mustInitRouterDB(true)
 	defer td()
 
 	tests := []struct {
 		id     uint
 		userID uint
-		in     model.Resource
+		in     resourcePatch
+		out    model.Resource
 		code   int
 	}{
+		// rename
+		{
+			id:     1,
+			userID: 1,
+			in:     resourcePatch{Name: "my little cooking pot"},
+			out:    model.Resource{Name: "my little cooking pot", Type: "pot.instance.large"},
+			code:   http.StatusOK,
+		},
+		// resize
+		{
+			id:     1,
+			userID: 1,
+			in:     resourcePatch{Type: "pot.instance.xlarge"},
+			out:    model.Resource{Name: "my little cooking pot", Type: "pot.instance.xlarge"},
+			code:   http.StatusOK,
+		},
+		// rename and resize
+		{
+			id:     2,
+			userID: 1,
+			in:     resourcePatch{Name: "rice cooker", Type: "pot.instance.small"},
+			out:    model.Resource{Name: "rice cooker", Type: "pot.instance.small"},
+			code:   http.StatusOK,
+		},
+		// invalid type
 		{
 			id:     1,
 			userID: 1,
-			in:     model.Resource{Name: "my little cooking pot"},
-			code:   http.StatusNotImplemented, // not supported yet
+			in:     resourcePatch{Type: "foo.bar.baz"},
+			code:   http.StatusBadRequest,
+		},
+		// wrong user
+		{
+			id:     3,
+			userID: 1,
+			in:     resourcePatch{Name: "stolen pan"},
+			code:   http.StatusNotFound,
+		},
+		// non-existing resource
+		{
+			id:     30,
+			userID: 1,
+			in:     resourcePatch{Name: "pot"},
+			code:   http.StatusNotFound,
 		},
 	}
 
 	for _, tt := range tests {
 		w := test.MustRecord(t, r, http.MethodPatch, fmt.Sprintf("/resources/%d/%d", tt.userID, tt.id), tt.in)
+		mustProvision(t, db)
 		if !assert.Equal(t, tt.code, w.Code) {
 			continue
 		}
@@ -258,10 +513,199 @@ func TestUpdateResource(t *testing.T) {
 
 		var rc model.Resource
 		test.MustBind(t, w, &rc)
-		assert.NotEmpty(t, rc)
 		assert.Equal(t, tt.id, rc.ID)
 		assert.Equal(t, tt.userID, rc.UserID)
-		assert.NotEmpty(t, rc.Name)
-		assert.NotEmpty(t, rc.Type)
+		assert.Equal(t, tt.out.Name, rc.Name)
+		assert.Equal(t, tt.out.Type, rc.Type)
+	}
+}
+
+func TestResizeOtherCategory(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	w := test.MustRecord(t, r, http.MethodPatch, "/resources/1/1", resourcePatch{Type: "pan.instance.wok"})
+	if !assert.Equal(t, http.StatusBadRequest, w.Code) {
+		return
+	}
+	var e api.ErrorResponse
+	test.MustBind(t, w, &e)
+	assert.Equal(t, api.CodeInvalidResourceType, e.Code)
+
+	w = test.MustRecord(t, r, http.MethodGet, "/resources/1/1")
+	var rc model.Resource
+	test.MustBind(t, w, &rc)
+	assert.Equal(t, "pot.instance.large", rc.Type)
+	assert.Equal(t, model.ResourceRunning, rc.Status)
+}
+
+func TestResizeInBackground(t *testing.T) {
+	r, db, td := mustInitRouterDB(true)
+	defer td()
+
+	w := test.MustRecord(t, r, http.MethodPatch, "/resources/1/1", resourcePatch{Type: "pot.instance.xlarge"})
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		return
+	}
+	var rc model.Resource
+	test.MustBind(t, w, &rc)
+	assert.Equal(t, "pot.instance.xlarge", rc.Type)
+	assert.Equal(t, model.ResourceProvisioning, rc.Status)
+	assert.NotEmpty(t, w.Header().Get("Operation-Location"))
+
+	// can't resize again until done, renaming is fine
+	w = test.MustRecord(t, r, http.MethodPatch, "/resources/1/1", resourcePatch{Type: "pot.instance.small"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = test.MustRecord(t, r, http.MethodPatch, "/resources/1/1", resourcePatch{Name: "stock pot"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Operation-Location"))
+
+	mustProvision(t, db)
+	w = test.MustRecord(t, r, http.MethodGet, "/resources/1/1")
+	test.MustBind(t, w, &rc)
+	assert.Equal(t, model.ResourceRunning, rc.Status)
+	assert.Equal(t, "stock pot", rc.Name)
+}
+
+func TestResizeQuotaLimit(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	// user 1 has a quota of 10 small pots
+	limit := 10
+	userID := 1
+
+	for i := 0; i < limit; i++ {
+		in := model.Resource{Name: "a small cooking pot", Type: "pot.instance.small"}
+		w := test.MustRecord(t, r, http.MethodPost, fmt.Sprintf("/resources/%d", userID), in)
+		if !assert.Equal(t, http.StatusCreated, w.Code) {
+			return
+		}
+	}
+
+	// resizing a large pot into a small one would exceed quota
+	in := resourcePatch{Type: "pot.instance.small"}
+	w := test.MustRecord(t, r, http.MethodPatch, fmt.Sprintf("/resources/%d/1", userID), in)
+	if !assert.Equal(t, http.StatusBadRequest, w.Code) {
+		return
+	}
+	var e api.ErrorResponse
+	test.MustBind(t, w, &e)
+	assert.Equal(t, api.CodeQuotaExceeded, e.Code)
+	assert.Regexp(t, regexp.MustCompile("quota exceeded"), e.Error)
+
+	// resource is unchanged
+	w = test.MustRecord(t, r, http.MethodGet, fmt.Sprintf("/resources/%d/1", userID))
+	var rc model.Resource
+	test.MustBind(t, w, &rc)
+	assert.Equal(t, "pot.instance.large", rc.Type)
+
+	// renaming a resource already at its quota limit is fine, it doesn't change the count
+	in = resourcePatch{Name: "still small", Type: "pot.instance.small"}
+	w = test.MustRecord(t, r, http.MethodPatch, fmt.Sprintf("/resources/%d/5", userID), in)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestResourceActions(t *testing.T) {
+	r, db, td := mustInitRouterDB(true)
+	defer td()
+
+	// resource 3 is a running wok owned by user 2
+	tests := []struct {
+		action string
+		code   int
+		status model.ResourceStatus
+		done   model.ResourceStatus
+	}{
+		{action: "explode", code: http.StatusNotFound},
+		{action: "start", code: http.StatusConflict},
+		{action: "stop", code: http.StatusAccepted, status: model.ResourceStopping, done: model.ResourceStopped},
+		{action: "stop", code: http.StatusConflict},
+		{action: "restart", code: http.StatusConflict},
+		{action: "start", code: http.StatusAccepted, status: model.ResourceProvisioning, done: model.ResourceRunning},
+		{action: "restart", code: http.StatusAccepted, status: model.ResourceStopping, done: model.ResourceRunning},
+	}
+
+	for _, tt := range tests {
+		w := test.MustRecord(t, r, http.MethodPost, "/resources/2/3/actions/"+tt.action)
+		if !assert.Equal(t, tt.code, w.Code, tt.action) || w.Code != http.StatusAccepted {
+			continue
+		}
+		var rc model.Resource
+		test.MustBind(t, w, &rc)
+		assert.Equal(t, tt.status, rc.Status, tt.action)
+		assert.Regexp(t, `^/resources/2/3/operations/\d+$`, w.Header().Get("Operation-Location"))
+
+		mustProvision(t, db)
+		w = test.MustRecord(t, r, http.MethodGet, "/resources/2/3")
+		test.MustBind(t, w, &rc)
+		assert.Equal(t, tt.done, rc.Status, tt.action)
+	}
+
+	// actions are kept in the resource's history
+	w := test.MustRecord(t, r, http.MethodGet, "/resources/2/3/operations")
+	var ops []model.Operation
+	test.MustBind(t, w, &ops)
+	var actions []string
+	for _, op := range ops {
+		actions = append(actions, op.Action)
+	}
+	assert.Equal(t, []string{model.OperationStop, model.OperationStart, model.OperationRestart}, actions)
+
+	// resource belongs to user 2
+	w = test.MustRecord(t, r, http.MethodPost, "/resources/1/3/actions/stop")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestStoppedResourceQuota(t *testing.T) {
+	r, db, td := mustInitRouterDB(true)
+	defer td()
+
+	// user 1 has a quota of 10 small pots, which are free when stopped
+	in := model.Resource{Name: "a small cooking pot", Type: "pot.instance.small"}
+	var rc model.Resource
+	for i := 0; i < 10; i++ {
+		w := test.MustRecord(t, r, http.MethodPost, "/resources/1", in)
+		if !assert.Equal(t, http.StatusCreated, w.Code) {
+			return
+		}
+		test.MustBind(t, w, &rc)
+	}
+	mustProvision(t, db)
+
+	// stopping one makes room for another
+	w := test.MustRecord(t, r, http.MethodPost, fmt.Sprintf("/resources/1/%d/actions/stop", rc.ID))
+	if !assert.Equal(t, http.StatusAccepted, w.Code) {
+		return
+	}
+	mustProvision(t, db)
+	w = test.MustRecord(t, r, http.MethodPost, "/resources/1", in)
+	if !assert.Equal(t, http.StatusCreated, w.Code) {
+		return
+	}
+	mustProvision(t, db)
+
+	// now it can't be started again
+	w = test.MustRecord(t, r, http.MethodPost, fmt.Sprintf("/resources/1/%d/actions/start", rc.ID))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// usage across users agrees
+	w = test.MustRecord(t, r, http.MethodGet, "/usage")
+	var res []quotaUsage
+	test.MustBind(t, w, &res)
+	if assert.Len(t, res, 1) {
+		assert.Equal(t, 10, res[0].Used)
+	}
+
+	// stopped woks still count, resource 3 is user 2's wok
+	w = test.MustRecord(t, r, http.MethodPost, "/resources/2/3/actions/stop")
+	if !assert.Equal(t, http.StatusAccepted, w.Code) {
+		return
+	}
+	mustProvision(t, db)
+	qu, err := usageFor(db, 2, "pan.instance.wok")
+	if err != nil {
+		t.Fatal(err)
 	}
+	assert.Equal(t, 1, qu.Used)
 }
@@ -0,0 +1,179 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+	"github.com/yodo-io/ycp/pkg/api"
+	"github.com/yodo-io/ycp/pkg/api/v1/audit"
+	"github.com/yodo-io/ycp/pkg/api/v1/rbac"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+type roles struct {
+	db *gorm.DB
+}
+
+// description is a pointer to tell "not set" from "set to empty", rules are replaced if set
+type rolePatch struct {
+	Description *string          `json:"description"`
+	Rules       []model.RBACRule `json:"rules"`
+}
+
+func (rc *roles) list(c *gin.Context) (int, interface{}) {
+	var rs []*model.RoleSpec
+	if err := rc.db.Preload("Rules").Find(&rs).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, rs
+}
+
+func (rc *roles) get(c *gin.Context) (int, interface{}) {
+	r, err := lookupRole(rc.db, c.Param("name"))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if r == nil {
+		return http.StatusNotFound, errors.New("Role not found")
+	}
+	return http.StatusOK, r
+}
+
+func (rc *roles) create(c *gin.Context) (int, interface{}) {
+	var r model.RoleSpec
+	if err := c.ShouldBind(&r); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if !r.Name.Valid() {
+		return http.StatusBadRequest, fmt.Errorf("Invalid role name: %s", r.Name)
+	}
+	if err := validateRules(r.Rules); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	// ensure role doesn't exist yet
+	ex, err := lookupRole(rc.db, string(r.Name))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if ex != nil {
+		return http.StatusConflict, api.NewError(api.CodeAlreadyExists, "Role already exists")
+	}
+
+	// rules are created along with the role
+	for i := range r.Rules {
+		r.Rules[i].ID = 0
+		r.Rules[i].Role = r.Name
+	}
+	if err := rc.db.Create(&r).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusCreated, r
+}
+
+func (rc *roles) update(c *gin.Context) (int, interface{}) {
+	r, err := lookupRole(rc.db, c.Param("name"))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if r == nil {
+		return http.StatusNotFound, errors.New("Role not found")
+	}
+	audit.Before(c, r)
+
+	var rp rolePatch
+	if err := c.ShouldBind(&rp); err != nil {
+		return http.StatusBadRequest, err
+	}
+	if err := validateRules(rp.Rules); err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	err = model.Transaction(rc.db, func(tx *gorm.DB) error {
+		if rp.Description != nil {
+			if err := tx.Model(r).Update("description", *rp.Description).Error; err != nil {
+				return err
+			}
+		}
+		if rp.Rules == nil {
+			return nil
+		}
+		if err := tx.Delete(&model.RBACRule{}, "role = ?", r.Name).Error; err != nil {
+			return err
+		}
+		for _, rule := range rp.Rules {
+			rule.ID = 0
+			rule.Role = r.Name
+			if err := tx.Create(&rule).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	// find updated record and return
+	if r, err = lookupRole(rc.db, string(r.Name)); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, r
+}
+
+func (rc *roles) delete(c *gin.Context) (int, interface{}) {
+	r, err := lookupRole(rc.db, c.Param("name"))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if r == nil {
+		return http.StatusNotFound, errors.New("Role not found")
+	}
+
+	// roles still assigned to users can't be deleted
+	var n int
+	if err := rc.db.Model(&model.UserRole{}).Where("role = ?", r.Name).Count(&n).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if n > 0 {
+		return http.StatusConflict, fmt.Errorf("Role is assigned to %d user(s)", n)
+	}
+
+	err = model.Transaction(rc.db, func(tx *gorm.DB) error {
+		if err := tx.Delete(&model.RBACRule{}, "role = ?", r.Name).Error; err != nil {
+			return err
+		}
+		return tx.Delete(r, "name = ?", r.Name).Error
+	})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, r
+}
+
+// Make sure rules are accepted by the RBAC policy, otherwise reloading it would fail
+func validateRules(rules []model.RBACRule) error {
+	for _, r := range rules {
+		if r.Path == "" || r.Action == "" {
+			return errors.New("Rule path and action are required")
+		}
+		if err := rbac.ValidateRule(rbac.Rule{Path: r.Path, Action: r.Action}); err != nil {
+			return fmt.Errorf("Invalid rule: %v", err)
+		}
+	}
+	return nil
+}
+
+func lookupRole(db *gorm.DB, name string) (*model.RoleSpec, error) {
+	var r []*model.RoleSpec
+	if err := db.Preload("Rules").Find(&r, "name = ?", name).Error; err != nil {
+		return nil, err
+	}
+	if len(r) == 0 {
+		return nil, nil
+	}
+	return r[0], nil
+}
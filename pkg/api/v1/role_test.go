@@ -0,0 +1,135 @@
+package v1
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/api/test"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+func TestGetRoles(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	w := test.MustRecord(t, r, http.MethodGet, "/roles")
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		return
+	}
+
+	var res []model.RoleSpec
+	test.MustBind(t, w, &res)
+
+	names := map[model.Role]bool{}
+	for _, rs := range res {
+		names[rs.Name] = true
+		assert.NotEmpty(t, rs.Rules)
+	}
+	assert.True(t, names[model.RoleAdmin])
+	assert.True(t, names[model.RoleUser])
+	assert.True(t, names[model.RoleAuditor])
+}
+
+func TestCreateRole(t *testing.T) {
+	r, td := mustInitAdminRouter(true)
+	defer td()
+
+	tests := []struct {
+		in   gin.H
+		code int
+	}{
+		{
+			in: gin.H{"name": "billing", "description": "Billing", "rules": []gin.H{
+				{"path": `/v\d+/quotas/.*`, "action": "GET"},
+			}},
+			code: http.StatusCreated,
+		},
+		// exists already
+		{in: gin.H{"name": "billing"}, code: http.StatusConflict},
+		// invalid name
+		{in: gin.H{"name": "Billing Team"}, code: http.StatusBadRequest},
+		// no name
+		{in: gin.H{"description": "nameless"}, code: http.StatusBadRequest},
+		// invalid rules
+		{in: gin.H{"name": "support", "rules": []gin.H{{"path": "{{.Foo}}", "action": ".*"}}}, code: http.StatusBadRequest},
+		{in: gin.H{"name": "support", "rules": []gin.H{{"path": "/v1/users", "action": "GET("}}}, code: http.StatusBadRequest},
+		{in: gin.H{"name": "support", "rules": []gin.H{{"path": "/v1/users"}}}, code: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		w := test.MustRecord(t, r, http.MethodPost, "/roles", tt.in)
+		if !assert.Equal(t, tt.code, w.Code, "%v", tt.in) {
+			continue
+		}
+		if w.Code != http.StatusCreated {
+			continue
+		}
+
+		var res model.RoleSpec
+		test.MustBind(t, w, &res)
+		assert.Equal(t, tt.in["name"], string(res.Name))
+		if assert.Len(t, res.Rules, 1) {
+			assert.Equal(t, res.Name, res.Rules[0].Role)
+			assert.NotZero(t, res.Rules[0].ID)
+		}
+	}
+
+	// can assign new role to users now
+	w := test.MustRecord(t, r, http.MethodPatch, "/users/1", gin.H{"roles": []string{"user", "billing"}})
+	if assert.Equal(t, http.StatusOK, w.Code) {
+		var u model.User
+		test.MustBind(t, w, &u)
+		assert.Equal(t, []model.Role{"billing", "user"}, u.Roles)
+	}
+}
+
+func TestUpdateRole(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	in := gin.H{"description": "Read-only", "rules": []gin.H{
+		{"path": `/v\d+/catalog`, "action": "GET"},
+		{"path": `/v\d+/users`, "action": "GET"},
+	}}
+	w := test.MustRecord(t, r, http.MethodPatch, "/roles/auditor", in)
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		return
+	}
+	var res model.RoleSpec
+	test.MustBind(t, w, &res)
+	assert.Equal(t, "Read-only", res.Description)
+	assert.Len(t, res.Rules, 2)
+
+	// description only, rules are kept
+	w = test.MustRecord(t, r, http.MethodPatch, "/roles/auditor", gin.H{"description": "Auditors"})
+	if assert.Equal(t, http.StatusOK, w.Code) {
+		test.MustBind(t, w, &res)
+		assert.Equal(t, "Auditors", res.Description)
+		assert.Len(t, res.Rules, 2)
+	}
+
+	w = test.MustRecord(t, r, http.MethodPatch, "/roles/nope", in)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestDeleteRole(t *testing.T) {
+	r, td := mustInitRouter(true)
+	defer td()
+
+	tests := []struct {
+		name string
+		code int
+	}{
+		{name: "auditor", code: http.StatusOK},
+		{name: "auditor", code: http.StatusNotFound},
+		// still assigned to sample users
+		{name: "user", code: http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		w := test.MustRecord(t, r, http.MethodDelete, "/roles/"+tt.name)
+		assert.Equal(t, tt.code, w.Code, tt.name)
+	}
+}
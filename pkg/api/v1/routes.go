@@ -6,12 +6,10 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
+	"github.com/yodo-io/ycp/pkg/api"
+	"github.com/yodo-io/ycp/pkg/api/v1/audit"
 )
 
-type errorResponse struct {
-	Error string `json:"error"`
-}
-
 // Routes registers all routes implemented by this module with the provided `RouterGroup`
 // Accepting a `RouterGroup` reference makes it possible for client code to use the HTTP API
 // implemented by this module along with other modules.
@@ -24,42 +22,100 @@ func Routes(rg *gin.RouterGroup, db *gorm.DB) {
 	rg.POST("/users", h(uc.create))
 	rg.PATCH("/users/:id", h(uc.update))
 	rg.DELETE("/users/:id", h(uc.delete))
+	rg.GET("/users/:id/memberships", h(uc.memberships))
+	// role api
+	roc := &roles{db}
+	rg.GET("/roles", h(roc.list))
+	rg.GET("/roles/:name", h(roc.get))
+	rg.POST("/roles", h(roc.create))
+	rg.PATCH("/roles/:name", h(roc.update))
+	rg.DELETE("/roles/:name", h(roc.delete))
+
+	// organization api
+	oc := &orgs{db}
+	rg.GET("/orgs", h(oc.list))
+	rg.GET("/orgs/:oid", h(oc.get))
+	rg.POST("/orgs", h(oc.create))
+	rg.PATCH("/orgs/:oid", h(oc.update))
+	rg.DELETE("/orgs/:oid", h(oc.delete))
+	rg.GET("/orgs/:oid/members", h(oc.listMembers))
+	rg.PUT("/orgs/:oid/members/:uid", h(oc.putMember))
+	rg.DELETE("/orgs/:oid/members/:uid", h(oc.deleteMember))
+	rg.GET("/orgs/:oid/projects", h(oc.listProjects))
+	rg.POST("/orgs/:oid/projects", h(oc.createProject))
 
-	// resource api
-	rc := &resources{db}
-	rg.GET("/resources/:uid", h(rc.listForUser))
-	rg.GET("/resources/:uid/:rid", h(rc.getForUser))
-	rg.POST("/resources/:uid", h(rc.createForUser))
-	rg.PATCH("/resources/:uid/:rid", h(notImplemented)) // TODO
-	rg.DELETE("/resources/:uid/:rid", h(rc.deleteForUser))
+	// project api, resources and quotas of a project are registered below
+	pc := &projects{db}
+	rg.GET("/projects", h(pc.list))
+	rg.GET("/projects/:pid", h(pc.get))
+	rg.PATCH("/projects/:pid", h(pc.update))
+	rg.DELETE("/projects/:pid", h(pc.delete))
+	rg.GET("/projects/:pid/members", h(pc.listMembers))
+	rg.PUT("/projects/:pid/members/:uid", h(pc.putMember))
+	rg.DELETE("/projects/:pid/members/:uid", h(pc.deleteMember))
 
-	// catalog api - can only browse for now
+	// resource api, /resources/:uid acts on the user's personal project
+	resourceRoutes(rg.Group("/projects/:pid/resources"), &resources{db, projectParam})
+	resourceRoutes(rg.Group("/resources/:uid"), &resources{db, personalProject})
+
+	// catalog api
 	cc := &catalog{db}
 	rg.GET("/catalog", h(cc.list))
+	rg.GET("/catalog/:name", h(cc.get))
+	rg.POST("/catalog", h(cc.create))
+	rg.PATCH("/catalog/:name", h(cc.update))
+	rg.DELETE("/catalog/:name", h(cc.delete))
+
+	// quota api, /quotas/:uid acts on the user's personal project
+	quotaRoutes(rg.Group("/projects/:pid/quotas"), &quotas{db, projectParam})
+	quotaRoutes(rg.Group("/quotas/:uid"), &quotas{db, personalProject})
+	qc := &quotas{db, projectParam}
+	rg.GET("/usage", h(qc.usage))
+
+	// audit api
+	alc := &auditLog{db}
+	rg.GET("/audit", h(alc.list))
+}
+
+// Routes of resources in a project, relative to the project's resources
+func resourceRoutes(rg *gin.RouterGroup, rc *resources) {
+	rg.GET("", h(rc.list))
+	rg.GET("/:rid", h(rc.get))
+	rg.POST("", h(rc.create))
+	rg.PATCH("/:rid", h(rc.update))
+	rg.DELETE("/:rid", h(rc.delete))
+	rg.POST("/:rid/actions/:action", h(rc.action))
+	rg.GET("/:rid/operations", h(rc.listOperations))
+	rg.GET("/:rid/operations/:oid", h(rc.getOperation))
+}
 
-	// quota api
-	qc := &quotas{db}
-	rg.GET("/quotas/:uid", h(qc.listForUser))
-	rg.POST("/quotas/:uid", h(qc.createForUser))
-	rg.PATCH("/quotas/:uid/:qid", h(qc.updateForUser))
-	rg.DELETE("/quotas/:uid/:qid", h(qc.deleteForUser))
+// Routes of quotas of a project, relative to the project's quotas
+func quotaRoutes(rg *gin.RouterGroup, qc *quotas) {
+	rg.GET("", h(qc.list))
+	rg.GET("/usage", h(qc.projectUsage))
+	rg.POST("", h(qc.create))
+	rg.PATCH("/:qid", h(qc.update))
+	rg.PUT("/:type", h(qc.put))
+	rg.DELETE("/:qid", h(qc.delete))
 }
 
 // Simplified handler func for pure JSON APIs
-// If second return val is an error it will be converted into an errorResponse
+// If second return val is an error it will be converted into an api.ErrorResponse
 // Otherwise it will be marshalled as-is and sent along with the status code
 type handlerFunc func(c *gin.Context) (int, interface{})
 
-func notImplemented(c *gin.Context) (int, interface{}) {
-	return http.StatusNotImplemented, errors.New("Not implemented")
-}
-
-// Convert internal handler funcs into gin handlers
+// Convert internal handler funcs into gin handlers. Errors are reported to the context, so they are
+// logged along with the request and handlers don't need to log them.
 func h(fn handlerFunc) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		code, data := fn(c)
-		if err, ok := data.(error); ok {
-			c.JSON(code, errorResponse{Error: err.Error()})
+		audit.Respond(c, data)
+		err, ok := data.(error)
+		if !ok && code >= http.StatusBadRequest {
+			err, ok = errors.New(http.StatusText(code)), true
+		}
+		if ok {
+			api.AbortWithError(c, code, err)
 		} else {
 			c.JSON(code, data)
 		}
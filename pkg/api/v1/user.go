@@ -1,27 +1,41 @@
 package v1
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jinzhu/gorm"
+	"github.com/yodo-io/ycp/pkg/api"
+	"github.com/yodo-io/ycp/pkg/api/v1/audit"
 	"github.com/yodo-io/ycp/pkg/model"
 )
 
+var errUserNotFound = errors.New("User not found")
+
 type users struct {
 	db *gorm.DB
 }
 
 type userPatch struct {
-	Email    string     `json:"email" binding:"omitempty,email"`
-	Role     model.Role `json:"role" binding:"omitempty,userrole"`
-	Password string     `json:"password"`
+	Email    string       `json:"email" binding:"omitempty,email"`
+	Roles    []model.Role `json:"roles" gorm:"-"`
+	Password string       `json:"password"`
+}
+
+var userList = listQuery{
+	sort: map[string]string{"id": "id", "email": "email"},
+	filter: map[string]string{
+		"email": "email = ?",
+		"role":  `id IN (SELECT user_id FROM user_roles WHERE role = ?)`,
+	},
+	order: "id",
 }
 
 func (uc *users) list(c *gin.Context) (int, interface{}) {
 	var users []*model.User
-	if err := uc.db.Find(&users).Error; err != nil {
-		return http.StatusInternalServerError, err
+	if code, err := userList.find(c, uc.db, &users); err != nil {
+		return code, err
 	}
 	return http.StatusOK, scrubAll(users)
 }
@@ -31,8 +45,14 @@ func (uc *users) create(c *gin.Context) (int, interface{}) {
 	if err := c.ShouldBind(&u); err != nil {
 		return http.StatusBadRequest, err
 	}
-	if u.Role == "" {
-		u.Role = "user"
+	if len(u.Roles) == 0 {
+		u.Roles = []model.Role{model.RoleUser}
+	}
+	if err := model.CheckRolesExist(uc.db, u.Roles); err != nil {
+		return roleError(err)
+	}
+	if err := u.SetPassword(u.Password); err != nil {
+		return http.StatusInternalServerError, err
 	}
 	if err := uc.db.Create(&u).Error; err != nil {
 		return http.StatusInternalServerError, err
@@ -48,7 +68,7 @@ func (uc *users) get(c *gin.Context) (int, interface{}) {
 		return http.StatusInternalServerError, err
 	}
 	if len(u) == 0 {
-		return http.StatusNotFound, errorResponse{Error: "Not Found"}
+		return http.StatusNotFound, errUserNotFound
 	}
 	return http.StatusOK, scrub(u[0])
 }
@@ -61,9 +81,30 @@ func (uc *users) delete(c *gin.Context) (int, interface{}) {
 		return http.StatusInternalServerError, err
 	}
 	if len(u) == 0 {
-		return http.StatusNotFound, errorResponse{Error: "Not Found"}
-	}
-	if err := uc.db.Delete(u[0], "id = ?", id).Error; err != nil {
+		return http.StatusNotFound, errUserNotFound
+	}
+
+	// the personal project goes along with the user, so its resources must be deleted first
+	err := model.Transaction(uc.db, func(tx *gorm.DB) error {
+		var n int
+		err := tx.Model(&model.Resource{}).
+			Where("project_id = ? and status <> ?", u[0].ProjectID, model.ResourceDeleted).
+			Count(&n).Error
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return api.Errorf(api.CodeConflict, "User still has %d resources in their personal project", n)
+		}
+		if err := tx.Delete(u[0], "id = ?", id).Error; err != nil {
+			return err
+		}
+		return model.RevokeUserTokens(tx, u[0].ID)
+	})
+	if e, ok := err.(*api.Error); ok {
+		return http.StatusConflict, e
+	}
+	if err != nil {
 		return http.StatusInternalServerError, err
 	}
 	return http.StatusOK, scrub(u[0])
@@ -77,14 +118,44 @@ func (uc *users) update(c *gin.Context) (int, interface{}) {
 		return http.StatusInternalServerError, err
 	}
 	if len(u) == 0 {
-		return http.StatusNotFound, errorResponse{"Not found"}
+		return http.StatusNotFound, errUserNotFound
 	}
+	audit.Before(c, u[0])
 
 	var up userPatch
 	if err := c.ShouldBind(&up); err != nil {
 		return http.StatusBadRequest, err
 	}
-	if err := uc.db.Model(&model.User{}).Where("id = ?", id).Omit("id").Updates(up).Error; err != nil {
+	// zero values are skipped by Updates, so an empty password is left untouched
+	if up.Password != "" {
+		h, err := model.HashPassword(up.Password)
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		up.Password = h
+	}
+	if up.Roles != nil {
+		// users may change their own email and password, but only admins grant roles
+		if !claimedAdmin(c) {
+			return http.StatusForbidden, errors.New("Only admins can change roles")
+		}
+		if len(up.Roles) == 0 {
+			return http.StatusBadRequest, errors.New("User must have at least one role")
+		}
+		if err := model.CheckRolesExist(uc.db, up.Roles); err != nil {
+			return roleError(err)
+		}
+	}
+	err := model.Transaction(uc.db, func(tx *gorm.DB) error {
+		if err := tx.Model(&model.User{}).Where("id = ?", id).Omit("id").Updates(up).Error; err != nil {
+			return err
+		}
+		if up.Roles != nil {
+			return u[0].SetRoles(tx, up.Roles)
+		}
+		return nil
+	})
+	if err != nil {
 		return http.StatusInternalServerError, err
 	}
 	if err := uc.db.Find(&u, "id = ?", id).Error; err != nil {
@@ -94,6 +165,46 @@ func (uc *users) update(c *gin.Context) (int, interface{}) {
 	return http.StatusOK, scrub(u[0])
 }
 
+// Organizations and projects a user is a member of
+type userMemberships struct {
+	Organizations []*model.OrganizationMember `json:"organizations"`
+	Projects      []*model.ProjectMember      `json:"projects"`
+}
+
+func (uc *users) memberships(c *gin.Context) (int, interface{}) {
+	u, err := lookupUser(uc.db, c.Param("id"))
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if u == nil {
+		return http.StatusNotFound, errUserNotFound
+	}
+
+	var res userMemberships
+	if err := uc.db.Order("organization_id").Find(&res.Organizations, "user_id = ?", u.ID).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err := uc.db.Order("project_id").Find(&res.Projects, "user_id = ?", u.ID).Error; err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, res
+}
+
+// Whether the user making the request is an admin according to their token. Requests without a token
+// aren't, so routes mounted without authentication can't be used to grant roles.
+func claimedAdmin(c *gin.Context) bool {
+	cl, ok := requestClaims(c)
+	if !ok {
+		return false
+	}
+	for _, r := range cl.Roles {
+		if r == model.RoleAdmin {
+			return true
+		}
+	}
+	return false
+}
+
 // Remove sensitive information from user object
 func scrub(u *model.User) *model.User {
 	u.Password = ""
@@ -118,3 +229,11 @@ func lookupUser(db *gorm.DB, id string) (*model.User, error) {
 	}
 	return u[0], nil
 }
+
+// Convert error from model.CheckRolesExist into a response
+func roleError(err error) (int, interface{}) {
+	if _, ok := err.(*model.UnknownRoleError); ok {
+		return http.StatusBadRequest, err
+	}
+	return http.StatusInternalServerError, err
+}
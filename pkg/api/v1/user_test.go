@@ -5,9 +5,14 @@ import (
 	"net/http"
 	"regexp"
 	"testing"
+	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
 	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/api"
 	"github.com/yodo-io/ycp/pkg/api/test"
+	"github.com/yodo-io/ycp/pkg/api/v1/auth"
 	"github.com/yodo-io/ycp/pkg/model"
 )
 
@@ -18,11 +23,11 @@ func TestCreateUser(t *testing.T) {
 	}{
 		{
 			in:  model.User{Email: "john@example.org", Password: "pass"},
-			out: model.User{ID: 1, Email: "john@example.org", Role: "user"},
+			out: model.User{ID: 1, Email: "john@example.org", Roles: []model.Role{"user"}, ProjectID: 1},
 		},
 		{
-			in:  model.User{Email: "john@example.org", Password: "pass", Role: "admin"},
-			out: model.User{ID: 1, Email: "john@example.org", Role: "admin"},
+			in:  model.User{Email: "john@example.org", Password: "pass", Roles: []model.Role{"admin"}},
+			out: model.User{ID: 1, Email: "john@example.org", Roles: []model.Role{"admin"}, ProjectID: 1},
 		},
 	}
 
@@ -46,28 +51,33 @@ func TestCreateUser(t *testing.T) {
 func TestUserValidation(t *testing.T) {
 
 	tests := []struct {
-		in  model.User
-		err *regexp.Regexp
+		in   model.User
+		err  *regexp.Regexp
+		code api.Code
 	}{
 		// password required
 		{
-			in:  model.User{Email: "john@example.org"},
-			err: regexp.MustCompile(`(?i)password`),
+			in:   model.User{Email: "john@example.org"},
+			err:  regexp.MustCompile(`(?i)password`),
+			code: api.CodeValidationFailed,
 		},
 		// email required
 		{
-			in:  model.User{Password: "password"},
-			err: regexp.MustCompile(`(?i)email`),
+			in:   model.User{Password: "password"},
+			err:  regexp.MustCompile(`(?i)email`),
+			code: api.CodeValidationFailed,
 		},
 		// role must exist
 		{
-			in:  model.User{Email: "john@example.org", Password: "password", Role: "superuser"},
-			err: regexp.MustCompile(`(?i)role`),
+			in:   model.User{Email: "john@example.org", Password: "password", Roles: []model.Role{"superuser"}},
+			err:  regexp.MustCompile(`(?i)role`),
+			code: api.CodeBadRequest,
 		},
 		// email must be valid
 		{
-			in:  model.User{Email: "email", Password: "password"},
-			err: regexp.MustCompile(`(?i)email`),
+			in:   model.User{Email: "email", Password: "password"},
+			err:  regexp.MustCompile(`(?i)email`),
+			code: api.CodeValidationFailed,
 		},
 	}
 
@@ -85,6 +95,7 @@ func TestUserValidation(t *testing.T) {
 			test.MustBind(t, w, &res)
 			assert.NotEmpty(t, res["error"])
 			assert.Regexp(t, tt.err, res["error"].(string))
+			assert.Equal(t, string(tt.code), res["code"])
 		}()
 	}
 }
@@ -104,7 +115,7 @@ func TestGetUsers(t *testing.T) {
 
 	for _, u := range res {
 		assert.NotEmpty(t, u.Email)
-		assert.NotEmpty(t, u.Role)
+		assert.NotEmpty(t, u.Roles)
 		assert.Empty(t, u.Password)
 	}
 }
@@ -135,24 +146,30 @@ func TestGetUser(t *testing.T) {
 		assert.NotEmpty(t, u)
 		assert.Equal(t, tt.id, u.ID)
 		assert.NotEmpty(t, u.Email)
-		assert.NotEmpty(t, u.Role)
+		assert.NotEmpty(t, u.Roles)
 		assert.Empty(t, u.Password)
 	}
 }
 
 func TestDeleteUser(t *testing.T) {
-	r, td := mustInitRouter(true)
+	r, db, td := mustInitRouterDB(true)
 	defer td()
 
 	tests := []struct {
 		id   uint
 		code int
+		// mark resources of the user's personal project deleted before
+		deleted bool
 	}{
-		{id: 1, code: http.StatusOK},
+		{id: 1, code: http.StatusConflict},
+		{id: 1, code: http.StatusOK, deleted: true},
 		{id: 20, code: http.StatusNotFound},
 	}
 
 	for _, tt := range tests {
+		if tt.deleted {
+			mustDeleteResources(t, db, tt.id)
+		}
 		w := test.MustRecord(t, r, http.MethodDelete, fmt.Sprintf("/users/%d", tt.id))
 		if !assert.Equal(t, tt.code, w.Code) {
 			continue
@@ -166,17 +183,32 @@ func TestDeleteUser(t *testing.T) {
 		assert.NotEmpty(t, u)
 		assert.Equal(t, tt.id, u.ID)
 		assert.NotEmpty(t, u.Email)
-		assert.NotEmpty(t, u.Role)
+		assert.NotEmpty(t, u.Roles)
 		assert.Empty(t, u.Password)
 
-		// make sure user was really deleted
+		// make sure user was really deleted, along with their personal project
 		w = test.MustRecord(t, r, http.MethodGet, fmt.Sprintf("/users/%d", tt.id))
 		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = test.MustRecord(t, r, http.MethodGet, fmt.Sprintf("/projects/%d", u.ProjectID))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		var n int
+		assert.NoError(t, db.Model(&model.ProjectMember{}).Where("user_id = ?", tt.id).Count(&n).Error)
+		assert.Zero(t, n)
+	}
+}
+
+// Mark all resources of a user's personal project deleted, as the provisioning worker would
+func mustDeleteResources(t *testing.T, db *gorm.DB, userID uint) {
+	err := db.Model(&model.Resource{}).
+		Where("project_id = (SELECT project_id FROM users WHERE id = ?)", userID).
+		Update("status", model.ResourceDeleted).Error
+	if err != nil {
+		t.Fatal(err)
 	}
 }
 
 func TestUpdateUser(t *testing.T) {
-	r, td := mustInitRouter(true)
+	r, td := mustInitAdminRouter(true)
 	defer td()
 
 	tests := []struct {
@@ -185,10 +217,10 @@ func TestUpdateUser(t *testing.T) {
 		code int
 	}{
 		{id: 1, user: model.User{Email: "jane@acme.org"}, code: http.StatusOK},
-		{id: 1, user: model.User{Role: "admin"}, code: http.StatusOK},
+		{id: 1, user: model.User{Roles: []model.Role{"admin"}}, code: http.StatusOK},
 		{id: 20, user: model.User{Email: "jane@acme.org"}, code: http.StatusNotFound},
 		{id: 1, user: model.User{Email: "jane"}, code: http.StatusBadRequest},
-		{id: 1, user: model.User{Role: "foo"}, code: http.StatusBadRequest},
+		{id: 1, user: model.User{Roles: []model.Role{"foo"}}, code: http.StatusBadRequest},
 	}
 
 	for _, tt := range tests {
@@ -205,6 +237,98 @@ func TestUpdateUser(t *testing.T) {
 		assert.NotEmpty(t, u)
 		assert.Equal(t, tt.id, u.ID)
 		assert.NotEmpty(t, u.Email)
-		assert.NotEmpty(t, u.Role)
+		assert.NotEmpty(t, u.Roles)
 	}
 }
+
+func TestUpdateUserRoles(t *testing.T) {
+	db := model.MustInitTestDB(true)
+	defer db.Close()
+
+	tests := []struct {
+		claims auth.Claims
+		in     gin.H
+		code   int
+	}{
+		// users can change their own details, but not their roles
+		{claims: auth.Claims{UserID: 1, Roles: []model.Role{model.RoleUser}}, in: gin.H{"email": "joe@example.com"}, code: http.StatusOK},
+		{claims: auth.Claims{UserID: 1, Roles: []model.Role{model.RoleUser}}, in: gin.H{"roles": []string{"admin"}}, code: http.StatusForbidden},
+		{claims: auth.Claims{UserID: 1, Roles: []model.Role{model.RoleUser, model.RoleAuditor}}, in: gin.H{"roles": []string{"user"}}, code: http.StatusForbidden},
+		{claims: auth.Claims{UserID: 2, Roles: []model.Role{model.RoleAdmin}}, in: gin.H{"roles": []string{"user", "auditor"}}, code: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		cl := tt.claims
+		r := test.NewRouter()
+		r.Use(func(c *gin.Context) { c.Set("claims", cl) })
+		Routes(&r.RouterGroup, db)
+
+		w := test.MustRecord(t, r, http.MethodPatch, "/users/1", tt.in)
+		assert.Equal(t, tt.code, w.Code, "%v", tt.in)
+	}
+
+	// requests without claims didn't pass authentication, they can't change roles either
+	r := test.NewRouter()
+	Routes(&r.RouterGroup, db)
+	w := test.MustRecord(t, r, http.MethodPatch, "/users/1", gin.H{"roles": []string{"admin"}})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	var u model.User
+	if err := db.First(&u, 1).Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []model.Role{model.RoleAuditor, model.RoleUser}, u.Roles)
+}
+
+func TestPasswordIsHashed(t *testing.T) {
+	db := model.MustInitTestDB(false)
+	defer db.Close()
+	r := test.NewRouter()
+	Routes(&r.RouterGroup, db)
+
+	tests := []struct {
+		method string
+		path   string
+		pw     string
+	}{
+		{method: http.MethodPost, path: "/users", pw: "pass"},
+		{method: http.MethodPatch, path: "/users/1", pw: "new pass"},
+	}
+
+	for _, tt := range tests {
+		in := model.User{Email: "john@example.org", Password: tt.pw}
+		w := test.MustRecord(t, r, tt.method, tt.path, in)
+		if !assert.True(t, w.Code < 300, "unexpected status %d", w.Code) {
+			continue
+		}
+
+		var u model.User
+		if err := db.First(&u, 1).Error; err != nil {
+			t.Fatal(err)
+		}
+		assert.NotEqual(t, tt.pw, u.Password)
+		assert.True(t, u.CheckPassword(tt.pw))
+	}
+}
+
+func TestDeleteUserRevokesTokens(t *testing.T) {
+	db := model.MustInitTestDB(true)
+	defer db.Close()
+	r := test.NewRouter()
+	Routes(&r.RouterGroup, db)
+
+	rt := model.RefreshToken{Hash: "hash", Family: "family", UserID: 1, AccessJTI: "jti", ExpiresAt: time.Now().Add(time.Hour)}
+	if err := db.Create(&rt).Error; err != nil {
+		t.Fatal(err)
+	}
+	mustDeleteResources(t, db, 1)
+
+	w := test.MustRecord(t, r, http.MethodDelete, "/users/1")
+	if !assert.Equal(t, http.StatusOK, w.Code) {
+		return
+	}
+
+	revoked, err := model.IsTokenRevoked(db, "jti")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+// CatalogPatch changes a catalog item, nil fields are left unchanged
+type CatalogPatch struct {
+	DisplayName     *string              `json:"displayName,omitempty"`
+	Description     *string              `json:"description,omitempty"`
+	Category        *string              `json:"category,omitempty"`
+	Specs           *model.Specs         `json:"specs,omitempty"`
+	HourlyPrice     *int64               `json:"hourlyPrice,omitempty"`
+	Currency        *string              `json:"currency,omitempty"`
+	Status          *model.CatalogStatus `json:"status,omitempty"`
+	FreeWhenStopped *bool                `json:"freeWhenStopped,omitempty"`
+}
+
+func catalogPath(name string) string {
+	return "/catalog/" + url.PathEscape(name)
+}
+
+// ListCatalog lists catalog items, they can be filtered by "category" and "status"
+func (c *Client) ListCatalog(ctx context.Context, opts *ListOptions) ([]*model.Catalog, *Page, error) {
+	var res []*model.Catalog
+	p, err := c.list(ctx, "/catalog", opts, &res)
+	return res, p, err
+}
+
+// GetCatalogItem gets a catalog item by name
+func (c *Client) GetCatalogItem(ctx context.Context, name string) (*model.Catalog, error) {
+	var res model.Catalog
+	if err := c.do(ctx, http.MethodGet, catalogPath(name), nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// CreateCatalogItem adds an item to the catalog
+func (c *Client) CreateCatalogItem(ctx context.Context, cat *model.Catalog) (*model.Catalog, error) {
+	var res model.Catalog
+	if err := c.do(ctx, http.MethodPost, "/catalog", nil, cat, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// UpdateCatalogItem changes a catalog item, e.g. to retire it
+func (c *Client) UpdateCatalogItem(ctx context.Context, name string, up CatalogPatch) (*model.Catalog, error) {
+	var res model.Catalog
+	if err := c.do(ctx, http.MethodPatch, catalogPath(name), nil, up, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// DeleteCatalogItem deletes a catalog item, which fails while resources or quotas use it
+func (c *Client) DeleteCatalogItem(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, catalogPath(name), nil, nil, nil)
+}
@@ -0,0 +1,49 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/client"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+func TestCatalog(t *testing.T) {
+	srv, _, teardown := mustInitServer(t)
+	defer teardown()
+	ctx := context.Background()
+	admin := mustLogin(t, srv, "admin@example.org")
+
+	cat := &model.Catalog{
+		Name:        "pot.instance.tiny",
+		DisplayName: "Tiny pot",
+		Category:    "pot",
+		Specs:       model.Specs{Capacity: 0.5, Size: 10},
+		HourlyPrice: 1,
+		Currency:    "EUR",
+	}
+	res, err := admin.CreateCatalogItem(ctx, cat)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, cat.Name, res.Name)
+
+	_, err = admin.CreateCatalogItem(ctx, cat)
+	assert.True(t, client.ErrAlreadyExists.Is(err), "%v", err)
+
+	name := "Very tiny pot"
+	res, err = admin.UpdateCatalogItem(ctx, cat.Name, client.CatalogPatch{DisplayName: &name})
+	if assert.NoError(t, err) {
+		assert.Equal(t, name, res.DisplayName)
+		assert.Equal(t, "pot", res.Category)
+	}
+	res, err = admin.GetCatalogItem(ctx, cat.Name)
+	if assert.NoError(t, err) {
+		assert.Equal(t, name, res.DisplayName)
+	}
+
+	assert.NoError(t, admin.DeleteCatalogItem(ctx, cat.Name))
+	_, err = admin.GetCatalogItem(ctx, cat.Name)
+	assert.True(t, client.ErrNotFound.Is(err), "%v", err)
+}
@@ -0,0 +1,297 @@
+/*
+Package client is a Go client for the v1 API of ycp.
+
+Clients log in once and keep the tokens they get. Expired tokens are refreshed with the refresh
+token, or by logging in again if that fails, and the request is retried:
+
+	c, err := client.New("http://localhost:9000")
+	if err != nil {
+		return err
+	}
+	if err := c.Login(ctx, "joe@example.org", "secret"); err != nil {
+		return err
+	}
+	r, opID, err := c.CreateResource(ctx, projectID, &model.Resource{Name: "soup", Type: "pot.instance.small"})
+
+Requests are cancelled with their context, they return the context's error then. Errors returned by the API are of type *Error, which
+can be compared to the Err values of this package by their code:
+
+	if client.ErrQuotaExceeded.Is(err) {
+		// ask for a bigger quota
+	}
+*/
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Client calls the API of a ycp server. It is safe for concurrent use.
+type Client struct {
+	base *url.URL
+
+	// HTTPClient sends requests, http.DefaultClient by default
+	HTTPClient *http.Client
+
+	mu           sync.Mutex
+	email        string
+	password     string
+	token        string
+	refreshToken string
+
+	// held while renewing the session, refresh tokens can only be used once
+	renewMu sync.Mutex
+}
+
+// New creates a client for the server at baseURL, e.g. http://localhost:9000
+func New(baseURL string) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("Invalid base URL %q, must be absolute", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	return &Client{base: u, HTTPClient: http.DefaultClient}, nil
+}
+
+// Tokens returned by the server on login and refresh
+type tokens struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refreshToken"`
+}
+
+// Login logs in with the given credentials. They are kept to log in again if the session expires.
+func (c *Client) Login(ctx context.Context, email, password string) error {
+	var t tokens
+	in := map[string]string{"email": email, "password": password}
+	if err := c.send(ctx, http.MethodPost, "/auth/token", nil, in, &t, ""); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.email, c.password = email, password
+	c.token, c.refreshToken = t.Token, t.RefreshToken
+	return nil
+}
+
+// Refresh gets a new token with the refresh token. Refresh tokens can only be used once, the
+// client keeps the new one.
+func (c *Client) Refresh(ctx context.Context) error {
+	c.renewMu.Lock()
+	defer c.renewMu.Unlock()
+	return c.refresh(ctx)
+}
+
+func (c *Client) refresh(ctx context.Context) error {
+	_, refreshToken := c.Tokens()
+	if refreshToken == "" {
+		return ErrUnauthorized
+	}
+	var t tokens
+	in := map[string]string{"refreshToken": refreshToken}
+	if err := c.send(ctx, http.MethodPost, "/auth/refresh", nil, in, &t, ""); err != nil {
+		return err
+	}
+	c.SetTokens(t.Token, t.RefreshToken)
+	return nil
+}
+
+// Logout revokes the refresh token and all tokens issued with it, and forgets the credentials
+func (c *Client) Logout(ctx context.Context) error {
+	_, refreshToken := c.Tokens()
+	c.mu.Lock()
+	c.email, c.password, c.token, c.refreshToken = "", "", "", ""
+	c.mu.Unlock()
+	if refreshToken == "" {
+		return nil
+	}
+	in := map[string]string{"refreshToken": refreshToken}
+	return c.send(ctx, http.MethodPost, "/auth/logout", nil, in, nil, "")
+}
+
+// Tokens returns the current token and refresh token, e.g. to keep them between runs of a program
+func (c *Client) Tokens() (token, refreshToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.token, c.refreshToken
+}
+
+// SetTokens sets the tokens to use, e.g. those kept from a previous login
+func (c *Client) SetTokens(token, refreshToken string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token, c.refreshToken = token, refreshToken
+}
+
+// Renew the session after the token was rejected, by refreshing or logging in again. Requests
+// rejected at the same time renew it only once: the server revokes all tokens of a session if a
+// refresh token is used twice.
+func (c *Client) renew(ctx context.Context, rejected string) error {
+	c.renewMu.Lock()
+	defer c.renewMu.Unlock()
+	if token, _ := c.Tokens(); token != rejected {
+		return nil
+	}
+	err := c.refresh(ctx)
+	if err == nil {
+		return nil
+	}
+	c.mu.Lock()
+	email, password := c.email, c.password
+	c.mu.Unlock()
+	if email == "" {
+		return err
+	}
+	return c.Login(ctx, email, password)
+}
+
+// Call an authenticated API route, path is relative to /v1. The session is renewed and the request
+// retried once if the token has expired or been revoked.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	token, _ := c.Tokens()
+	err := c.send(ctx, method, "/v1"+path, query, in, out, token)
+	if e, ok := err.(*Error); !ok || e.StatusCode != http.StatusUnauthorized {
+		return err
+	}
+	if rerr := c.renew(ctx, token); rerr != nil {
+		return err
+	}
+	token, _ = c.Tokens()
+	return c.send(ctx, method, "/v1"+path, query, in, out, token)
+}
+
+// Send a request and decode the response into out. Out may be a *withHeader to get the response
+// headers along with the body.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, in, out interface{}, token string) error {
+	u := *c.base
+	u.Path += path
+	u.RawQuery = query.Encode()
+
+	var body io.Reader
+	if in != nil {
+		b, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Token", token)
+	}
+
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		// report cancellation as is, rather than wrapped in a *url.Error
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	defer res.Body.Close()
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode >= http.StatusBadRequest {
+		return newError(res, b)
+	}
+
+	if wh, ok := out.(*withHeader); ok {
+		wh.header = res.Header
+		out = wh.body
+	}
+	if out == nil || len(b) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(b, out); err != nil {
+		return fmt.Errorf("Failed to decode response of %s %s: %v", method, path, err)
+	}
+	return nil
+}
+
+// Decodes a response body while keeping the response headers, for responses with information in
+// headers such as list pages and operation locations
+type withHeader struct {
+	body   interface{}
+	header http.Header
+}
+
+// ListOptions page, sort and filter list requests
+type ListOptions struct {
+	// Limit is the maximum number of items returned, the server's default if 0
+	Limit int
+	// Cursor continues a previous list, see Page
+	Cursor string
+	// Sort lists fields to sort by, e.g. "-name,id" sorts by name descending, then by id
+	Sort string
+	// Filter maps filters to values, e.g. {"category": "pot"}. Filters differ by list.
+	Filter map[string]string
+}
+
+func (o *ListOptions) query() url.Values {
+	q := url.Values{}
+	if o == nil {
+		return q
+	}
+	if o.Limit > 0 {
+		q.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Cursor != "" {
+		q.Set("cursor", o.Cursor)
+	}
+	if o.Sort != "" {
+		q.Set("sort", o.Sort)
+	}
+	for k, v := range o.Filter {
+		q.Set(k, v)
+	}
+	return q
+}
+
+// Page describes where a list result is in the list of all items
+type Page struct {
+	// Total is the number of items matching the list's filters
+	Total int
+	// Next is the cursor of the next page, empty on the last page
+	Next string
+}
+
+// List a page of items at path into out, a pointer to a slice
+func (c *Client) list(ctx context.Context, path string, opts *ListOptions, out interface{}) (*Page, error) {
+	wh := &withHeader{body: out}
+	if err := c.do(ctx, http.MethodGet, path, opts.query(), nil, wh); err != nil {
+		return nil, err
+	}
+	p := &Page{}
+	p.Total, _ = strconv.Atoi(wh.header.Get("X-Total-Count"))
+	// Link: </v1/users?cursor=MTAw&limit=100>; rel="next"
+	if l := wh.header.Get("Link"); strings.HasSuffix(l, `rel="next"`) {
+		if i, j := strings.Index(l, "<"), strings.Index(l, ">"); i >= 0 && j > i {
+			if u, err := url.Parse(l[i+1 : j]); err == nil {
+				p.Next = u.Query().Get("cursor")
+			}
+		}
+	}
+	return p, nil
+}
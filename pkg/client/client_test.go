@@ -0,0 +1,237 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/api/v1/auth"
+	"github.com/yodo-io/ycp/pkg/client"
+	"github.com/yodo-io/ycp/pkg/client/clienttest"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+// Start a server with sample data
+func mustInitServer(t *testing.T) (*httptest.Server, *gorm.DB, func()) {
+	db := model.MustInitTestDB(true)
+	srv := clienttest.NewServer(db)
+	return srv, db, func() {
+		srv.Close()
+		db.Close()
+	}
+}
+
+// Create a client logged in with the given credentials
+func mustLogin(t *testing.T, srv *httptest.Server, email string) *client.Client {
+	c, err := client.New(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Login(context.Background(), email, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// An expired token for joe, signed like the server does
+func mustExpiredToken(t *testing.T) string {
+	cl := &auth.Claims{
+		Roles:  []model.Role{model.RoleUser},
+		UserID: 1,
+		Email:  "joe@example.org",
+		StandardClaims: jwt.StandardClaims{
+			Id:        "expired",
+			ExpiresAt: time.Now().Add(-time.Minute).Unix(),
+		},
+	}
+	s, err := jwt.NewWithClaims(jwt.SigningMethodHS256, cl).SignedString(clienttest.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		url string
+		ok  bool
+	}{
+		{url: "http://localhost:9000", ok: true},
+		{url: "http://localhost:9000/ycp/", ok: true},
+		{url: "localhost:9000", ok: false},
+		{url: "/v1", ok: false},
+	}
+	for _, tt := range tests {
+		_, err := client.New(tt.url)
+		assert.Equal(t, tt.ok, err == nil, tt.url)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	srv, _, teardown := mustInitServer(t)
+	defer teardown()
+
+	tests := []struct {
+		email    string
+		password string
+		err      *client.Error
+	}{
+		{email: "joe@example.org", password: "secret"},
+		{email: "joe@example.org", password: "wrong", err: client.ErrAuthenticationFailed},
+		{email: "nobody@example.org", password: "secret", err: client.ErrAuthenticationFailed},
+		{email: "", password: "", err: client.ErrValidationFailed},
+	}
+	for _, tt := range tests {
+		c, _ := client.New(srv.URL)
+		err := c.Login(context.Background(), tt.email, tt.password)
+		token, refreshToken := c.Tokens()
+		if tt.err == nil {
+			assert.NoError(t, err)
+			assert.NotEmpty(t, token)
+			assert.NotEmpty(t, refreshToken)
+			continue
+		}
+		assert.True(t, tt.err.Is(err), "%s: %v", tt.email, err)
+		assert.Empty(t, token)
+	}
+}
+
+func TestRenewSession(t *testing.T) {
+	srv, _, teardown := mustInitServer(t)
+	defer teardown()
+	ctx := context.Background()
+	expired := mustExpiredToken(t)
+
+	// expired token is refreshed with the refresh token
+	c := mustLogin(t, srv, "joe@example.org")
+	_, refreshToken := c.Tokens()
+	c.SetTokens(expired, refreshToken)
+	u, err := c.GetUser(ctx, 1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "joe@example.org", u.Email)
+	}
+	token, newRefreshToken := c.Tokens()
+	assert.NotEqual(t, expired, token)
+	assert.NotEqual(t, refreshToken, newRefreshToken)
+
+	// refresh token was used up, so the client logs in again
+	c.SetTokens(expired, refreshToken)
+	_, err = c.GetUser(ctx, 1)
+	assert.NoError(t, err)
+
+	// without credentials, the original error is returned
+	c, _ = client.New(srv.URL)
+	c.SetTokens(expired, "")
+	_, err = c.GetUser(ctx, 1)
+	assert.True(t, client.ErrTokenExpired.Is(err), "%v", err)
+}
+
+func TestRenewSessionConcurrently(t *testing.T) {
+	srv, _, teardown := mustInitServer(t)
+	defer teardown()
+	ctx := context.Background()
+
+	// a session restored from its tokens has no credentials to log in again with
+	_, refreshToken := mustLogin(t, srv, "joe@example.org").Tokens()
+	c, _ := client.New(srv.URL)
+	c.SetTokens(mustExpiredToken(t), refreshToken)
+
+	// using the refresh token twice would revoke the session
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = c.GetUser(ctx, 1)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	_, err := c.GetUser(ctx, 1)
+	assert.NoError(t, err)
+}
+
+func TestLogout(t *testing.T) {
+	srv, _, teardown := mustInitServer(t)
+	defer teardown()
+	ctx := context.Background()
+
+	c := mustLogin(t, srv, "joe@example.org")
+	token, refreshToken := c.Tokens()
+	assert.NoError(t, c.Logout(ctx))
+
+	// tokens are forgotten and revoked on the server
+	_, err := c.GetUser(ctx, 1)
+	assert.True(t, client.ErrUnauthorized.Is(err), "%v", err)
+	c.SetTokens(token, refreshToken)
+	_, err = c.GetUser(ctx, 1)
+	assert.True(t, client.ErrUnauthorized.Is(err), "%v", err)
+}
+
+func TestContextCancel(t *testing.T) {
+	srv, _, teardown := mustInitServer(t)
+	defer teardown()
+
+	c := mustLogin(t, srv, "joe@example.org")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := c.GetUser(ctx, 1)
+	assert.Equal(t, context.Canceled, err)
+}
+
+func TestList(t *testing.T) {
+	srv, _, teardown := mustInitServer(t)
+	defer teardown()
+	ctx := context.Background()
+
+	c := mustLogin(t, srv, "admin@example.org")
+	var names []string
+	opts := &client.ListOptions{Limit: 1, Sort: "name"}
+	for i := 0; ; i++ {
+		items, p, err := c.ListCatalog(ctx, opts)
+		if !assert.NoError(t, err) || !assert.Len(t, items, 1) {
+			return
+		}
+		names = append(names, items[0].Name)
+		if p.Next == "" {
+			assert.Equal(t, p.Total, i+1)
+			break
+		}
+		opts.Cursor = p.Next
+	}
+	assert.True(t, len(names) > 1, "%v", names)
+	assert.True(t, sort.StringsAreSorted(names), "%v", names)
+
+	items, p, err := c.ListCatalog(ctx, &client.ListOptions{Filter: map[string]string{"category": "nothing"}})
+	if assert.NoError(t, err) {
+		assert.Empty(t, items)
+		assert.Equal(t, 0, p.Total)
+		assert.Empty(t, p.Next)
+	}
+}
+
+func TestNotJSONError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", "abc")
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	c, _ := client.New(srv.URL)
+	_, err := c.GetUser(context.Background(), 1)
+	if e, ok := err.(*client.Error); assert.True(t, ok, "%v", err) {
+		assert.Equal(t, http.StatusBadGateway, e.StatusCode)
+		assert.True(t, client.ErrInternal.Is(e))
+		assert.Equal(t, "abc", e.RequestID)
+	}
+}
@@ -0,0 +1,34 @@
+// Package clienttest runs the API for tests of clients
+package clienttest
+
+import (
+	"net/http/httptest"
+
+	"github.com/jinzhu/gorm"
+	"github.com/yodo-io/ycp/pkg/api/test"
+	v1 "github.com/yodo-io/ycp/pkg/api/v1"
+	"github.com/yodo-io/ycp/pkg/api/v1/auth"
+	"github.com/yodo-io/ycp/pkg/api/v1/rbac"
+)
+
+// Secret signs the tokens of test servers
+var Secret = []byte("be00d27d0c134cc79e473f40a1e393f0")
+
+// NewServer starts a server for the API on db, set up like main does apart from auditing and metrics.
+// It panics if the RBAC policy can't be loaded, so it's not intended for use outside of test code.
+// Clients must close the server.
+func NewServer(db *gorm.DB) *httptest.Server {
+	policy, err := rbac.NewPolicy(rbac.DBSource(db))
+	if err != nil {
+		panic(err)
+	}
+	policy.Memberships = rbac.DBMemberships(db)
+
+	r := test.NewRouter()
+	auth.Routes(r.Group("/auth"), db, Secret)
+	rg := r.Group("/v1")
+	rg.Use(auth.Middleware(db, Secret))
+	rg.Use(rbac.Middleware(policy))
+	v1.Routes(rg, db)
+	return httptest.NewServer(r)
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/yodo-io/ycp/pkg/api"
+)
+
+// Error is an error response of the API
+type Error struct {
+	// StatusCode is the HTTP status of the response
+	StatusCode int
+	Code       api.Code
+	Message    string
+	// Details tell which fields of the request were invalid
+	Details   []api.FieldError
+	RequestID string
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("%s: %s", e.Code, e.Message)
+	if e.RequestID != "" {
+		msg += fmt.Sprintf(" (request %s)", e.RequestID)
+	}
+	return msg
+}
+
+// Is reports whether err is an *Error with the same code as e. It makes errors.Is work with the Err
+// values of this package.
+func (e *Error) Is(err error) bool {
+	o, ok := err.(*Error)
+	return ok && o.Code == e.Code
+}
+
+// Errors by code, compare them to returned errors with Is
+var (
+	ErrBadRequest           = &Error{Code: api.CodeBadRequest}
+	ErrValidationFailed     = &Error{Code: api.CodeValidationFailed}
+	ErrAuthenticationFailed = &Error{Code: api.CodeAuthenticationFailed}
+	ErrUnauthorized         = &Error{Code: api.CodeUnauthorized}
+	ErrTokenExpired         = &Error{Code: api.CodeTokenExpired}
+	ErrForbidden            = &Error{Code: api.CodeForbidden}
+	ErrNotFound             = &Error{Code: api.CodeNotFound}
+	ErrAlreadyExists        = &Error{Code: api.CodeAlreadyExists}
+	ErrConflict             = &Error{Code: api.CodeConflict}
+	ErrInvalidState         = &Error{Code: api.CodeInvalidState}
+	ErrQuotaExceeded        = &Error{Code: api.CodeQuotaExceeded}
+	ErrInvalidResourceType  = &Error{Code: api.CodeInvalidResourceType}
+	ErrInternal             = &Error{Code: api.CodeInternal}
+	ErrUnavailable          = &Error{Code: api.CodeUnavailable}
+)
+
+// Build the error of a failed response from its body, responses not in the API's error format,
+// e.g. from a proxy, are reported with their status
+func newError(res *http.Response, body []byte) *Error {
+	e := &Error{StatusCode: res.StatusCode, RequestID: res.Header.Get("X-Request-ID")}
+	var er api.ErrorResponse
+	if err := json.Unmarshal(body, &er); err == nil && er.Code != "" {
+		e.Code, e.Message, e.Details = er.Code, er.Error, er.Details
+		if er.RequestID != "" {
+			e.RequestID = er.RequestID
+		}
+		return e
+	}
+	e.Code, e.Message = statusCode(res.StatusCode), http.StatusText(res.StatusCode)
+	return e
+}
+
+// Codes of errors without one, matching what the server uses
+func statusCode(status int) api.Code {
+	switch status {
+	case http.StatusUnauthorized:
+		return api.CodeUnauthorized
+	case http.StatusForbidden:
+		return api.CodeForbidden
+	case http.StatusNotFound:
+		return api.CodeNotFound
+	case http.StatusConflict:
+		return api.CodeConflict
+	case http.StatusServiceUnavailable:
+		return api.CodeUnavailable
+	}
+	if status >= http.StatusInternalServerError {
+		return api.CodeInternal
+	}
+	return api.CodeBadRequest
+}
@@ -0,0 +1,71 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+// QuotaUsage tells how much of a project's quota for a resource type is used. Limit and Remaining
+// are nil for unlimited types.
+type QuotaUsage struct {
+	ProjectID uint   `json:"projectId"`
+	Type      string `json:"type"`
+	Limit     *int   `json:"limit,omitempty"`
+	Used      int    `json:"used"`
+	Remaining *int   `json:"remaining,omitempty"`
+	Unlimited bool   `json:"unlimited"`
+}
+
+func quotasPath(projectID uint) string {
+	return fmt.Sprintf("/projects/%d/quotas", projectID)
+}
+
+// ListQuotas lists the quotas of a project, they can be filtered by "type"
+func (c *Client) ListQuotas(ctx context.Context, projectID uint, opts *ListOptions) ([]*model.Quota, *Page, error) {
+	var res []*model.Quota
+	p, err := c.list(ctx, quotasPath(projectID), opts, &res)
+	return res, p, err
+}
+
+// SetQuota sets the quota of a project for a resource type, creating it if needed
+func (c *Client) SetQuota(ctx context.Context, projectID uint, tp string, value int) (*model.Quota, error) {
+	var res model.Quota
+	p := quotasPath(projectID) + "/" + url.PathEscape(tp)
+	if err := c.do(ctx, http.MethodPut, p, nil, map[string]int{"value": value}, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// DeleteQuota deletes a quota of a project, which makes its type unlimited
+func (c *Client) DeleteQuota(ctx context.Context, projectID, id uint) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("%s/%d", quotasPath(projectID), id), nil, nil, nil)
+}
+
+// ProjectUsage lists how much of a project's quota is used, for every type in the catalog
+func (c *Client) ProjectUsage(ctx context.Context, projectID uint) ([]*QuotaUsage, error) {
+	var res []*QuotaUsage
+	if err := c.do(ctx, http.MethodGet, quotasPath(projectID)+"/usage", nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Usage lists how much of all quotas across projects is used, fullest first. Only quotas used at
+// least by the threshold, e.g. 0.8 for 80%, are returned.
+func (c *Client) Usage(ctx context.Context, threshold float64) ([]*QuotaUsage, error) {
+	var res []*QuotaUsage
+	q := url.Values{}
+	if threshold > 0 {
+		q.Set("threshold", strconv.FormatFloat(threshold, 'f', -1, 64))
+	}
+	if err := c.do(ctx, http.MethodGet, "/usage", q, nil, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
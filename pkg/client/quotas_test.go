@@ -0,0 +1,89 @@
+package client_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/client"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+func TestQuotas(t *testing.T) {
+	srv, _, teardown := mustInitServer(t)
+	defer teardown()
+	ctx := context.Background()
+	admin := mustLogin(t, srv, "admin@example.org")
+
+	q, err := admin.SetQuota(ctx, 1, "pot.instance.small", 2)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 2, q.Value)
+
+	qs, p, err := admin.ListQuotas(ctx, 1, &client.ListOptions{Filter: map[string]string{"type": "pot.instance.small"}})
+	if assert.NoError(t, err) && assert.Len(t, qs, 1) {
+		assert.Equal(t, q.ID, qs[0].ID)
+		assert.Equal(t, 1, p.Total)
+	}
+
+	// one of two used
+	c := mustLogin(t, srv, "joe@example.org")
+	if _, _, err := c.CreateResource(ctx, 1, &model.Resource{Name: "soup", Type: "pot.instance.small"}); err != nil {
+		t.Fatal(err)
+	}
+	us, err := c.ProjectUsage(ctx, 1)
+	if assert.NoError(t, err) {
+		for _, u := range us {
+			if u.Type == "pot.instance.small" && assert.NotNil(t, u.Remaining) {
+				assert.Equal(t, 1, u.Used)
+				assert.Equal(t, 1, *u.Remaining)
+			}
+		}
+	}
+	us, err = admin.Usage(ctx, 0.5)
+	if assert.NoError(t, err) && assert.NotEmpty(t, us) {
+		assert.Equal(t, uint(1), us[0].ProjectID)
+	}
+
+	// users can't change their quotas
+	_, err = c.SetQuota(ctx, 1, "pot.instance.small", 100)
+	assert.True(t, client.ErrForbidden.Is(err), "%v", err)
+
+	assert.NoError(t, admin.DeleteQuota(ctx, 1, q.ID))
+	err = admin.DeleteQuota(ctx, 1, q.ID)
+	assert.True(t, client.ErrNotFound.Is(err), "%v", err)
+}
+
+// Projects without quotas are unlimited, so users can't create projects of their own to get around theirs
+func TestQuotasNewProject(t *testing.T) {
+	srv, _, teardown := mustInitServer(t)
+	defer teardown()
+	ctx := context.Background()
+	admin := mustLogin(t, srv, "admin@example.org")
+	if _, err := admin.SetQuota(ctx, 1, "pot.instance.small", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	c := mustLogin(t, srv, "joe@example.org")
+	token, _ := c.Tokens()
+	for _, path := range []string{"/v1/orgs", "/v1/orgs/1/projects"} {
+		req, err := http.NewRequest(http.MethodPost, srv.URL+path, strings.NewReader(`{"name":"unlimited"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-type", "application/json")
+		req.Header.Set("Token", token)
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		assert.Equal(t, http.StatusForbidden, res.StatusCode, path)
+	}
+
+	_, _, err := c.CreateResource(ctx, 1, &model.Resource{Name: "soup", Type: "pot.instance.small"})
+	assert.True(t, client.ErrQuotaExceeded.Is(err), "%v", err)
+}
@@ -0,0 +1,124 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+// ResourcePatch renames or resizes a resource, empty fields are left unchanged
+type ResourcePatch struct {
+	Name string `json:"name,omitempty"`
+	// Type is the catalog item to resize the resource to, only running resources can be resized
+	Type string `json:"type,omitempty"`
+}
+
+func resourcesPath(projectID uint) string {
+	return fmt.Sprintf("/projects/%d/resources", projectID)
+}
+
+func resourcePath(projectID, id uint) string {
+	return fmt.Sprintf("/projects/%d/resources/%d", projectID, id)
+}
+
+// ListResources lists resources of a project, they can be filtered by "name", "type" and "status"
+func (c *Client) ListResources(ctx context.Context, projectID uint, opts *ListOptions) ([]*model.Resource, *Page, error) {
+	var res []*model.Resource
+	p, err := c.list(ctx, resourcesPath(projectID), opts, &res)
+	return res, p, err
+}
+
+// GetResource gets a resource of a project
+func (c *Client) GetResource(ctx context.Context, projectID, id uint) (*model.Resource, error) {
+	var res model.Resource
+	if err := c.do(ctx, http.MethodGet, resourcePath(projectID, id), nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// CreateResource creates a resource in a project from its name and type. Resources are provisioned
+// in the background, the ID of the operation doing so is returned along with the pending resource.
+func (c *Client) CreateResource(ctx context.Context, projectID uint, r *model.Resource) (*model.Resource, uint, error) {
+	return c.changeResource(ctx, http.MethodPost, resourcesPath(projectID), r)
+}
+
+// UpdateResource renames or resizes a resource. If the resource is resized, the ID of the operation
+// doing so is returned, otherwise 0.
+func (c *Client) UpdateResource(ctx context.Context, projectID, id uint, up ResourcePatch) (*model.Resource, uint, error) {
+	return c.changeResource(ctx, http.MethodPatch, resourcePath(projectID, id), up)
+}
+
+// DeleteResource deletes a resource in the background and returns the ID of the operation doing so
+func (c *Client) DeleteResource(ctx context.Context, projectID, id uint) (*model.Resource, uint, error) {
+	return c.changeResource(ctx, http.MethodDelete, resourcePath(projectID, id), nil)
+}
+
+// ResourceAction starts, stops or restarts a resource in the background and returns the ID of the
+// operation doing so
+func (c *Client) ResourceAction(ctx context.Context, projectID, id uint, action string) (*model.Resource, uint, error) {
+	return c.changeResource(ctx, http.MethodPost, resourcePath(projectID, id)+"/actions/"+action, nil)
+}
+
+// Send a request changing a resource, along with the ID of the operation from the Operation-Location header
+func (c *Client) changeResource(ctx context.Context, method, p string, in interface{}) (*model.Resource, uint, error) {
+	var res model.Resource
+	wh := &withHeader{body: &res}
+	if err := c.do(ctx, method, p, nil, in, wh); err != nil {
+		return nil, 0, err
+	}
+	var opID uint
+	if loc := wh.header.Get("Operation-Location"); loc != "" {
+		id, err := strconv.ParseUint(path.Base(loc), 10, 64)
+		if err != nil {
+			return nil, 0, fmt.Errorf("Invalid Operation-Location %q", loc)
+		}
+		opID = uint(id)
+	}
+	return &res, opID, nil
+}
+
+// ListOperations lists the operations of a resource, i.e. its history, oldest first
+func (c *Client) ListOperations(ctx context.Context, projectID, id uint) ([]*model.Operation, error) {
+	var res []*model.Operation
+	if err := c.do(ctx, http.MethodGet, resourcePath(projectID, id)+"/operations", nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// GetOperation gets an operation of a resource
+func (c *Client) GetOperation(ctx context.Context, projectID, id, opID uint) (*model.Operation, error) {
+	var res model.Operation
+	p := fmt.Sprintf("%s/operations/%d", resourcePath(projectID, id), opID)
+	if err := c.do(ctx, http.MethodGet, p, nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// WaitForOperation polls an operation every interval until it has succeeded or failed, or the
+// context is done. Failed operations are returned without error, their Error tells what went wrong.
+func (c *Client) WaitForOperation(ctx context.Context, projectID, id, opID uint, interval time.Duration) (*model.Operation, error) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		op, err := c.GetOperation(ctx, projectID, id, opID)
+		if err != nil {
+			return nil, err
+		}
+		if op.Status.Done() {
+			return op, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/client"
+	"github.com/yodo-io/ycp/pkg/model"
+	"github.com/yodo-io/ycp/pkg/provision"
+)
+
+func TestResourceLifecycle(t *testing.T) {
+	srv, db, teardown := mustInitServer(t)
+	defer teardown()
+	ctx := context.Background()
+	c := mustLogin(t, srv, "joe@example.org")
+
+	r, opID, err := c.CreateResource(ctx, 1, &model.Resource{Name: "soup", Type: "pot.instance.small"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, model.ResourcePending, r.Status)
+	assert.NotZero(t, opID)
+
+	mustProvision(t, db)
+	op, err := c.WaitForOperation(ctx, 1, r.ID, opID, 5*time.Millisecond)
+	if assert.NoError(t, err) {
+		assert.Equal(t, model.OperationSucceeded, op.Status)
+	}
+
+	r, opID, err = c.UpdateResource(ctx, 1, r.ID, client.ResourcePatch{Name: "stew"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "stew", r.Name)
+		assert.Zero(t, opID)
+	}
+
+	res, p, err := c.ListResources(ctx, 1, &client.ListOptions{Filter: map[string]string{"name": "stew"}})
+	if assert.NoError(t, err) && assert.Len(t, res, 1) {
+		assert.Equal(t, r.ID, res[0].ID)
+		assert.Equal(t, 1, p.Total)
+	}
+
+	_, opID, err = c.ResourceAction(ctx, 1, r.ID, "stop")
+	if assert.NoError(t, err) {
+		mustProvision(t, db)
+		_, err = c.WaitForOperation(ctx, 1, r.ID, opID, 5*time.Millisecond)
+		assert.NoError(t, err)
+	}
+	r, err = c.GetResource(ctx, 1, r.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, model.ResourceStopped, r.Status)
+	}
+
+	_, opID, err = c.DeleteResource(ctx, 1, r.ID)
+	if assert.NoError(t, err) {
+		mustProvision(t, db)
+		_, err = c.WaitForOperation(ctx, 1, r.ID, opID, 5*time.Millisecond)
+		assert.NoError(t, err)
+	}
+	ops, err := c.ListOperations(ctx, 1, r.ID)
+	if assert.NoError(t, err) {
+		assert.Len(t, ops, 3)
+	}
+}
+
+// Run all pending operations, as the provisioning worker would in the background
+func mustProvision(t *testing.T, db *gorm.DB) {
+	if _, err := provision.NewWorker(db, provision.Nop()).ProcessPending(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitForOperationCancel(t *testing.T) {
+	srv, _, teardown := mustInitServer(t)
+	defer teardown()
+	c := mustLogin(t, srv, "joe@example.org")
+
+	r, opID, err := c.CreateResource(context.Background(), 1, &model.Resource{Name: "soup", Type: "pot.instance.small"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	// nothing processes the operation, so waiting ends with the context
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = c.WaitForOperation(ctx, 1, r.ID, opID, 5*time.Millisecond)
+	assert.Equal(t, context.DeadlineExceeded, err)
+}
+
+func TestCreateResourceErrors(t *testing.T) {
+	srv, _, teardown := mustInitServer(t)
+	defer teardown()
+	ctx := context.Background()
+	c := mustLogin(t, srv, "joe@example.org")
+	admin := mustLogin(t, srv, "admin@example.org")
+
+	if _, err := admin.SetQuota(ctx, 1, "pot.instance.small", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		projectID uint
+		resource  *model.Resource
+		err       *client.Error
+		field     string
+	}{
+		{projectID: 1, resource: &model.Resource{Name: "soup", Type: "pot.instance.small"}, err: client.ErrQuotaExceeded},
+		{projectID: 1, resource: &model.Resource{Name: "soup", Type: "pot.instance.huge"}, err: client.ErrInvalidResourceType},
+		{projectID: 1, resource: &model.Resource{Type: "pot.instance.small"}, err: client.ErrValidationFailed, field: "Name"},
+		{projectID: 2, resource: &model.Resource{Name: "soup", Type: "pot.instance.small"}, err: client.ErrForbidden},
+	}
+	for _, tt := range tests {
+		_, _, err := c.CreateResource(ctx, tt.projectID, tt.resource)
+		if !assert.True(t, tt.err.Is(err), "%v: %v", tt.resource, err) {
+			continue
+		}
+		e := err.(*client.Error)
+		assert.NotEmpty(t, e.Message)
+		if tt.field != "" && assert.Len(t, e.Details, 1) {
+			assert.Equal(t, tt.field, e.Details[0].Field)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+// UserPatch changes a user, empty fields are left unchanged
+type UserPatch struct {
+	Email    string       `json:"email,omitempty"`
+	Roles    []model.Role `json:"roles,omitempty"`
+	Password string       `json:"password,omitempty"`
+}
+
+// ListUsers lists users, they can be filtered by "email" and "role"
+func (c *Client) ListUsers(ctx context.Context, opts *ListOptions) ([]*model.User, *Page, error) {
+	var res []*model.User
+	p, err := c.list(ctx, "/users", opts, &res)
+	return res, p, err
+}
+
+// GetUser gets a user by ID
+func (c *Client) GetUser(ctx context.Context, id uint) (*model.User, error) {
+	var res model.User
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/users/%d", id), nil, nil, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// CreateUser creates a user with an email, password and optionally roles
+func (c *Client) CreateUser(ctx context.Context, u *model.User) (*model.User, error) {
+	var res model.User
+	if err := c.do(ctx, http.MethodPost, "/users", nil, u, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// UpdateUser changes a user
+func (c *Client) UpdateUser(ctx context.Context, id uint, up UserPatch) (*model.User, error) {
+	var res model.User
+	if err := c.do(ctx, http.MethodPatch, fmt.Sprintf("/users/%d", id), nil, up, &res); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// DeleteUser deletes a user and revokes their tokens
+func (c *Client) DeleteUser(ctx context.Context, id uint) error {
+	return c.do(ctx, http.MethodDelete, fmt.Sprintf("/users/%d", id), nil, nil, nil)
+}
@@ -0,0 +1,50 @@
+package client_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/client"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+func TestUsers(t *testing.T) {
+	srv, _, teardown := mustInitServer(t)
+	defer teardown()
+	ctx := context.Background()
+	admin := mustLogin(t, srv, "admin@example.org")
+
+	u, err := admin.CreateUser(ctx, &model.User{Email: "jane@example.org", Password: "secret"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotZero(t, u.ID)
+	assert.Empty(t, u.Password)
+
+	// new users can log in right away
+	c := mustLogin(t, srv, "jane@example.org")
+	res, err := c.GetUser(ctx, u.ID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, u.Email, res.Email)
+	}
+
+	res, err = admin.UpdateUser(ctx, u.ID, client.UserPatch{Email: "jane@example.com"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, "jane@example.com", res.Email)
+	}
+
+	users, p, err := admin.ListUsers(ctx, &client.ListOptions{Filter: map[string]string{"email": "jane@example.com"}})
+	if assert.NoError(t, err) && assert.Len(t, users, 1) {
+		assert.Equal(t, u.ID, users[0].ID)
+		assert.Equal(t, 1, p.Total)
+	}
+
+	// users can't list others
+	_, _, err = c.ListUsers(ctx, nil)
+	assert.True(t, client.ErrForbidden.Is(err), "%v", err)
+
+	assert.NoError(t, admin.DeleteUser(ctx, u.ID))
+	_, err = admin.GetUser(ctx, u.ID)
+	assert.True(t, client.ErrNotFound.Is(err), "%v", err)
+}
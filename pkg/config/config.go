@@ -0,0 +1,238 @@
+/*
+Package config implements the server configuration.
+
+Configuration values are read from several sources. In order of precedence, from highest to lowest:
+
+ 1. Command line flags, e.g. -addr :8080
+ 2. Environment variables, e.g. YCP_ADDR=:8080
+ 3. An optional YAML or JSON config file, given by -config or YCP_CONFIG
+ 4. Built-in defaults, see Default()
+
+A value is only taken from a source if it is explicitly set there, so a config file can override
+a single value while keeping all other defaults.
+
+The built-in JWT secret is only meant for local development. Validate refuses it unless dev mode
+is enabled.
+*/
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	"github.com/yodo-io/ycp/pkg/logging"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DefaultSecret is the JWT secret used if none is configured. Only allowed in dev mode.
+const DefaultSecret = "secret"
+
+// Config holds the server configuration. Field names double as keys in the config file.
+type Config struct {
+	Addr       string `yaml:"addr"`
+	DBDriver   string `yaml:"dbDriver"`
+	DBString   string `yaml:"dbString"`
+	Secret     string `yaml:"secret"`
+	SampleData bool   `yaml:"sampleData"`
+	Dev        bool   `yaml:"dev"`
+
+	// RBACPolicy is the path to a YAML/JSON file with RBAC rules. If empty, rules are read from the DB.
+	RBACPolicy string `yaml:"rbacPolicy"`
+
+	// LogLevel is the minimum level of log entries written, one of debug, info, warn or error
+	LogLevel string `yaml:"logLevel"`
+
+	// AuditLog is the path to a file audit records are appended to as JSON lines, in addition to the DB
+	AuditLog string `yaml:"auditLog"`
+
+	// Resources are provisioned by the fake provider, simulating latency and failures for demos
+	FakeLatency     time.Duration `yaml:"fakeLatency"`
+	FakeFailureRate float64       `yaml:"fakeFailureRate"`
+
+	// Args holds the positional arguments left after parsing flags
+	Args []string `yaml:"-"`
+}
+
+// A single config option, tying flag name, env var and config field together.
+// Exactly one of the value funcs must be set, depending on the field's type.
+type option struct {
+	flag     string
+	env      string
+	usage    string
+	strVal   func(c *Config) *string
+	boolVal  func(c *Config) *bool
+	durVal   func(c *Config) *time.Duration
+	floatVal func(c *Config) *float64
+}
+
+var options = []option{
+	{flag: "addr", env: "YCP_ADDR", usage: "address to listen on", strVal: func(c *Config) *string { return &c.Addr }},
+	{flag: "db-driver", env: "YCP_DB_DRIVER", usage: "database driver", strVal: func(c *Config) *string { return &c.DBDriver }},
+	{flag: "db", env: "YCP_DB_STRING", usage: "database connection string", strVal: func(c *Config) *string { return &c.DBString }},
+	{flag: "secret", env: "YCP_SECRET", usage: "secret used to sign auth tokens", strVal: func(c *Config) *string { return &c.Secret }},
+	{flag: "sample-data", env: "YCP_SAMPLE_DATA", usage: "load sample data on startup", boolVal: func(c *Config) *bool { return &c.SampleData }},
+	{flag: "rbac-policy", env: "YCP_RBAC_POLICY", usage: "RBAC policy file, rules are read from the database if not set", strVal: func(c *Config) *string { return &c.RBACPolicy }},
+	{flag: "log-level", env: "YCP_LOG_LEVEL", usage: "minimum level of log entries: debug, info, warn or error", strVal: func(c *Config) *string { return &c.LogLevel }},
+	{flag: "audit-log", env: "YCP_AUDIT_LOG", usage: "file to append audit records to as JSON lines", strVal: func(c *Config) *string { return &c.AuditLog }},
+	{flag: "dev", env: "YCP_DEV", usage: "enable development mode", boolVal: func(c *Config) *bool { return &c.Dev }},
+	{flag: "fake-latency", env: "YCP_FAKE_LATENCY", usage: "latency of the fake provider, e.g. 5s", durVal: func(c *Config) *time.Duration { return &c.FakeLatency }},
+	{flag: "fake-failure-rate", env: "YCP_FAKE_FAILURE_RATE", usage: "probability of fake provider calls failing, between 0 and 1", floatVal: func(c *Config) *float64 { return &c.FakeFailureRate }},
+}
+
+// Default returns the built-in default configuration
+func Default() *Config {
+	return &Config{
+		Addr:       ":9000",
+		DBDriver:   "sqlite3",
+		DBString:   ":memory:",
+		Secret:     DefaultSecret,
+		SampleData: true,
+		LogLevel:   "info",
+	}
+}
+
+// Load reads the configuration from command line args, environment and config file.
+// getenv is used to look up environment variables, usually os.Getenv.
+func Load(name string, args []string, getenv func(string) string) (*Config, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+
+	// flags are parsed into a scratch config, and only applied if explicitly set
+	var fc Config
+	def := Default()
+	for _, o := range options {
+		usage := fmt.Sprintf("%s (env %s)", o.usage, o.env)
+		switch {
+		case o.strVal != nil:
+			fs.StringVar(o.strVal(&fc), o.flag, *o.strVal(def), usage)
+		case o.boolVal != nil:
+			fs.BoolVar(o.boolVal(&fc), o.flag, *o.boolVal(def), usage)
+		case o.durVal != nil:
+			fs.DurationVar(o.durVal(&fc), o.flag, *o.durVal(def), usage)
+		default:
+			fs.Float64Var(o.floatVal(&fc), o.flag, *o.floatVal(def), usage)
+		}
+	}
+	path := fs.String("config", "", "path to YAML or JSON config file (env YCP_CONFIG)")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	c := Default()
+
+	// config file
+	if !set["config"] {
+		*path = getenv("YCP_CONFIG")
+	}
+	if *path != "" {
+		if err := c.loadFile(*path); err != nil {
+			return nil, err
+		}
+	}
+
+	// env
+	for _, o := range options {
+		v := getenv(o.env)
+		if v == "" {
+			continue
+		}
+		if err := o.parse(c, v); err != nil {
+			return nil, fmt.Errorf("Invalid value %q for %s: %v", v, o.env, err)
+		}
+	}
+
+	// flags
+	for _, o := range options {
+		if !set[o.flag] {
+			continue
+		}
+		switch {
+		case o.strVal != nil:
+			*o.strVal(c) = *o.strVal(&fc)
+		case o.boolVal != nil:
+			*o.boolVal(c) = *o.boolVal(&fc)
+		case o.durVal != nil:
+			*o.durVal(c) = *o.durVal(&fc)
+		default:
+			*o.floatVal(c) = *o.floatVal(&fc)
+		}
+	}
+
+	c.Args = fs.Args()
+	return c, nil
+}
+
+// Parse a string value, e.g. from an env var, into the option's field of c
+func (o option) parse(c *Config, v string) error {
+	switch {
+	case o.strVal != nil:
+		*o.strVal(c) = v
+	case o.boolVal != nil:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*o.boolVal(c) = b
+	case o.durVal != nil:
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*o.durVal(c) = d
+	default:
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		*o.floatVal(c) = f
+	}
+	return nil
+}
+
+// Read config file, JSON is a subset of YAML so a single parser will do
+func (c *Config) loadFile(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := yaml.UnmarshalStrict(b, c); err != nil {
+		return fmt.Errorf("Invalid config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// Validate checks the configuration is usable, it should be called before starting the server
+func (c *Config) Validate() error {
+	if c.Addr == "" {
+		return errors.New("Listen address must not be empty")
+	}
+	if c.DBDriver == "" {
+		return errors.New("Database driver must not be empty")
+	}
+	if c.DBString == "" {
+		return errors.New("Database connection string must not be empty")
+	}
+	if c.Secret == "" {
+		return errors.New("Secret must not be empty")
+	}
+	if c.Secret == DefaultSecret && !c.Dev {
+		return errors.New("Refusing to use default secret outside of dev mode, set YCP_SECRET or enable dev mode")
+	}
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		return err
+	}
+	if c.FakeLatency < 0 {
+		return errors.New("Fake provider latency must not be negative")
+	}
+	if c.FakeFailureRate < 0 || c.FakeFailureRate > 1 {
+		return errors.New("Fake provider failure rate must be between 0 and 1")
+	}
+	return nil
+}
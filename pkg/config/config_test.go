@@ -0,0 +1,184 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Creates a getenv func from a map
+func env(m map[string]string) func(string) string {
+	return func(k string) string {
+		return m[k]
+	}
+}
+
+func mustWriteFile(t *testing.T, name, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "ycp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	c, err := Load("ycp", nil, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, Default(), withoutArgs(c))
+}
+
+func TestPrecedence(t *testing.T) {
+	path, td := mustWriteFile(t, "ycp.yaml", "addr: :7000\ndbString: ycp.db\nsecret: from-file\n")
+	defer td()
+
+	tests := []struct {
+		args []string
+		env  map[string]string
+		addr string
+		db   string
+		sec  string
+	}{
+		// file only
+		{
+			args: []string{"-config", path},
+			addr: ":7000", db: "ycp.db", sec: "from-file",
+		},
+		// file path from env
+		{
+			env:  map[string]string{"YCP_CONFIG": path},
+			addr: ":7000", db: "ycp.db", sec: "from-file",
+		},
+		// env overrides file
+		{
+			args: []string{"-config", path},
+			env:  map[string]string{"YCP_ADDR": ":8000"},
+			addr: ":8000", db: "ycp.db", sec: "from-file",
+		},
+		// flag overrides env and file
+		{
+			args: []string{"-config", path, "-addr", ":9999"},
+			env:  map[string]string{"YCP_ADDR": ":8000", "YCP_SECRET": "from-env"},
+			addr: ":9999", db: "ycp.db", sec: "from-env",
+		},
+	}
+
+	for _, tt := range tests {
+		c, err := Load("ycp", tt.args, env(tt.env))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		assert.Equal(t, tt.addr, c.Addr)
+		assert.Equal(t, tt.db, c.DBString)
+		assert.Equal(t, tt.sec, c.Secret)
+		assert.Equal(t, "sqlite3", c.DBDriver)
+	}
+}
+
+func TestBoolOptions(t *testing.T) {
+	c, err := Load("ycp", []string{"-sample-data=false"}, env(map[string]string{"YCP_DEV": "true"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, c.SampleData)
+	assert.True(t, c.Dev)
+
+	_, err = Load("ycp", nil, env(map[string]string{"YCP_DEV": "maybe"}))
+	assert.Error(t, err)
+}
+
+func TestFakeProviderOptions(t *testing.T) {
+	c, err := Load("ycp", []string{"-fake-latency=2s"}, env(map[string]string{
+		"YCP_FAKE_LATENCY":      "1s",
+		"YCP_FAKE_FAILURE_RATE": "0.25",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2*time.Second, c.FakeLatency)
+	assert.Equal(t, 0.25, c.FakeFailureRate)
+
+	_, err = Load("ycp", nil, env(map[string]string{"YCP_FAKE_LATENCY": "soon"}))
+	assert.Error(t, err)
+	_, err = Load("ycp", nil, env(map[string]string{"YCP_FAKE_FAILURE_RATE": "often"}))
+	assert.Error(t, err)
+}
+
+func TestJSONConfigFile(t *testing.T) {
+	path, td := mustWriteFile(t, "ycp.json", `{"addr": ":7000", "dev": true}`)
+	defer td()
+
+	c, err := Load("ycp", []string{"-config", path}, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ":7000", c.Addr)
+	assert.True(t, c.Dev)
+}
+
+func TestInvalidConfigFile(t *testing.T) {
+	path, td := mustWriteFile(t, "ycp.yaml", "listen: :7000\n")
+	defer td()
+
+	_, err := Load("ycp", []string{"-config", path}, env(nil))
+	assert.Error(t, err)
+
+	_, err = Load("ycp", []string{"-config", path + ".missing"}, env(nil))
+	assert.Error(t, err)
+}
+
+func TestPositionalArgs(t *testing.T) {
+	c, err := Load("ycp", []string{"-dev", "migrate", "up"}, env(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"migrate", "up"}, c.Args)
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		mod func(c *Config)
+		ok  bool
+	}{
+		{mod: func(c *Config) {}, ok: false}, // default secret
+		{mod: func(c *Config) { c.Dev = true }, ok: true},
+		{mod: func(c *Config) { c.Secret = "be00d27d0c134cc7" }, ok: true},
+		{mod: func(c *Config) { c.Dev = true; c.Secret = "" }, ok: false},
+		{mod: func(c *Config) { c.Dev = true; c.Addr = "" }, ok: false},
+		{mod: func(c *Config) { c.Dev = true; c.DBDriver = "" }, ok: false},
+		{mod: func(c *Config) { c.Dev = true; c.DBString = "" }, ok: false},
+		{mod: func(c *Config) { c.Dev = true; c.FakeFailureRate = 1 }, ok: true},
+		{mod: func(c *Config) { c.Dev = true; c.FakeFailureRate = 1.5 }, ok: false},
+		{mod: func(c *Config) { c.Dev = true; c.FakeLatency = -time.Second }, ok: false},
+		{mod: func(c *Config) { c.Dev = true; c.LogLevel = "DEBUG" }, ok: true},
+		{mod: func(c *Config) { c.Dev = true; c.LogLevel = "verbose" }, ok: false},
+	}
+
+	for i, tt := range tests {
+		c := Default()
+		tt.mod(c)
+		err := c.Validate()
+		if tt.ok {
+			assert.NoError(t, err, "case %d", i)
+		} else {
+			assert.Error(t, err, "case %d", i)
+		}
+	}
+}
+
+func withoutArgs(c *Config) *Config {
+	c.Args = nil
+	return c
+}
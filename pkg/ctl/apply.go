@@ -0,0 +1,103 @@
+package ctl
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/yodo-io/ycp/pkg/manifest"
+)
+
+func planTable(p *manifest.Plan) *table {
+	t := newTable("ACTION", "KIND", "PROJECT", "NAME", "CHANGE", "PROBLEM")
+	for _, c := range p.Changes {
+		change := c.To
+		if c.From != "" && c.To != "" {
+			change = c.From + " -> " + c.To
+		} else if c.From != "" {
+			change = c.From
+		}
+		t.add(c.Action, c.Kind, c.ProjectID, c.Name, change, c.Problem)
+	}
+	return t
+}
+
+// Read the manifest given by -f and plan the changes to converge to it
+func (c *cli) plan(file string) (*manifest.Plan, error) {
+	if file == "" {
+		return nil, errors.New("-f is required")
+	}
+	var m *manifest.Manifest
+	var err error
+	if file == "-" {
+		m, err = manifest.Parse(c.stdin)
+	} else {
+		m, err = manifest.Load(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	api, err := c.client()
+	if err != nil {
+		return nil, err
+	}
+	return manifest.NewPlan(c.ctx, api, m)
+}
+
+// Fail if the plan has problems, after printing it
+func checkPlan(p *manifest.Plan) error {
+	if n := len(p.Problems()); n > 0 {
+		return fmt.Errorf("Plan has %d problem(s), fix them before applying the manifest", n)
+	}
+	return nil
+}
+
+// plan -f file, fails if the plan has problems
+func plan(c *cli, args []string) error {
+	fs := c.flags("plan", "-f file")
+	file := fs.String("f", "", `manifest file, "-" for stdin`)
+	if _, err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+	p, err := c.plan(*file)
+	if err != nil {
+		return err
+	}
+	if err := c.print(p, func() *table { return planTable(p) }); err != nil {
+		return err
+	}
+	if len(p.Changes) == 0 {
+		c.info("Nothing to do, the projects match the manifest")
+	}
+	return checkPlan(p)
+}
+
+// apply -f file, prints the plan and applies it unless it has problems
+func apply(c *cli, args []string) error {
+	fs := c.flags("apply", "-f file")
+	file := fs.String("f", "", `manifest file, "-" for stdin`)
+	if _, err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+	p, err := c.plan(*file)
+	if err != nil {
+		return err
+	}
+	if len(p.Changes) == 0 {
+		c.info("Nothing to do, the projects match the manifest")
+		return nil
+	}
+	if err := c.print(p, func() *table { return planTable(p) }); err != nil {
+		return err
+	}
+	if err := checkPlan(p); err != nil {
+		return err
+	}
+	err = p.Apply(c.ctx, c.api, pollInterval, func(ch *manifest.Change) {
+		c.info("Done: %s", ch)
+	})
+	if err != nil {
+		return err
+	}
+	c.info("Applied %d change(s)", len(p.Changes))
+	return nil
+}
@@ -0,0 +1,183 @@
+package ctl
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/yodo-io/ycp/pkg/client"
+)
+
+// Name of the context created by the first login, unless -context is given
+const defaultContext = "default"
+
+// login [-server url] [-email email] [-password password]
+func login(c *cli, args []string) error {
+	fs := c.flags("login", "[-server url] [-email email] [-password password]")
+	server := fs.String("server", "", "server URL, e.g. http://localhost:9000, defaults to the context's")
+	email := fs.String("email", "", "email to log in with, defaults to the context's or is prompted for")
+	password := fs.String("password", "", "password, read from YCP_PASSWORD or prompted for if not set")
+	if _, err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+
+	name := c.contextName
+	if name == "" {
+		name = c.config.Current
+	}
+	if name == "" {
+		name = defaultContext
+	}
+	session := c.config.Contexts[name]
+	if session == nil {
+		session = &Context{}
+	}
+	if *server != "" {
+		session.Server = *server
+	}
+	if session.Server == "" {
+		return errors.New("-server is required for new contexts")
+	}
+	if *email != "" {
+		session.Email = *email
+	}
+	var err error
+	if session.Email == "" {
+		if session.Email, err = c.prompt("Email: ", false); err != nil {
+			return err
+		}
+	}
+	pw := *password
+	if pw == "" {
+		pw = c.getenv("YCP_PASSWORD")
+	}
+	if pw == "" {
+		if pw, err = c.prompt("Password: ", true); err != nil {
+			return err
+		}
+	}
+
+	api, err := client.New(session.Server)
+	if err != nil {
+		return err
+	}
+	if err := api.Login(c.ctx, session.Email, pw); err != nil {
+		return err
+	}
+	session.Token, session.RefreshToken = api.Tokens()
+	c.config.Contexts[name] = session
+	c.config.Current = name
+	if err := c.config.Save(c.configPath); err != nil {
+		return err
+	}
+	c.info("Logged in to %s as %s, using context %s", session.Server, session.Email, name)
+	return nil
+}
+
+// logout
+func logout(c *cli, args []string) error {
+	if _, err := c.parse(c.flags("logout", ""), args, 0); err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	// forget the session even if revoking it fails, e.g. because it has expired already
+	err = api.Logout(c.ctx)
+	c.session.Token, c.session.RefreshToken = "", ""
+	if serr := c.config.Save(c.configPath); serr != nil {
+		return serr
+	}
+	if err != nil {
+		return err
+	}
+	c.info("Logged out of %s", c.session.Server)
+	return nil
+}
+
+var contextCommands = map[string]func(c *cli, args []string) error{
+	"list":   listContexts,
+	"use":    useContext,
+	"delete": deleteContext,
+}
+
+// context list|use|delete
+func contexts(c *cli, args []string) error {
+	return c.subcommand("context", contextCommands, args)
+}
+
+// Contexts as printed, without tokens
+type contextInfo struct {
+	Name     string `json:"name"`
+	Server   string `json:"server"`
+	Email    string `json:"email,omitempty"`
+	LoggedIn bool   `json:"loggedIn"`
+	Current  bool   `json:"current"`
+}
+
+// context list
+func listContexts(c *cli, args []string) error {
+	if _, err := c.parse(c.flags("context list", ""), args, 0); err != nil {
+		return err
+	}
+	res := []*contextInfo{}
+	for name, ctx := range c.config.Contexts {
+		res = append(res, &contextInfo{
+			Name:     name,
+			Server:   ctx.Server,
+			Email:    ctx.Email,
+			LoggedIn: ctx.Token != "",
+			Current:  name == c.config.Current,
+		})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return c.print(res, func() *table {
+		t := newTable("CURRENT", "NAME", "SERVER", "EMAIL", "LOGGED IN")
+		for _, ci := range res {
+			current := ""
+			if ci.Current {
+				current = "*"
+			}
+			t.add(current, ci.Name, ci.Server, ci.Email, ci.LoggedIn)
+		}
+		return t
+	})
+}
+
+// context use <name>
+func useContext(c *cli, args []string) error {
+	args, err := c.parse(c.flags("context use", "<name>"), args, 1)
+	if err != nil {
+		return err
+	}
+	if _, ok := c.config.Contexts[args[0]]; !ok {
+		return fmt.Errorf("Unknown context: %s", args[0])
+	}
+	c.config.Current = args[0]
+	if err := c.config.Save(c.configPath); err != nil {
+		return err
+	}
+	c.info("Using context %s", args[0])
+	return nil
+}
+
+// context delete <name>, without logging out
+func deleteContext(c *cli, args []string) error {
+	args, err := c.parse(c.flags("context delete", "<name>"), args, 1)
+	if err != nil {
+		return err
+	}
+	if _, ok := c.config.Contexts[args[0]]; !ok {
+		return fmt.Errorf("Unknown context: %s", args[0])
+	}
+	delete(c.config.Contexts, args[0])
+	if c.config.Current == args[0] {
+		c.config.Current = ""
+	}
+	if err := c.config.Save(c.configPath); err != nil {
+		return err
+	}
+	c.info("Deleted context %s", args[0])
+	return nil
+}
@@ -0,0 +1,192 @@
+package ctl
+
+import (
+	"flag"
+
+	"github.com/yodo-io/ycp/pkg/client"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+var catalogCommands = map[string]func(c *cli, args []string) error{
+	"list":   listCatalog,
+	"get":    getCatalogItem,
+	"create": createCatalogItem,
+	"update": updateCatalogItem,
+	"delete": deleteCatalogItem,
+}
+
+// catalog list|get|create|update|delete, items are given by name
+func catalog(c *cli, args []string) error {
+	return c.subcommand("catalog", catalogCommands, args)
+}
+
+func catalogTable(cs ...*model.Catalog) *table {
+	t := newTable("NAME", "DISPLAY NAME", "CATEGORY", "CAPACITY", "SIZE", "HOURLY PRICE", "CURRENCY", "STATUS")
+	for _, ci := range cs {
+		t.add(ci.Name, ci.DisplayName, ci.Category, ci.Specs.Capacity, ci.Specs.Size, ci.HourlyPrice, ci.Currency, ci.Status)
+	}
+	return t
+}
+
+// Flags of catalog items, shared by create and update
+type catalogFlags struct {
+	model.Catalog
+	status string
+}
+
+const catalogUsage = "[-display-name name] [-description text] [-category category] [-capacity litres] [-size cm] " +
+	"[-price amount] [-currency currency] [-status status] [-free-when-stopped] <name>"
+
+func addCatalogFlags(fs *flag.FlagSet) *catalogFlags {
+	cf := &catalogFlags{}
+	fs.StringVar(&cf.DisplayName, "display-name", "", "name shown to users")
+	fs.StringVar(&cf.Description, "description", "", "description")
+	fs.StringVar(&cf.Category, "category", "", `category, e.g. "pot"`)
+	fs.Float64Var(&cf.Specs.Capacity, "capacity", 0, "capacity in litres")
+	fs.IntVar(&cf.Specs.Size, "size", 0, "size in centimetres")
+	fs.Int64Var(&cf.HourlyPrice, "price", 0, "hourly price in the smallest unit of the currency, e.g. cents")
+	fs.StringVar(&cf.Currency, "currency", "", `currency, e.g. "EUR"`)
+	fs.StringVar(&cf.status, "status", "", "status: available, deprecated or retired")
+	fs.BoolVar(&cf.FreeWhenStopped, "free-when-stopped", false, "don't count stopped resources against quotas")
+	return cf
+}
+
+// catalog list [-category category] [-status status]
+func listCatalog(c *cli, args []string) error {
+	fs := c.flags("catalog list", "[-category category] [-status status] [-limit n] [-sort fields]")
+	category := fs.String("category", "", "only list items of this category")
+	status := fs.String("status", "", "only list items with this status")
+	lf := addListFlags(fs)
+	if _, err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	res := []*model.Catalog{}
+	err = lf.fetch(filter("category", *category, "status", *status), func(opts *client.ListOptions) (*client.Page, error) {
+		cs, p, err := api.ListCatalog(c.ctx, opts)
+		res = append(res, cs...)
+		return p, err
+	})
+	if err != nil {
+		return err
+	}
+	return c.print(res, func() *table { return catalogTable(res...) })
+}
+
+// catalog get <name>
+func getCatalogItem(c *cli, args []string) error {
+	args, err := c.parse(c.flags("catalog get", "<name>"), args, 1)
+	if err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	ci, err := api.GetCatalogItem(c.ctx, args[0])
+	if err != nil {
+		return err
+	}
+	return c.print(ci, func() *table { return catalogTable(ci) })
+}
+
+// catalog create [flags] <name>
+func createCatalogItem(c *cli, args []string) error {
+	fs := c.flags("catalog create", catalogUsage)
+	cf := addCatalogFlags(fs)
+	args, err := c.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	item := cf.Catalog
+	item.Name = args[0]
+	item.Status = model.CatalogStatus(cf.status)
+	ci, err := api.CreateCatalogItem(c.ctx, &item)
+	if err != nil {
+		return err
+	}
+	return c.print(ci, func() *table { return catalogTable(ci) })
+}
+
+// catalog update [flags] <name>, only changes fields given by flags
+func updateCatalogItem(c *cli, args []string) error {
+	fs := c.flags("catalog update", catalogUsage)
+	cf := addCatalogFlags(fs)
+	args, err := c.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	var up client.CatalogPatch
+	status := model.CatalogStatus(cf.status)
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "display-name":
+			up.DisplayName = &cf.DisplayName
+		case "description":
+			up.Description = &cf.Description
+		case "category":
+			up.Category = &cf.Category
+		case "capacity", "size":
+			up.Specs = &cf.Specs
+		case "price":
+			up.HourlyPrice = &cf.HourlyPrice
+		case "currency":
+			up.Currency = &cf.Currency
+		case "status":
+			up.Status = &status
+		case "free-when-stopped":
+			up.FreeWhenStopped = &cf.FreeWhenStopped
+		}
+	})
+
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	if up.Specs != nil {
+		// specs are replaced as a whole, keep the one not given
+		cur, err := api.GetCatalogItem(c.ctx, args[0])
+		if err != nil {
+			return err
+		}
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "capacity" {
+				cur.Specs.Capacity = cf.Specs.Capacity
+			}
+			if f.Name == "size" {
+				cur.Specs.Size = cf.Specs.Size
+			}
+		})
+		up.Specs = &cur.Specs
+	}
+	ci, err := api.UpdateCatalogItem(c.ctx, args[0], up)
+	if err != nil {
+		return err
+	}
+	return c.print(ci, func() *table { return catalogTable(ci) })
+}
+
+// catalog delete <name>
+func deleteCatalogItem(c *cli, args []string) error {
+	args, err := c.parse(c.flags("catalog delete", "<name>"), args, 1)
+	if err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	if err := api.DeleteCatalogItem(c.ctx, args[0]); err != nil {
+		return err
+	}
+	c.info("Deleted catalog item %s", args[0])
+	return nil
+}
@@ -0,0 +1,87 @@
+package ctl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Config is the config file of ycpctl. It keeps servers and the sessions on them as named contexts,
+// commands use the current one unless another is selected with -context.
+type Config struct {
+	Current  string              `yaml:"current,omitempty"`
+	Contexts map[string]*Context `yaml:"contexts,omitempty"`
+}
+
+// Context is a server along with the session of a user on it. The password isn't kept, once the
+// refresh token has expired users have to log in again.
+type Context struct {
+	Server       string `yaml:"server"`
+	Email        string `yaml:"email,omitempty"`
+	Token        string `yaml:"token,omitempty"`
+	RefreshToken string `yaml:"refreshToken,omitempty"`
+}
+
+// DefaultConfigPath is where the config file is kept unless YCPCTL_CONFIG is set, relative to the home dir
+const DefaultConfigPath = ".ycp/config.yaml"
+
+// Path of the config file, given by env or in the user's home dir
+func configPath(getenv func(string) string) string {
+	if p := getenv("YCPCTL_CONFIG"); p != "" {
+		return p
+	}
+	return filepath.Join(getenv("HOME"), DefaultConfigPath)
+}
+
+// LoadConfig reads the config file at path. A missing file is an empty config, as before the first login.
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{Contexts: map[string]*Context{}}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.UnmarshalStrict(b, cfg); err != nil {
+		return nil, fmt.Errorf("Invalid config file %s: %v", path, err)
+	}
+	if cfg.Contexts == nil {
+		cfg.Contexts = map[string]*Context{}
+	}
+	return cfg, nil
+}
+
+// Save writes the config to path. As it holds tokens, only the user may read it.
+func (c *Config) Save(path string) error {
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of existing files
+	return os.Chmod(path, 0600)
+}
+
+// Context returns the context with the given name, or the current one if name is empty
+func (c *Config) Context(name string) (*Context, error) {
+	if name == "" {
+		name = c.Current
+	}
+	if name == "" {
+		return nil, fmt.Errorf("No context selected, log in with: ycpctl login -server <url>")
+	}
+	ctx, ok := c.Contexts[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown context: %s", name)
+	}
+	return ctx, nil
+}
@@ -0,0 +1,71 @@
+package ctl
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mustTempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "ycpctl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestConfigPath(t *testing.T) {
+	env := map[string]string{"HOME": "/home/joe"}
+	assert.Equal(t, "/home/joe/.ycp/config.yaml", configPath(func(k string) string { return env[k] }))
+	env["YCPCTL_CONFIG"] = "/etc/ycpctl.yaml"
+	assert.Equal(t, "/etc/ycpctl.yaml", configPath(func(k string) string { return env[k] }))
+}
+
+func TestConfigSaveLoad(t *testing.T) {
+	dir, cleanup := mustTempDir(t)
+	defer cleanup()
+	path := filepath.Join(dir, "sub", "config.yaml")
+
+	// missing file is an empty config
+	cfg, err := LoadConfig(path)
+	if assert.NoError(t, err) {
+		assert.Empty(t, cfg.Current)
+		assert.Empty(t, cfg.Contexts)
+	}
+	_, err = cfg.Context("")
+	assert.Error(t, err)
+
+	cfg.Current = "prod"
+	cfg.Contexts["prod"] = &Context{Server: "https://ycp.example.org", Email: "joe@example.org", Token: "t", RefreshToken: "r"}
+	cfg.Contexts["dev"] = &Context{Server: "http://localhost:9000"}
+	if !assert.NoError(t, cfg.Save(path)) {
+		return
+	}
+	fi, err := os.Stat(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	}
+
+	res, err := LoadConfig(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, cfg, res)
+	}
+	ctx, err := res.Context("")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "t", ctx.Token)
+	}
+	ctx, err = res.Context("dev")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "http://localhost:9000", ctx.Server)
+	}
+	_, err = res.Context("staging")
+	assert.Error(t, err)
+
+	// unknown keys are most likely typos
+	assert.NoError(t, ioutil.WriteFile(path, []byte("curent: prod\n"), 0600))
+	_, err = LoadConfig(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,316 @@
+/*
+Package ctl implements ycpctl, the command line client of ycp:
+
+	ycpctl [-context name] [-o table|json|yaml] <command> <subcommand> [flags] [args]
+
+Log in once per server, the session is kept in the config file and renewed as needed:
+
+	ycpctl -context prod login -server https://ycp.example.org -email joe@example.org
+	ycpctl resources list -project 1
+	ycpctl -o json users get 2
+
+Commands act on the current context, set by the last login or with `ycpctl context use <name>`, unless
+another one is selected with -context. Flags of subcommands go before their arguments.
+*/
+package ctl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yodo-io/ycp/pkg/client"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// Returned for invalid command lines, after printing their usage
+var errUsage = errors.New("invalid usage")
+
+// How often to poll operations with -wait
+var pollInterval = time.Second
+
+// Commands by name, each with subcommands of its own
+var commands = map[string]func(c *cli, args []string) error{
+	"login":     login,
+	"logout":    logout,
+	"context":   contexts,
+	"users":     users,
+	"resources": resources,
+	"quotas":    quotas,
+	"catalog":   catalog,
+	"plan":      plan,
+	"apply":     apply,
+}
+
+const usage = `Usage: ycpctl [flags] <command> <subcommand> [flags] [args]
+
+Commands:
+  login                              log in to a server, creating or updating a context
+  logout                             log out and revoke the session
+  context list|use|delete            manage contexts
+  users list|get|create|update|delete
+  resources list|get|create|update|delete
+  quotas list|get|create|update|delete|usage
+  catalog list|get|create|update|delete
+  plan -f file                       show the changes needed to converge projects to a manifest
+  apply -f file                      converge projects to a manifest
+
+Flags:
+`
+
+// State of a single run of ycpctl
+type cli struct {
+	ctx    context.Context
+	stdin  *bufio.Reader
+	stdinF *os.File
+	stdout io.Writer
+	stderr io.Writer
+	getenv func(string) string
+
+	format      string
+	contextName string
+	configPath  string
+	config      *Config
+
+	// API client of the selected context and its session, set once a command needs them
+	api     *client.Client
+	session *Context
+}
+
+// Main runs ycpctl with the given args, without the program name, and returns its exit code.
+// Cancelling ctx aborts requests in flight.
+func Main(ctx context.Context, args []string, stdin io.Reader, stdout, stderr io.Writer, getenv func(string) string) int {
+	c := &cli{ctx: ctx, stdin: bufio.NewReader(stdin), stdout: stdout, stderr: stderr, getenv: getenv}
+	if f, ok := stdin.(*os.File); ok {
+		c.stdinF = f
+	}
+	err := c.run(args)
+	// keep tokens renewed during the command, even if it failed later on
+	if serr := c.saveSession(); serr != nil && err == nil {
+		err = serr
+	}
+	switch {
+	case err == nil:
+		return 0
+	case err == flag.ErrHelp:
+		return 0
+	case err == errUsage:
+		return 2
+	}
+	printError(stderr, err)
+	return 1
+}
+
+func (c *cli) run(args []string) error {
+	fs := flag.NewFlagSet("ycpctl", flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprint(c.stderr, usage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&c.configPath, "config", configPath(c.getenv), "config file, can also be set with YCPCTL_CONFIG")
+	fs.StringVar(&c.contextName, "context", "", "context to use instead of the current one")
+	fs.StringVar(&c.format, "o", FormatTable, "output format: "+strings.Join(formats, ", "))
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return errUsage
+	}
+	if !validFormat(c.format) {
+		return fmt.Errorf("Invalid output format %q, must be one of: %s", c.format, strings.Join(formats, ", "))
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	cfg, err := LoadConfig(c.configPath)
+	if err != nil {
+		return err
+	}
+	c.config = cfg
+
+	name := fs.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("Unknown command: %s", name)
+	}
+	return cmd(c, fs.Args()[1:])
+}
+
+// Run the subcommand of a command given by the first arg, e.g. "list" for "users"
+func (c *cli) subcommand(name string, cmds map[string]func(c *cli, args []string) error, args []string) error {
+	names := make([]string, 0, len(cmds))
+	for n := range cmds {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	if len(args) == 0 {
+		fmt.Fprintf(c.stderr, "Usage: ycpctl %s %s\n", name, strings.Join(names, "|"))
+		return errUsage
+	}
+	cmd, ok := cmds[args[0]]
+	if !ok {
+		return fmt.Errorf("Unknown %s command: %s, must be one of: %s", name, args[0], strings.Join(names, ", "))
+	}
+	return cmd(c, args[1:])
+}
+
+// Flags of a subcommand, args describes the flags and arguments it takes for its usage
+func (c *cli) flags(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: ycpctl %s %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// Parse the flags of a subcommand, which takes n args
+func (c *cli) parse(fs *flag.FlagSet, args []string, n int) ([]string, error) {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil, err
+		}
+		return nil, errUsage
+	}
+	if fs.NArg() != n {
+		fs.Usage()
+		return nil, errUsage
+	}
+	return fs.Args(), nil
+}
+
+// Print the result of a command to stdout in the selected format
+func (c *cli) print(v interface{}, tbl func() *table) error {
+	return printValue(c.stdout, c.format, v, tbl)
+}
+
+// Tell the user what happened, on stderr so it doesn't mix with output meant for other programs
+func (c *cli) info(format string, args ...interface{}) {
+	fmt.Fprintf(c.stderr, format+"\n", args...)
+}
+
+// Read a line from stdin after printing a prompt. Hidden input isn't echoed if stdin is a terminal.
+func (c *cli) prompt(prompt string, hidden bool) (string, error) {
+	fmt.Fprint(c.stderr, prompt)
+	if hidden && c.stdinF != nil && terminal.IsTerminal(int(c.stdinF.Fd())) {
+		b, err := terminal.ReadPassword(int(c.stdinF.Fd()))
+		fmt.Fprintln(c.stderr)
+		return string(b), err
+	}
+	s, err := c.stdin.ReadString('\n')
+	if err != nil && (err != io.EOF || s == "") {
+		return "", fmt.Errorf("Failed to read input: %v", err)
+	}
+	return strings.TrimSpace(s), nil
+}
+
+// Client of the selected context, with its session
+func (c *cli) client() (*client.Client, error) {
+	session, err := c.config.Context(c.contextName)
+	if err != nil {
+		return nil, err
+	}
+	if session.Token == "" {
+		return nil, fmt.Errorf("Not logged in to %s, log in with: ycpctl login", session.Server)
+	}
+	api, err := client.New(session.Server)
+	if err != nil {
+		return nil, err
+	}
+	api.SetTokens(session.Token, session.RefreshToken)
+	c.api, c.session = api, session
+	return api, nil
+}
+
+// Save the session if the client renewed it
+func (c *cli) saveSession() error {
+	if c.api == nil {
+		return nil
+	}
+	token, refreshToken := c.api.Tokens()
+	if token == c.session.Token && refreshToken == c.session.RefreshToken {
+		return nil
+	}
+	c.session.Token, c.session.RefreshToken = token, refreshToken
+	return c.config.Save(c.configPath)
+}
+
+func printError(w io.Writer, err error) {
+	e, ok := err.(*client.Error)
+	if !ok {
+		fmt.Fprintf(w, "Error: %v\n", err)
+		return
+	}
+	fmt.Fprintf(w, "Error: %s\n", e.Message)
+	for _, d := range e.Details {
+		fmt.Fprintf(w, "  %s: %s\n", d.Field, d.Message)
+	}
+	fmt.Fprintf(w, "Code: %s", e.Code)
+	if e.RequestID != "" {
+		fmt.Fprintf(w, ", request ID: %s", e.RequestID)
+	}
+	fmt.Fprintln(w)
+	if client.ErrUnauthorized.Is(e) || client.ErrTokenExpired.Is(e) {
+		fmt.Fprintln(w, "Your session has ended, log in again with: ycpctl login")
+	}
+}
+
+// Parse the ID of an object given as argument
+func parseID(s string) (uint, error) {
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("Invalid ID %q", s)
+	}
+	return uint(id), nil
+}
+
+// Filters of a list, from pairs of names and values. Empty values aren't filtered by.
+func filter(kv ...string) map[string]string {
+	f := map[string]string{}
+	for i := 0; i+1 < len(kv); i += 2 {
+		if kv[i+1] != "" {
+			f[kv[i]] = kv[i+1]
+		}
+	}
+	return f
+}
+
+// Flags shared by list subcommands
+type listFlags struct {
+	limit *int
+	sort  *string
+}
+
+func addListFlags(fs *flag.FlagSet) *listFlags {
+	return &listFlags{
+		limit: fs.Int("limit", 0, "maximum number of items, all if 0"),
+		sort:  fs.String("sort", "", `fields to sort by, e.g. "-name,id"`),
+	}
+}
+
+// Fetch pages of a list with the given filters, all of them unless a limit is given
+func (l *listFlags) fetch(filter map[string]string, page func(opts *client.ListOptions) (*client.Page, error)) error {
+	opts := &client.ListOptions{Limit: *l.limit, Sort: *l.sort, Filter: filter}
+	for {
+		p, err := page(opts)
+		if err != nil {
+			return err
+		}
+		if *l.limit > 0 || p.Next == "" {
+			return nil
+		}
+		opts.Cursor = p.Next
+	}
+}
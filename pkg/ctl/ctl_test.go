@@ -0,0 +1,396 @@
+package ctl
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/api/v1/auth"
+	"github.com/yodo-io/ycp/pkg/client/clienttest"
+	"github.com/yodo-io/ycp/pkg/model"
+	"github.com/yodo-io/ycp/pkg/provision"
+)
+
+func init() {
+	pollInterval = 5 * time.Millisecond
+}
+
+// Start a server with sample data
+func mustInitServer(t *testing.T) (*httptest.Server, *gorm.DB, func()) {
+	db := model.MustInitTestDB(true)
+	srv := clienttest.NewServer(db)
+	return srv, db, func() {
+		srv.Close()
+		db.Close()
+	}
+}
+
+// Run pending operations in the background, as the provisioning worker does, until the returned func is called
+func startWorker(db *gorm.DB) func() {
+	done := make(chan struct{})
+	go func() {
+		w := provision.NewWorker(db, provision.Nop())
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(5 * time.Millisecond):
+				w.ProcessPending()
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// Runs ycpctl with a config file at path
+type runner struct {
+	t     *testing.T
+	path  string
+	stdin string
+}
+
+type result struct {
+	code   int
+	stdout string
+	stderr string
+}
+
+func (r *runner) run(args ...string) result {
+	var stdout, stderr bytes.Buffer
+	env := map[string]string{"YCPCTL_CONFIG": r.path}
+	code := Main(context.Background(), args, strings.NewReader(r.stdin), &stdout, &stderr, func(k string) string { return env[k] })
+	return result{code, stdout.String(), stderr.String()}
+}
+
+// Run a command that must succeed and decode its JSON output into out
+func (r *runner) mustRunJSON(out interface{}, args ...string) {
+	res := r.run(append([]string{"-o", "json"}, args...)...)
+	if res.code != 0 {
+		r.t.Fatalf("%v failed: %s", args, res.stderr)
+	}
+	if err := json.Unmarshal([]byte(res.stdout), out); err != nil {
+		r.t.Fatalf("%v: %v\n%s", args, err, res.stdout)
+	}
+}
+
+func mustRunner(t *testing.T, srv *httptest.Server, email string) (*runner, func()) {
+	dir, cleanup := mustTempDir(t)
+	r := &runner{t: t, path: filepath.Join(dir, "config.yaml")}
+	if res := r.run("login", "-server", srv.URL, "-email", email, "-password", "secret"); res.code != 0 {
+		cleanup()
+		t.Fatalf("Login failed: %s", res.stderr)
+	}
+	return r, cleanup
+}
+
+func TestUsage(t *testing.T) {
+	dir, cleanup := mustTempDir(t)
+	defer cleanup()
+	r := &runner{t: t, path: filepath.Join(dir, "config.yaml")}
+
+	tests := []struct {
+		args   []string
+		code   int
+		stderr string
+	}{
+		{args: nil, code: 2, stderr: "Usage: ycpctl"},
+		{args: []string{"-h"}, code: 0, stderr: "Usage: ycpctl"},
+		{args: []string{"-o", "xml", "users", "list"}, code: 1, stderr: "Invalid output format"},
+		{args: []string{"foo"}, code: 1, stderr: "Unknown command: foo"},
+		{args: []string{"users"}, code: 2, stderr: "Usage: ycpctl users create|delete|get|list|update"},
+		{args: []string{"users", "foo"}, code: 1, stderr: "Unknown users command: foo"},
+		{args: []string{"users", "get"}, code: 2, stderr: "Usage: ycpctl users get <id>"},
+		{args: []string{"users", "get", "x"}, code: 1, stderr: `Invalid ID "x"`},
+		{args: []string{"users", "get", "1"}, code: 1, stderr: "No context selected"},
+		{args: []string{"resources", "list"}, code: 1, stderr: "-project is required"},
+		{args: []string{"login", "-email", "joe@example.org"}, code: 1, stderr: "-server is required"},
+	}
+	for _, tt := range tests {
+		res := r.run(tt.args...)
+		assert.Equal(t, tt.code, res.code, "%v", tt.args)
+		assert.Contains(t, res.stderr, tt.stderr, "%v", tt.args)
+	}
+}
+
+func TestLoginContexts(t *testing.T) {
+	srv, _, teardown := mustInitServer(t)
+	defer teardown()
+	r, cleanup := mustRunner(t, srv, "joe@example.org")
+	defer cleanup()
+
+	// credentials are prompted for if not given
+	r.stdin = "admin@example.org\nsecret\n"
+	res := r.run("-context", "admin", "login", "-server", srv.URL)
+	if !assert.Equal(t, 0, res.code, res.stderr) {
+		return
+	}
+	assert.Contains(t, res.stderr, "Logged in to "+srv.URL+" as admin@example.org, using context admin")
+
+	var cs []*contextInfo
+	r.mustRunJSON(&cs, "context", "list")
+	assert.Equal(t, []*contextInfo{
+		{Name: "admin", Server: srv.URL, Email: "admin@example.org", LoggedIn: true, Current: true},
+		{Name: "default", Server: srv.URL, Email: "joe@example.org", LoggedIn: true},
+	}, cs)
+
+	var u model.User
+	r.mustRunJSON(&u, "users", "get", "2")
+	assert.Equal(t, "admin@example.org", u.Email)
+
+	// joe can't read admin, but can read himself
+	res = r.run("-context", "default", "users", "get", "2")
+	assert.Equal(t, 1, res.code)
+	assert.Contains(t, res.stderr, "Code: FORBIDDEN")
+	assert.Equal(t, 0, r.run("context", "use", "default").code)
+	r.mustRunJSON(&u, "users", "get", "1")
+	assert.Equal(t, "joe@example.org", u.Email)
+
+	// session is gone after logout
+	assert.Equal(t, 0, r.run("logout").code)
+	res = r.run("users", "get", "1")
+	assert.Equal(t, 1, res.code)
+	assert.Contains(t, res.stderr, "Not logged in")
+	assert.Equal(t, 0, r.run("-context", "admin", "users", "get", "1").code)
+
+	assert.Equal(t, 0, r.run("context", "delete", "admin").code)
+	assert.Equal(t, 1, r.run("context", "use", "admin").code)
+}
+
+func TestRefreshSession(t *testing.T) {
+	srv, _, teardown := mustInitServer(t)
+	defer teardown()
+	r, cleanup := mustRunner(t, srv, "joe@example.org")
+	defer cleanup()
+
+	cl := &auth.Claims{
+		Roles:          []model.Role{model.RoleUser},
+		UserID:         1,
+		Email:          "joe@example.org",
+		StandardClaims: jwt.StandardClaims{Id: "expired", ExpiresAt: time.Now().Add(-time.Minute).Unix()},
+	}
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodHS256, cl).SignedString(clienttest.Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, _ := LoadConfig(r.path)
+	refreshToken := cfg.Contexts["default"].RefreshToken
+	cfg.Contexts["default"].Token = expired
+	if err := cfg.Save(r.path); err != nil {
+		t.Fatal(err)
+	}
+
+	// the renewed session is saved
+	res := r.run("users", "get", "1")
+	assert.Equal(t, 0, res.code, res.stderr)
+	cfg, _ = LoadConfig(r.path)
+	assert.NotEqual(t, expired, cfg.Contexts["default"].Token)
+	assert.NotEqual(t, refreshToken, cfg.Contexts["default"].RefreshToken)
+
+	// without a valid refresh token, users must log in again
+	cfg.Contexts["default"].Token, cfg.Contexts["default"].RefreshToken = expired, refreshToken
+	cfg.Save(r.path)
+	res = r.run("users", "get", "1")
+	assert.Equal(t, 1, res.code)
+	assert.Contains(t, res.stderr, "log in again")
+}
+
+func TestUsers(t *testing.T) {
+	srv, _, teardown := mustInitServer(t)
+	defer teardown()
+	r, cleanup := mustRunner(t, srv, "admin@example.org")
+	defer cleanup()
+
+	var u model.User
+	r.mustRunJSON(&u, "users", "create", "-email", "jane@example.org", "-password", "secret", "-roles", "user,auditor")
+	assert.Equal(t, []model.Role{model.RoleUser, model.RoleAuditor}, u.Roles)
+	id := itoa(u.ID)
+
+	r.mustRunJSON(&u, "users", "update", "-email", "jane@example.com", id)
+	assert.Equal(t, "jane@example.com", u.Email)
+
+	res := r.run("users", "list", "-role", "auditor")
+	assert.Equal(t, 0, res.code, res.stderr)
+	assert.Contains(t, res.stdout, "jane@example.com")
+	assert.NotContains(t, res.stdout, "joe@example.org")
+
+	var us []*model.User
+	r.mustRunJSON(&us, "users", "list", "-limit", "1", "-sort", "-id")
+	if assert.Len(t, us, 1) {
+		assert.Equal(t, u.ID, us[0].ID)
+	}
+	r.mustRunJSON(&us, "users", "list")
+	assert.Len(t, us, 3)
+
+	res = r.run("users", "create", "-email", "jane", "-password", "secret")
+	assert.Equal(t, 1, res.code)
+	assert.Contains(t, res.stderr, "Code: VALIDATION_FAILED")
+	assert.Contains(t, res.stderr, "  email: ")
+
+	res = r.run("users", "delete", id)
+	assert.Equal(t, 0, res.code, res.stderr)
+	assert.Contains(t, res.stderr, "Deleted user "+id)
+	assert.Equal(t, 1, r.run("users", "get", id).code)
+}
+
+func TestResources(t *testing.T) {
+	srv, db, teardown := mustInitServer(t)
+	defer teardown()
+	r, cleanup := mustRunner(t, srv, "joe@example.org")
+	defer cleanup()
+
+	// provision in the background while commands wait
+	defer startWorker(db)()
+
+	var res model.Resource
+	r.mustRunJSON(&res, "resources", "create", "-project", "1", "-name", "soup", "-type", "pot.instance.small", "-wait")
+	assert.Equal(t, model.ResourceRunning, res.Status)
+	id := itoa(res.ID)
+
+	r.mustRunJSON(&res, "resources", "update", "-project", "1", "-name", "stew", id)
+	assert.Equal(t, "stew", res.Name)
+
+	out := r.run("resources", "list", "-project", "1", "-status", "running")
+	assert.Equal(t, 0, out.code, out.stderr)
+	assert.Contains(t, out.stdout, "stew")
+
+	out = r.run("-o", "yaml", "resources", "get", "-project", "1", id)
+	assert.Equal(t, 0, out.code, out.stderr)
+	assert.Contains(t, out.stdout, "Name: stew\n")
+
+	r.mustRunJSON(&res, "resources", "delete", "-project", "1", "-wait", id)
+	assert.Equal(t, model.ResourceDeleted, res.Status)
+
+	out = r.run("resources", "get", "-project", "2", id)
+	assert.Equal(t, 1, out.code)
+}
+
+func TestQuotas(t *testing.T) {
+	srv, _, teardown := mustInitServer(t)
+	defer teardown()
+	r, cleanup := mustRunner(t, srv, "admin@example.org")
+	defer cleanup()
+
+	var q model.Quota
+	r.mustRunJSON(&q, "quotas", "create", "-project", "1", "pot.instance.small", "0")
+	assert.Equal(t, 0, q.Value)
+	r.mustRunJSON(&q, "quotas", "update", "-project", "1", "pot.instance.small", "1")
+	assert.Equal(t, 1, q.Value)
+	r.mustRunJSON(&q, "quotas", "get", "-project", "1", "pot.instance.small")
+	assert.Equal(t, 1, q.Value)
+
+	out := r.run("quotas", "list", "-project", "1")
+	assert.Equal(t, 0, out.code, out.stderr)
+	assert.Contains(t, out.stdout, "pot.instance.small")
+
+	assert.Equal(t, 0, r.run("resources", "create", "-project", "1", "-name", "soup", "-type", "pot.instance.small").code)
+	out = r.run("resources", "create", "-project", "1", "-name", "stew", "-type", "pot.instance.small")
+	assert.Equal(t, 1, out.code)
+	assert.Contains(t, out.stderr, "Code: QUOTA_EXCEEDED")
+
+	out = r.run("quotas", "usage", "-threshold", "1")
+	assert.Equal(t, 0, out.code, out.stderr)
+	assert.Contains(t, out.stdout, "pot.instance.small  1     1      0")
+
+	out = r.run("quotas", "delete", "-project", "1", "pot.instance.small")
+	assert.Equal(t, 0, out.code, out.stderr)
+	out = r.run("quotas", "get", "-project", "1", "pot.instance.small")
+	assert.Equal(t, 1, out.code)
+	assert.Contains(t, out.stderr, "Project 1 has no quota for pot.instance.small")
+}
+
+func TestCatalog(t *testing.T) {
+	srv, _, teardown := mustInitServer(t)
+	defer teardown()
+	r, cleanup := mustRunner(t, srv, "admin@example.org")
+	defer cleanup()
+
+	var ci model.Catalog
+	r.mustRunJSON(&ci, "catalog", "create", "-display-name", "Tiny pot", "-category", "pot", "-capacity", "0.5", "-size", "10",
+		"-price", "1", "-currency", "EUR", "pot.instance.tiny")
+	assert.Equal(t, model.Specs{Capacity: 0.5, Size: 10}, ci.Specs)
+
+	// only given fields change
+	r.mustRunJSON(&ci, "catalog", "update", "-size", "12", "-status", "deprecated", "pot.instance.tiny")
+	assert.Equal(t, model.Specs{Capacity: 0.5, Size: 12}, ci.Specs)
+	assert.Equal(t, model.CatalogDeprecated, ci.Status)
+	assert.Equal(t, "Tiny pot", ci.DisplayName)
+
+	out := r.run("catalog", "list", "-status", "deprecated")
+	assert.Equal(t, 0, out.code, out.stderr)
+	assert.Contains(t, out.stdout, "pot.instance.tiny")
+
+	r.mustRunJSON(&ci, "catalog", "get", "pot.instance.tiny")
+	assert.Equal(t, int64(1), ci.HourlyPrice)
+
+	assert.Equal(t, 0, r.run("catalog", "delete", "pot.instance.tiny").code)
+	out = r.run("catalog", "get", "pot.instance.tiny")
+	assert.Equal(t, 1, out.code)
+	assert.Contains(t, out.stderr, "Code: NOT_FOUND")
+}
+
+func TestApply(t *testing.T) {
+	srv, db, teardown := mustInitServer(t)
+	defer teardown()
+	r, cleanup := mustRunner(t, srv, "joe@example.org")
+	defer cleanup()
+
+	defer startWorker(db)()
+
+	path := filepath.Join(filepath.Dir(r.path), "manifest.yaml")
+	doc := `
+projects:
+- project: 1
+  resources:
+  - name: pasta pot
+    type: pot.instance.large
+  - name: soup
+    type: pot.instance.small
+`
+	if err := ioutil.WriteFile(path, []byte(doc), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	out := r.run("plan", "-f", path)
+	assert.Equal(t, 0, out.code, out.stderr)
+	assert.Contains(t, out.stdout, "delete  resource  1        rice pot  pot.instance.xlarge")
+	assert.Contains(t, out.stdout, "create  resource  1        soup      pot.instance.small")
+
+	out = r.run("apply", "-f", path)
+	assert.Equal(t, 0, out.code, out.stderr)
+	assert.Contains(t, out.stderr, "Applied 2 change(s)")
+
+	r.stdin = doc
+	out = r.run("apply", "-f", "-")
+	assert.Equal(t, 0, out.code, out.stderr)
+	assert.Contains(t, out.stderr, "Nothing to do")
+
+	// plans with problems fail
+	r.stdin = `projects: [{project: 1, resources: [{name: kettle, type: kettle.instance.s}]}]`
+	var p struct {
+		Changes []map[string]interface{}
+	}
+	out = r.run("-o", "json", "plan", "-f", "-")
+	assert.Equal(t, 1, out.code)
+	assert.Contains(t, out.stderr, "Plan has 1 problem(s)")
+	if assert.NoError(t, json.Unmarshal([]byte(out.stdout), &p)) {
+		assert.Len(t, p.Changes, 3)
+	}
+	out = r.run("apply", "-f", "-")
+	assert.Equal(t, 1, out.code)
+	out = r.run("resources", "list", "-project", "1", "-status", "running")
+	assert.Contains(t, out.stdout, "soup")
+}
+
+func itoa(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
@@ -0,0 +1,71 @@
+package ctl
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+func TestPrintValue(t *testing.T) {
+	us := []*model.User{
+		{ID: 1, Email: "joe@example.org", Roles: []model.Role{model.RoleUser}, ProjectID: 1},
+		{ID: 2, Email: "admin@example.org", Roles: []model.Role{model.RoleAdmin, model.RoleUser}, ProjectID: 2},
+	}
+	tests := []struct {
+		format string
+		out    string
+	}{
+		{
+			format: FormatTable,
+			out: "ID  EMAIL              ROLES       PROJECT\n" +
+				"1   joe@example.org    user        1\n" +
+				"2   admin@example.org  admin,user  2\n",
+		},
+		{
+			format: FormatJSON,
+			out: `[
+  {
+    "id": 1,
+    "email": "joe@example.org",
+    "roles": [
+      "user"
+    ],
+    "projectId": 1
+  },
+  {
+    "id": 2,
+    "email": "admin@example.org",
+    "roles": [
+      "admin",
+      "user"
+    ],
+    "projectId": 2
+  }
+]
+`,
+		},
+		{
+			format: FormatYAML,
+			out: `- email: joe@example.org
+  id: 1
+  projectId: 1
+  roles:
+  - user
+- email: admin@example.org
+  id: 2
+  projectId: 2
+  roles:
+  - admin
+  - user
+`,
+		},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		if assert.NoError(t, printValue(&out, tt.format, us, func() *table { return userTable(us...) }), tt.format) {
+			assert.Equal(t, tt.out, out.String(), tt.format)
+		}
+	}
+}
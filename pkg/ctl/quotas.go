@@ -0,0 +1,185 @@
+package ctl
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/yodo-io/ycp/pkg/client"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+var quotaCommands = map[string]func(c *cli, args []string) error{
+	"list":   listQuotas,
+	"get":    getQuota,
+	"create": setQuota,
+	"update": setQuota,
+	"delete": deleteQuota,
+	"usage":  quotaUsage,
+}
+
+// quotas list|get|create|update|delete|usage. Quotas are unique per project and type, so they are
+// given by type rather than ID.
+func quotas(c *cli, args []string) error {
+	return c.subcommand("quotas", quotaCommands, args)
+}
+
+func quotaTable(qs ...*model.Quota) *table {
+	t := newTable("ID", "TYPE", "VALUE")
+	for _, q := range qs {
+		t.add(q.ID, q.Type, q.Value)
+	}
+	return t
+}
+
+func usageTable(us ...*client.QuotaUsage) *table {
+	t := newTable("PROJECT", "TYPE", "USED", "LIMIT", "REMAINING")
+	for _, u := range us {
+		limit, remaining := "-", "-"
+		if u.Limit != nil {
+			limit = strconv.Itoa(*u.Limit)
+		}
+		if u.Remaining != nil {
+			remaining = strconv.Itoa(*u.Remaining)
+		}
+		t.add(u.ProjectID, u.Type, u.Used, limit, remaining)
+	}
+	return t
+}
+
+// Look up the quota of a project for a type
+func (c *cli) findQuota(api *client.Client, projectID uint, tp string) (*model.Quota, error) {
+	qs, _, err := api.ListQuotas(c.ctx, projectID, &client.ListOptions{Filter: filter("type", tp)})
+	if err != nil {
+		return nil, err
+	}
+	if len(qs) == 0 {
+		return nil, fmt.Errorf("Project %d has no quota for %s", projectID, tp)
+	}
+	return qs[0], nil
+}
+
+// quotas list -project id [-type type]
+func listQuotas(c *cli, args []string) error {
+	fs := c.flags("quotas list", "-project id [-type type] [-limit n] [-sort fields]")
+	pid := projectFlag(fs)
+	tp := fs.String("type", "", "only list the quota for this catalog item")
+	lf := addListFlags(fs)
+	if _, err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+	if err := checkProject(*pid); err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	res := []*model.Quota{}
+	err = lf.fetch(filter("type", *tp), func(opts *client.ListOptions) (*client.Page, error) {
+		qs, p, err := api.ListQuotas(c.ctx, *pid, opts)
+		res = append(res, qs...)
+		return p, err
+	})
+	if err != nil {
+		return err
+	}
+	return c.print(res, func() *table { return quotaTable(res...) })
+}
+
+// quotas get -project id <type>
+func getQuota(c *cli, args []string) error {
+	fs := c.flags("quotas get", "-project id <type>")
+	pid := projectFlag(fs)
+	args, err := c.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if err := checkProject(*pid); err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	q, err := c.findQuota(api, *pid, args[0])
+	if err != nil {
+		return err
+	}
+	return c.print(q, func() *table { return quotaTable(q) })
+}
+
+// quotas create|update -project id <type> <value>, either creates or updates the quota
+func setQuota(c *cli, args []string) error {
+	fs := c.flags("quotas create|update", "-project id <type> <value>")
+	pid := projectFlag(fs)
+	args, err := c.parse(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	v, err := strconv.Atoi(args[1])
+	if err != nil {
+		return fmt.Errorf("Invalid quota value %q", args[1])
+	}
+	if err := checkProject(*pid); err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	q, err := api.SetQuota(c.ctx, *pid, args[0], v)
+	if err != nil {
+		return err
+	}
+	return c.print(q, func() *table { return quotaTable(q) })
+}
+
+// quotas delete -project id <type>
+func deleteQuota(c *cli, args []string) error {
+	fs := c.flags("quotas delete", "-project id <type>")
+	pid := projectFlag(fs)
+	args, err := c.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	if err := checkProject(*pid); err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	q, err := c.findQuota(api, *pid, args[0])
+	if err != nil {
+		return err
+	}
+	if err := api.DeleteQuota(c.ctx, *pid, q.ID); err != nil {
+		return err
+	}
+	c.info("Deleted quota of project %d for %s", *pid, q.Type)
+	return nil
+}
+
+// quotas usage -project id, or quotas usage [-threshold t] across projects
+func quotaUsage(c *cli, args []string) error {
+	fs := c.flags("quotas usage", "[-project id] [-threshold fraction]")
+	pid := projectFlag(fs)
+	threshold := fs.Float64("threshold", 0, "without -project, only list quotas used at least this much, e.g. 0.8")
+	if _, err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	var res []*client.QuotaUsage
+	if *pid != 0 {
+		res, err = api.ProjectUsage(c.ctx, *pid)
+	} else {
+		res, err = api.Usage(c.ctx, *threshold)
+	}
+	if err != nil {
+		return err
+	}
+	return c.print(res, func() *table { return usageTable(res...) })
+}
@@ -0,0 +1,207 @@
+package ctl
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/yodo-io/ycp/pkg/client"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+var resourceCommands = map[string]func(c *cli, args []string) error{
+	"list":   listResources,
+	"get":    getResource,
+	"create": createResource,
+	"update": updateResource,
+	"delete": deleteResource,
+}
+
+// resources list|get|create|update|delete
+func resources(c *cli, args []string) error {
+	return c.subcommand("resources", resourceCommands, args)
+}
+
+func resourceTable(rs ...*model.Resource) *table {
+	t := newTable("ID", "NAME", "TYPE", "STATUS", "MESSAGE")
+	for _, r := range rs {
+		t.add(r.ID, r.Name, r.Type, r.Status, r.StatusMessage)
+	}
+	return t
+}
+
+// Flag for the project of resources and quotas, which all their subcommands need
+func projectFlag(fs *flag.FlagSet) *uint {
+	return fs.Uint("project", 0, "ID of the project (required)")
+}
+
+func checkProject(id uint) error {
+	if id == 0 {
+		return errors.New("-project is required")
+	}
+	return nil
+}
+
+// resources list -project id [-name name] [-type type] [-status status]
+func listResources(c *cli, args []string) error {
+	fs := c.flags("resources list", "-project id [-name name] [-type type] [-status status] [-limit n] [-sort fields]")
+	pid := projectFlag(fs)
+	name := fs.String("name", "", "only list resources with this name")
+	tp := fs.String("type", "", "only list resources of this catalog item")
+	status := fs.String("status", "", "only list resources with this status, e.g. running")
+	lf := addListFlags(fs)
+	if _, err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+	if err := checkProject(*pid); err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	res := []*model.Resource{}
+	err = lf.fetch(filter("name", *name, "type", *tp, "status", *status), func(opts *client.ListOptions) (*client.Page, error) {
+		rs, p, err := api.ListResources(c.ctx, *pid, opts)
+		res = append(res, rs...)
+		return p, err
+	})
+	if err != nil {
+		return err
+	}
+	return c.print(res, func() *table { return resourceTable(res...) })
+}
+
+// resources get -project id <id>
+func getResource(c *cli, args []string) error {
+	fs := c.flags("resources get", "-project id <id>")
+	pid := projectFlag(fs)
+	args, err := c.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	if err := checkProject(*pid); err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	r, err := api.GetResource(c.ctx, *pid, id)
+	if err != nil {
+		return err
+	}
+	return c.print(r, func() *table { return resourceTable(r) })
+}
+
+// resources create -project id -name name -type type [-wait]
+func createResource(c *cli, args []string) error {
+	fs := c.flags("resources create", "-project id -name name -type type [-wait]")
+	pid := projectFlag(fs)
+	name := fs.String("name", "", "name of the resource")
+	tp := fs.String("type", "", "catalog item to create the resource from")
+	wait := fs.Bool("wait", false, "wait until the resource is provisioned")
+	if _, err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+	if err := checkProject(*pid); err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	r, opID, err := api.CreateResource(c.ctx, *pid, &model.Resource{Name: *name, Type: *tp})
+	if err != nil {
+		return err
+	}
+	if *wait {
+		if r, err = c.waitFor(api, *pid, r, opID); err != nil {
+			return err
+		}
+	}
+	return c.print(r, func() *table { return resourceTable(r) })
+}
+
+// resources update -project id [-name name] [-type type] [-wait] <id>
+func updateResource(c *cli, args []string) error {
+	fs := c.flags("resources update", "-project id [-name name] [-type type] [-wait] <id>")
+	pid := projectFlag(fs)
+	name := fs.String("name", "", "new name")
+	tp := fs.String("type", "", "catalog item to resize the resource to")
+	wait := fs.Bool("wait", false, "wait until the resource is resized")
+	args, err := c.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	if err := checkProject(*pid); err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	r, opID, err := api.UpdateResource(c.ctx, *pid, id, client.ResourcePatch{Name: *name, Type: *tp})
+	if err != nil {
+		return err
+	}
+	if *wait && opID != 0 {
+		if r, err = c.waitFor(api, *pid, r, opID); err != nil {
+			return err
+		}
+	}
+	return c.print(r, func() *table { return resourceTable(r) })
+}
+
+// resources delete -project id [-wait] <id>
+func deleteResource(c *cli, args []string) error {
+	fs := c.flags("resources delete", "-project id [-wait] <id>")
+	pid := projectFlag(fs)
+	wait := fs.Bool("wait", false, "wait until the resource is deleted")
+	args, err := c.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	if err := checkProject(*pid); err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	r, opID, err := api.DeleteResource(c.ctx, *pid, id)
+	if err != nil {
+		return err
+	}
+	if *wait {
+		if r, err = c.waitFor(api, *pid, r, opID); err != nil {
+			return err
+		}
+	}
+	return c.print(r, func() *table { return resourceTable(r) })
+}
+
+// Wait for an operation on a resource to finish and get the resource as it is afterwards
+func (c *cli) waitFor(api *client.Client, projectID uint, r *model.Resource, opID uint) (*model.Resource, error) {
+	c.info("Waiting for operation %d on resource %d...", opID, r.ID)
+	op, err := api.WaitForOperation(c.ctx, projectID, r.ID, opID, pollInterval)
+	if err != nil {
+		return nil, err
+	}
+	if op.Status == model.OperationFailed {
+		return nil, fmt.Errorf("Operation %d (%s) on resource %d failed: %s", op.ID, op.Action, r.ID, op.Error)
+	}
+	return api.GetResource(c.ctx, projectID, r.ID)
+}
@@ -0,0 +1,157 @@
+package ctl
+
+import (
+	"strings"
+
+	"github.com/yodo-io/ycp/pkg/client"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+var userCommands = map[string]func(c *cli, args []string) error{
+	"list":   listUsers,
+	"get":    getUser,
+	"create": createUser,
+	"update": updateUser,
+	"delete": deleteUser,
+}
+
+// users list|get|create|update|delete
+func users(c *cli, args []string) error {
+	return c.subcommand("users", userCommands, args)
+}
+
+func userTable(us ...*model.User) *table {
+	t := newTable("ID", "EMAIL", "ROLES", "PROJECT")
+	for _, u := range us {
+		roles := make([]string, len(u.Roles))
+		for i, r := range u.Roles {
+			roles[i] = string(r)
+		}
+		t.add(u.ID, u.Email, strings.Join(roles, ","), u.ProjectID)
+	}
+	return t
+}
+
+// Roles given as a comma separated list
+func parseRoles(s string) []model.Role {
+	if s == "" {
+		return nil
+	}
+	var roles []model.Role
+	for _, r := range strings.Split(s, ",") {
+		roles = append(roles, model.Role(strings.TrimSpace(r)))
+	}
+	return roles
+}
+
+// users list [-email email] [-role role]
+func listUsers(c *cli, args []string) error {
+	fs := c.flags("users list", "[-email email] [-role role] [-limit n] [-sort fields]")
+	email := fs.String("email", "", "only list the user with this email")
+	role := fs.String("role", "", "only list users with this role")
+	lf := addListFlags(fs)
+	if _, err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	res := []*model.User{}
+	err = lf.fetch(filter("email", *email, "role", *role), func(opts *client.ListOptions) (*client.Page, error) {
+		us, p, err := api.ListUsers(c.ctx, opts)
+		res = append(res, us...)
+		return p, err
+	})
+	if err != nil {
+		return err
+	}
+	return c.print(res, func() *table { return userTable(res...) })
+}
+
+// users get <id>
+func getUser(c *cli, args []string) error {
+	args, err := c.parse(c.flags("users get", "<id>"), args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	u, err := api.GetUser(c.ctx, id)
+	if err != nil {
+		return err
+	}
+	return c.print(u, func() *table { return userTable(u) })
+}
+
+// users create -email email -password password [-roles roles]
+func createUser(c *cli, args []string) error {
+	fs := c.flags("users create", "-email email -password password [-roles roles]")
+	email := fs.String("email", "", "email of the user")
+	password := fs.String("password", "", "initial password")
+	roles := fs.String("roles", "", `comma separated roles, e.g. "user,auditor"`)
+	if _, err := c.parse(fs, args, 0); err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	u, err := api.CreateUser(c.ctx, &model.User{Email: *email, Password: *password, Roles: parseRoles(*roles)})
+	if err != nil {
+		return err
+	}
+	return c.print(u, func() *table { return userTable(u) })
+}
+
+// users update [-email email] [-password password] [-roles roles] <id>
+func updateUser(c *cli, args []string) error {
+	fs := c.flags("users update", "[-email email] [-password password] [-roles roles] <id>")
+	email := fs.String("email", "", "new email")
+	password := fs.String("password", "", "new password")
+	roles := fs.String("roles", "", "new comma separated roles, replacing the current ones")
+	args, err := c.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	u, err := api.UpdateUser(c.ctx, id, client.UserPatch{Email: *email, Password: *password, Roles: parseRoles(*roles)})
+	if err != nil {
+		return err
+	}
+	return c.print(u, func() *table { return userTable(u) })
+}
+
+// users delete <id>
+func deleteUser(c *cli, args []string) error {
+	args, err := c.parse(c.flags("users delete", "<id>"), args, 1)
+	if err != nil {
+		return err
+	}
+	id, err := parseID(args[0])
+	if err != nil {
+		return err
+	}
+	api, err := c.client()
+	if err != nil {
+		return err
+	}
+	if err := api.DeleteUser(c.ctx, id); err != nil {
+		return err
+	}
+	c.info("Deleted user %d", id)
+	return nil
+}
@@ -0,0 +1,97 @@
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Keys of values kept in the gin context
+const (
+	loggerKey = "logger"
+	// RequestIDKey holds the ID of the request, taken from the X-Request-ID header or generated
+	RequestIDKey = "requestID"
+)
+
+// RequestIDHeader is the header request IDs are read from and echoed in
+const RequestIDHeader = "X-Request-ID"
+
+// Request IDs given by clients end up in logs and responses, so only short, plain ones are used
+var requestIDRegexp = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Middleware logs every request once it has been handled, along with the errors handlers reported
+// with c.Error. Requests get a logger carrying their ID, method and path, see From. Requests to
+// the notlogged paths, e.g. health checks, get a logger but are not logged themselves.
+func Middleware(l *Logger, notlogged ...string) gin.HandlerFunc {
+	skip := map[string]bool{}
+	for _, p := range notlogged {
+		skip[p] = true
+	}
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Set(loggerKey, l.With(Fields{
+			"requestId": RequestID(c),
+			"method":    c.Request.Method,
+			"path":      c.Request.URL.Path,
+		}))
+		c.Next()
+
+		if skip[c.Request.URL.Path] {
+			return
+		}
+		status := c.Writer.Status()
+		f := Fields{
+			"status":    status,
+			"latencyMs": float64(time.Since(start)) / float64(time.Millisecond),
+			"clientIp":  c.ClientIP(),
+			"bytes":     c.Writer.Size(),
+		}
+		if e := c.Errors.Last(); e != nil {
+			f["error"] = e.Err
+		}
+		level := LevelInfo
+		if status >= http.StatusInternalServerError {
+			level = LevelError
+		}
+		From(c).Log(level, "Request handled", f)
+	}
+}
+
+// From returns the logger of a request, or Default outside of Middleware
+func From(c *gin.Context) *Logger {
+	if o, ok := c.Get(loggerKey); ok {
+		if l, ok := o.(*Logger); ok {
+			return l
+		}
+	}
+	return Default
+}
+
+// With adds fields to the logger of a request, e.g. the user once authenticated. They are
+// included in all entries logged for the request from now on, including the request log.
+func With(c *gin.Context, f Fields) {
+	c.Set(loggerKey, From(c).With(f))
+}
+
+// RequestID returns the ID of a request. The ID given by the client is reused if it is at most 64
+// letters, digits, dots, dashes or underscores, otherwise one is generated. It is echoed in the X-Request-ID response header.
+func RequestID(c *gin.Context) string {
+	if id := c.GetString(RequestIDKey); id != "" {
+		return id
+	}
+	id := c.GetHeader(RequestIDHeader)
+	if !requestIDRegexp.MatchString(id) {
+		b := make([]byte, 16)
+		if _, err := rand.Read(b); err != nil {
+			Default.Error("Failed to generate request ID", Fields{"error": err})
+		}
+		id = hex.EncodeToString(b)
+	}
+	c.Set(RequestIDKey, id)
+	c.Header(RequestIDHeader, id)
+	return id
+}
@@ -0,0 +1,116 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	return gin.New()
+}
+
+func record(r *gin.Engine, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, nil)
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRouter()
+	r.Use(Middleware(New(&buf, LevelInfo), "/healthz"))
+	r.GET("/healthz", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	r.GET("/users/:id", func(c *gin.Context) {
+		With(c, Fields{"userId": 1})
+		From(c).Info("Looking up user")
+		c.Error(errors.New("db is gone"))
+		c.Status(http.StatusInternalServerError)
+	})
+
+	w := record(r, http.MethodGet, "/users/1")
+	id := w.Header().Get(RequestIDHeader)
+	assert.Len(t, id, 32)
+
+	entries := mustDecode(t, &buf)
+	if !assert.Len(t, entries, 2) {
+		return
+	}
+	for _, e := range entries {
+		assert.Equal(t, id, e["requestId"])
+		assert.Equal(t, "/users/1", e["path"])
+		assert.Equal(t, float64(1), e["userId"])
+	}
+	assert.Equal(t, "Looking up user", entries[0]["msg"])
+	assert.Equal(t, "error", entries[1]["level"])
+	assert.Equal(t, float64(http.StatusInternalServerError), entries[1]["status"])
+	assert.Equal(t, "db is gone", entries[1]["error"])
+
+	// not logged, but still gets an ID
+	buf.Reset()
+	w = record(r, http.MethodGet, "/healthz")
+	assert.NotEmpty(t, w.Header().Get(RequestIDHeader))
+	assert.Empty(t, buf.String())
+}
+
+func TestRequestIDFromClient(t *testing.T) {
+	var buf bytes.Buffer
+	r := newRouter()
+	r.Use(Middleware(New(&buf, LevelInfo)))
+	r.POST("/resources", func(c *gin.Context) {
+		c.String(http.StatusCreated, RequestID(c))
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/resources", nil)
+	req.Header.Add(RequestIDHeader, "abc-123")
+	r.ServeHTTP(w, req)
+	assert.Equal(t, "abc-123", w.Header().Get(RequestIDHeader))
+	assert.Equal(t, "abc-123", w.Body.String())
+
+	entries := mustDecode(t, &buf)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "abc-123", entries[0]["requestId"])
+		assert.Equal(t, "info", entries[0]["level"])
+	}
+}
+
+func TestRequestIDInvalid(t *testing.T) {
+	r := newRouter()
+	r.Use(Middleware(New(&bytes.Buffer{}, LevelInfo)))
+	r.GET("/resources", func(c *gin.Context) {
+		c.String(http.StatusOK, RequestID(c))
+	})
+
+	for _, id := range []string{
+		strings.Repeat("a", 65),
+		"abc 123",
+		"abc\nfake log entry",
+		"<script>",
+	} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/resources", nil)
+		req.Header.Add(RequestIDHeader, id)
+		r.ServeHTTP(w, req)
+		got := w.Header().Get(RequestIDHeader)
+		assert.NotEqual(t, id, got)
+		assert.Len(t, got, 32)
+		assert.Equal(t, got, w.Body.String())
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/resources", nil)
+	req.Header.Add(RequestIDHeader, strings.Repeat("a", 64))
+	r.ServeHTTP(w, req)
+	assert.Equal(t, strings.Repeat("a", 64), w.Header().Get(RequestIDHeader))
+}
@@ -0,0 +1,154 @@
+/*
+Package logging implements a leveled logger writing structured entries as JSON lines:
+
+	{"level":"info","msg":"Reloaded RBAC policy","time":"2018-10-01T12:00:00.000000001Z"}
+
+Fields are attached to a logger with With, so all entries it writes carry them:
+
+	l := logging.Default.With(logging.Fields{"resourceId": r.ID})
+	l.Error("Operation failed", logging.Fields{"error": err})
+
+Within a request, handlers use the logger of the request, see From, which carries the request ID and
+the user making the request.
+*/
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level of log entries, entries below the level of a logger are dropped
+type Level int
+
+// Levels in increasing order of severity
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel returns the level of the given name, i.e. one of debug, info, warn or error
+func ParseLevel(s string) (Level, error) {
+	for i, n := range levelNames {
+		if strings.EqualFold(s, n) {
+			return Level(i), nil
+		}
+	}
+	return 0, fmt.Errorf("Invalid log level %q, must be one of %s", s, strings.Join(levelNames, ", "))
+}
+
+// Fields are key-value pairs added to log entries. Errors are logged as their message.
+type Fields map[string]interface{}
+
+// Names of the fields every entry has, they can't be overridden
+const (
+	timeKey  = "time"
+	levelKey = "level"
+	msgKey   = "msg"
+)
+
+// Clock of entry timestamps, replaced in tests
+var now = time.Now
+
+// Default logs at info level to stderr, it is used where no other logger is available
+var Default = New(os.Stderr, LevelInfo)
+
+// Logger writes entries of at least its level to a writer. Loggers are safe for concurrent use,
+// loggers derived with With share the writer and its lock.
+type Logger struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	level  Level
+	fields Fields
+}
+
+// New creates a logger writing entries of at least the given level to out
+func New(out io.Writer, level Level) *Logger {
+	return &Logger{mu: &sync.Mutex{}, out: out, level: level}
+}
+
+// With returns a logger adding the given fields to all entries, in addition to the fields of l
+func (l *Logger) With(f Fields) *Logger {
+	fields := make(Fields, len(l.fields)+len(f))
+	for k, v := range l.fields {
+		fields[k] = v
+	}
+	for k, v := range f {
+		fields[k] = v
+	}
+	return &Logger{mu: l.mu, out: l.out, level: l.level, fields: fields}
+}
+
+// Enabled is true if entries of the given level are written
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+// Debug writes an entry at debug level
+func (l *Logger) Debug(msg string, f ...Fields) {
+	l.Log(LevelDebug, msg, f...)
+}
+
+// Info writes an entry at info level
+func (l *Logger) Info(msg string, f ...Fields) {
+	l.Log(LevelInfo, msg, f...)
+}
+
+// Warn writes an entry at warn level
+func (l *Logger) Warn(msg string, f ...Fields) {
+	l.Log(LevelWarn, msg, f...)
+}
+
+// Error writes an entry at error level
+func (l *Logger) Error(msg string, f ...Fields) {
+	l.Log(LevelError, msg, f...)
+}
+
+// Log writes an entry with the fields of l and the given fields, later fields take precedence
+func (l *Logger) Log(level Level, msg string, f ...Fields) {
+	if !l.Enabled(level) {
+		return
+	}
+	entry := make(map[string]interface{}, len(l.fields)+3)
+	for _, fields := range append([]Fields{l.fields}, f...) {
+		for k, v := range fields {
+			if err, ok := v.(error); ok {
+				v = err.Error()
+			}
+			entry[k] = v
+		}
+	}
+	entry[timeKey] = now().Format(time.RFC3339Nano)
+	entry[levelKey] = level.String()
+	entry[msgKey] = msg
+
+	b, err := json.Marshal(entry)
+	if err != nil {
+		// keep the message at least
+		b, _ = json.Marshal(map[string]interface{}{
+			timeKey:  entry[timeKey],
+			levelKey: entry[levelKey],
+			msgKey:   msg,
+			"error":  fmt.Sprintf("Failed to encode log fields: %v", err),
+		})
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out.Write(append(b, '\n'))
+}
@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	now = func() time.Time { return time.Date(2018, 10, 1, 12, 0, 0, 0, time.UTC) }
+}
+
+// Decode all entries written to a buffer
+func mustDecode(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var res []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var e map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("Invalid log line %q: %v", line, err)
+		}
+		res = append(res, e)
+	}
+	return res
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l := New(&buf, LevelInfo)
+	l.Debug("dropped")
+	l.Info("Started", Fields{"addr": ":9000"})
+
+	rl := l.With(Fields{"requestId": "abc"})
+	rl.Error("Failed", Fields{"error": errors.New("boom"), "status": 500})
+	// fields of derived loggers don't leak into their parent
+	l.Warn("Slow", Fields{"msg": "can't override the message"})
+
+	assert.Equal(t, []map[string]interface{}{
+		{"time": "2018-10-01T12:00:00Z", "level": "info", "msg": "Started", "addr": ":9000"},
+		{"time": "2018-10-01T12:00:00Z", "level": "error", "msg": "Failed", "requestId": "abc", "error": "boom", "status": float64(500)},
+		{"time": "2018-10-01T12:00:00Z", "level": "warn", "msg": "Slow"},
+	}, mustDecode(t, &buf))
+}
+
+func TestUnencodableFields(t *testing.T) {
+	var buf bytes.Buffer
+	New(&buf, LevelDebug).Debug("Oops", Fields{"fn": func() {}})
+
+	entries := mustDecode(t, &buf)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "Oops", entries[0]["msg"])
+		assert.Contains(t, entries[0]["error"], "Failed to encode log fields")
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for _, l := range []Level{LevelDebug, LevelInfo, LevelWarn, LevelError} {
+		parsed, err := ParseLevel(strings.ToUpper(l.String()))
+		assert.NoError(t, err)
+		assert.Equal(t, l, parsed)
+	}
+	_, err := ParseLevel("verbose")
+	assert.Error(t, err)
+}
@@ -0,0 +1,74 @@
+package manifest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/yodo-io/ycp/pkg/client"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+// Apply makes the changes of the plan in order. It waits for each change of a resource to finish,
+// polling its operation every interval, so deleted resources free up quota for the ones created after
+// them. Plans with problems aren't applied. Apply stops at the first change that fails, since plans are
+// idempotent it can be planned and applied again once the cause is fixed. If done isn't nil, it is
+// called after each change.
+func (p *Plan) Apply(ctx context.Context, api *client.Client, interval time.Duration, done func(c *Change)) error {
+	if n := len(p.Problems()); n > 0 {
+		return fmt.Errorf("Plan has %d problem(s), not applying it", n)
+	}
+	for _, c := range p.Changes {
+		var err error
+		if c.Kind == KindQuota {
+			err = applyQuota(ctx, api, c)
+		} else {
+			err = applyResource(ctx, api, c, interval)
+		}
+		if err != nil {
+			return fmt.Errorf("Failed to %s: %v", c, err)
+		}
+		if done != nil {
+			done(c)
+		}
+	}
+	return nil
+}
+
+func applyQuota(ctx context.Context, api *client.Client, c *Change) error {
+	if c.Action == Delete {
+		return api.DeleteQuota(ctx, c.ProjectID, c.ID)
+	}
+	v, err := strconv.Atoi(c.To)
+	if err != nil {
+		return err
+	}
+	_, err = api.SetQuota(ctx, c.ProjectID, c.Name, v)
+	return err
+}
+
+func applyResource(ctx context.Context, api *client.Client, c *Change, interval time.Duration) error {
+	var r *model.Resource
+	var opID uint
+	var err error
+	switch c.Action {
+	case Create:
+		r, opID, err = api.CreateResource(ctx, c.ProjectID, &model.Resource{Name: c.Name, Type: c.To})
+	case Update:
+		r, opID, err = api.UpdateResource(ctx, c.ProjectID, c.ID, client.ResourcePatch{Type: c.To})
+	case Delete:
+		r, opID, err = api.DeleteResource(ctx, c.ProjectID, c.ID)
+	}
+	if err != nil {
+		return err
+	}
+	op, err := api.WaitForOperation(ctx, c.ProjectID, r.ID, opID, interval)
+	if err != nil {
+		return err
+	}
+	if op.Status == model.OperationFailed {
+		return fmt.Errorf("operation %d failed: %s", op.ID, op.Error)
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+/*
+Package manifest converges projects to a declarative description of their resources and quotas.
+
+A manifest lists projects, by ID or by the user whose personal project it is, with the resources and
+quotas they should have:
+
+	projects:
+	- project: 3
+	  resources:
+	  - name: lunch wok
+	    type: pan.instance.wok
+	  quotas:
+	    pan.instance.wok: 2
+	- user: joe@example.org
+	  resources: []
+
+A plan compares a manifest with the projects on the server and lists the changes needed to converge
+them. Resources are matched by name, so applying a manifest twice is the same as applying it once. The
+manifest is authoritative for what it lists: resources of a project are only managed if the manifest
+has a resources key for it, but then all other resources of the project are deleted. The same goes for
+quotas, deleting a quota makes its type unlimited. Failed resources are replaced: they are deleted and
+created again.
+
+Plans are made and applied on the client, using the API like any other client would.
+*/
+package manifest
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Manifest is the desired state of projects
+type Manifest struct {
+	Projects []*Project `yaml:"projects" json:"projects"`
+}
+
+// Project is the desired state of a project, given either by ID or by a user's ID or email for the
+// user's personal project. Only admins can give users by email, as looking them up needs admin rights.
+type Project struct {
+	Project uint   `yaml:"project,omitempty" json:"project,omitempty"`
+	User    string `yaml:"user,omitempty"    json:"user,omitempty"`
+
+	// Resources of the project, matched by name. If nil, resources aren't managed.
+	Resources []Resource `yaml:"resources" json:"resources"`
+
+	// Quotas of the project by resource type. If nil, quotas aren't managed.
+	Quotas map[string]int `yaml:"quotas" json:"quotas"`
+}
+
+// Resource is a resource as declared in a manifest
+type Resource struct {
+	Name string `yaml:"name" json:"name"`
+	Type string `yaml:"type" json:"type"`
+}
+
+// String tells which project is meant, for error messages
+func (p *Project) String() string {
+	if p.User != "" {
+		return fmt.Sprintf("personal project of user %s", p.User)
+	}
+	return fmt.Sprintf("project %d", p.Project)
+}
+
+// Parse reads a manifest in YAML or JSON and validates it
+func Parse(r io.Reader) (*Manifest, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := yaml.UnmarshalStrict(b, &m); err != nil {
+		return nil, fmt.Errorf("Invalid manifest: %v", err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Load reads the manifest file at path
+func Load(path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Validate checks the manifest is complete and unambiguous, it doesn't check it against the server
+func (m *Manifest) Validate() error {
+	if len(m.Projects) == 0 {
+		return fmt.Errorf("Invalid manifest: no projects")
+	}
+	for i, p := range m.Projects {
+		if (p.Project == 0) == (p.User == "") {
+			return fmt.Errorf("Invalid manifest: projects[%d] must have either project or user", i)
+		}
+		if p.Resources == nil && p.Quotas == nil {
+			return fmt.Errorf("Invalid manifest: %s has neither resources nor quotas", p)
+		}
+		names := map[string]bool{}
+		for j, r := range p.Resources {
+			if r.Name == "" || r.Type == "" {
+				return fmt.Errorf("Invalid manifest: resources[%d] of %s must have name and type", j, p)
+			}
+			if names[r.Name] {
+				return fmt.Errorf("Invalid manifest: %s has several resources named %q", p, r.Name)
+			}
+			names[r.Name] = true
+		}
+		for tp, v := range p.Quotas {
+			if v < 0 {
+				return fmt.Errorf("Invalid manifest: quota of %s for %s is negative", p, tp)
+			}
+		}
+	}
+	return nil
+}
+
+// User ID of the project, if the user is given by ID rather than email
+func (p *Project) userID() (uint, bool) {
+	id, err := strconv.ParseUint(p.User, 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,66 @@
+package manifest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		doc string
+		err string
+		m   *Manifest
+	}{
+		{
+			doc: `
+projects:
+- project: 3
+  resources:
+  - name: lunch wok
+    type: pan.instance.wok
+  quotas:
+    pan.instance.wok: 2
+- user: joe@example.org
+  resources: []
+- user: 2
+  quotas: {}
+`,
+			m: &Manifest{Projects: []*Project{
+				{Project: 3, Resources: []Resource{{Name: "lunch wok", Type: "pan.instance.wok"}}, Quotas: map[string]int{"pan.instance.wok": 2}},
+				{User: "joe@example.org", Resources: []Resource{}},
+				{User: "2", Quotas: map[string]int{}},
+			}},
+		},
+		{
+			doc: `{"projects":[{"project":1,"resources":[{"name":"soup","type":"pot.instance.small"}]}]}`,
+			m: &Manifest{Projects: []*Project{
+				{Project: 1, Resources: []Resource{{Name: "soup", Type: "pot.instance.small"}}},
+			}},
+		},
+		{doc: `projects: []`, err: "no projects"},
+		{doc: `projects: [{project: 1, resorces: []}]`, err: "Invalid manifest"},
+		{doc: `projects: [{resources: []}]`, err: "must have either project or user"},
+		{doc: `projects: [{project: 1, user: joe@example.org, resources: []}]`, err: "must have either project or user"},
+		{doc: `projects: [{project: 1}]`, err: "project 1 has neither resources nor quotas"},
+		{doc: `projects: [{project: 1, resources: [{name: soup}]}]`, err: "resources[0] of project 1 must have name and type"},
+		{
+			doc: `projects: [{user: joe@example.org, resources: [{name: soup, type: a}, {name: soup, type: b}]}]`,
+			err: `personal project of user joe@example.org has several resources named "soup"`,
+		},
+		{doc: `projects: [{project: 1, quotas: {pot.instance.small: -1}}]`, err: "negative"},
+	}
+	for _, tt := range tests {
+		m, err := Parse(strings.NewReader(tt.doc))
+		if tt.err != "" {
+			if assert.Error(t, err, tt.doc) {
+				assert.Contains(t, err.Error(), tt.err, tt.doc)
+			}
+			continue
+		}
+		if assert.NoError(t, err, tt.doc) {
+			assert.Equal(t, tt.m, m, tt.doc)
+		}
+	}
+}
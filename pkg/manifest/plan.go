@@ -0,0 +1,361 @@
+package manifest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/yodo-io/ycp/pkg/client"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+// Action of a change
+type Action string
+
+// Actions of changes, in the order they are applied
+const (
+	Create Action = "create"
+	Update Action = "update"
+	Delete Action = "delete"
+)
+
+// Kinds of objects changed
+const (
+	KindQuota    = "quota"
+	KindResource = "resource"
+)
+
+// Change is a step towards converging a project to the manifest
+type Change struct {
+	Action    Action `json:"action"`
+	Kind      string `json:"kind"`
+	ProjectID uint   `json:"projectId"`
+	// Name is the name of a resource or the type of a quota
+	Name string `json:"name"`
+	// ID of the object changed, unless it is created
+	ID uint `json:"id,omitempty"`
+	// From and To are the type of a resource or the value of a quota, before and after the change
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+	// Problem tells why the change would fail, e.g. because it exceeds a quota
+	Problem string `json:"problem,omitempty"`
+}
+
+func (c *Change) String() string {
+	s := fmt.Sprintf("%s %s %q in project %d", c.Action, c.Kind, c.Name, c.ProjectID)
+	switch {
+	case c.From != "" && c.To != "":
+		s += fmt.Sprintf(" from %s to %s", c.From, c.To)
+	case c.To != "":
+		s += fmt.Sprintf(" with %s", c.To)
+	}
+	return s
+}
+
+// Plan lists the changes needed to converge projects to a manifest. Changes are sorted by project,
+// then in the order they are applied.
+type Plan struct {
+	Changes []*Change `json:"changes"`
+}
+
+// Problems returns the changes which would fail
+func (p *Plan) Problems() []*Change {
+	var res []*Change
+	for _, c := range p.Changes {
+		if c.Problem != "" {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// Order in which changes of a project are applied: quotas first, so new limits apply to resources,
+// deletions before resizes and resizes before creations, so they free up quota for the ones after
+func (c *Change) order() int {
+	if c.Kind == KindQuota {
+		return 0
+	}
+	switch c.Action {
+	case Delete:
+		return 1
+	case Update:
+		return 2
+	}
+	return 3
+}
+
+// NewPlan compares the manifest with the projects on the server and returns the changes needed to
+// converge them. Changes which would fail, e.g. because they exceed a quota, are kept in the plan with
+// their problem.
+func NewPlan(ctx context.Context, api *client.Client, m *Manifest) (*Plan, error) {
+	var cat []*model.Catalog
+	if err := listAll(func(opts *client.ListOptions) (*client.Page, error) {
+		cs, p, err := api.ListCatalog(ctx, opts)
+		cat = append(cat, cs...)
+		return p, err
+	}); err != nil {
+		return nil, err
+	}
+	pl := &planner{ctx: ctx, api: api, catalog: map[string]*model.Catalog{}, freeing: map[*Change]bool{}}
+	for _, ci := range cat {
+		pl.catalog[ci.Name] = ci
+	}
+
+	// resolve all projects before planning any, so references the caller can't resolve fail early
+	pids := make([]uint, len(m.Projects))
+	seen := map[uint]*Project{}
+	for i, mp := range m.Projects {
+		pid, err := pl.projectID(mp)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := seen[pid]; ok {
+			return nil, fmt.Errorf("The %s and the %s are the same project", other, mp)
+		}
+		seen[pid] = mp
+		pids[i] = pid
+	}
+
+	plan := &Plan{Changes: []*Change{}}
+	for i, mp := range m.Projects {
+		pid := pids[i]
+		changes, err := pl.project(pid, mp)
+		if err != nil {
+			return nil, err
+		}
+		sort.SliceStable(changes, func(i, j int) bool { return changes[i].order() < changes[j].order() })
+		plan.Changes = append(plan.Changes, changes...)
+	}
+	return plan, nil
+}
+
+// Plans changes of one project at a time
+type planner struct {
+	ctx     context.Context
+	api     *client.Client
+	catalog map[string]*model.Catalog
+	// deletions of resources which count against their quota now, so deleting them frees it up
+	freeing map[*Change]bool
+}
+
+// ID of a project of the manifest, looking up the personal project of users. Users can only be
+// looked up by email by admins, others have to give them by ID.
+func (pl *planner) projectID(mp *Project) (uint, error) {
+	if mp.User == "" {
+		return mp.Project, nil
+	}
+	if id, ok := mp.userID(); ok {
+		u, err := pl.api.GetUser(pl.ctx, id)
+		if err != nil {
+			return 0, err
+		}
+		return u.ProjectID, nil
+	}
+	us, _, err := pl.api.ListUsers(pl.ctx, &client.ListOptions{Filter: map[string]string{"email": mp.User}})
+	if client.ErrForbidden.Is(err) {
+		return 0, fmt.Errorf("Only admins can give users by email, give %s by ID instead", mp.User)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(us) == 0 {
+		return 0, fmt.Errorf("Unknown user %s", mp.User)
+	}
+	return us[0].ProjectID, nil
+}
+
+// Changes to converge a project, including quota problems
+func (pl *planner) project(pid uint, mp *Project) ([]*Change, error) {
+	var changes []*Change
+	if mp.Quotas != nil {
+		cs, err := pl.quotas(pid, mp.Quotas)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, cs...)
+	}
+	if mp.Resources != nil {
+		cs, err := pl.resources(pid, mp.Resources)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, cs...)
+	}
+	if err := pl.checkQuotas(pid, mp, changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+func (pl *planner) quotas(pid uint, want map[string]int) ([]*Change, error) {
+	var have []*model.Quota
+	if err := listAll(func(opts *client.ListOptions) (*client.Page, error) {
+		qs, p, err := pl.api.ListQuotas(pl.ctx, pid, opts)
+		have = append(have, qs...)
+		return p, err
+	}); err != nil {
+		return nil, err
+	}
+
+	var changes []*Change
+	current := map[string]*model.Quota{}
+	for _, q := range have {
+		current[q.Type] = q
+		if _, ok := want[q.Type]; !ok {
+			changes = append(changes, &Change{Action: Delete, Kind: KindQuota, ProjectID: pid, Name: q.Type, ID: q.ID, From: strconv.Itoa(q.Value)})
+		}
+	}
+	for _, tp := range sortedKeys(want) {
+		v := want[tp]
+		c := &Change{Kind: KindQuota, ProjectID: pid, Name: tp, To: strconv.Itoa(v)}
+		if q, ok := current[tp]; !ok {
+			c.Action = Create
+		} else if q.Value != v {
+			c.Action, c.ID, c.From = Update, q.ID, strconv.Itoa(q.Value)
+		} else {
+			continue
+		}
+		if _, ok := pl.catalog[tp]; !ok {
+			c.Problem = fmt.Sprintf("%s is not in the catalog", tp)
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+func (pl *planner) resources(pid uint, want []Resource) ([]*Change, error) {
+	var have []*model.Resource
+	if err := listAll(func(opts *client.ListOptions) (*client.Page, error) {
+		opts.Sort = "id"
+		rs, p, err := pl.api.ListResources(pl.ctx, pid, opts)
+		have = append(have, rs...)
+		return p, err
+	}); err != nil {
+		return nil, err
+	}
+
+	wanted := map[string]Resource{}
+	for _, r := range want {
+		wanted[r.Name] = r
+	}
+	var changes []*Change
+	current := map[string]*model.Resource{}
+	for _, r := range have {
+		// deleted resources are kept for their history, resources being deleted are on their way out
+		if r.Status == model.ResourceDeleted || r.Status == model.ResourceDeleting {
+			continue
+		}
+		// resources not in the manifest are deleted, as are duplicates of those that are, keeping the oldest.
+		// Failed resources are replaced, they are deleted and created again.
+		_, dup := current[r.Name]
+		if _, ok := wanted[r.Name]; !ok || dup || r.Status == model.ResourceFailed {
+			c := &Change{Action: Delete, Kind: KindResource, ProjectID: pid, Name: r.Name, ID: r.ID, From: r.Type}
+			pl.freeing[c] = pl.counts(r)
+			changes = append(changes, c)
+			continue
+		}
+		current[r.Name] = r
+	}
+	for _, w := range want {
+		c := &Change{Kind: KindResource, ProjectID: pid, Name: w.Name, To: w.Type}
+		r, ok := current[w.Name]
+		switch {
+		case !ok:
+			c.Action = Create
+		case r.Type != w.Type:
+			c.Action, c.ID, c.From = Update, r.ID, r.Type
+			if r.Status != model.ResourceRunning {
+				c.Problem = fmt.Sprintf("only running resources can be resized, it is %s", r.Status)
+			}
+		default:
+			continue
+		}
+		if ci, ok := pl.catalog[w.Type]; !ok {
+			c.Problem = fmt.Sprintf("%s is not in the catalog", w.Type)
+		} else if ci.Status == model.CatalogRetired {
+			c.Problem = fmt.Sprintf("%s is retired", w.Type)
+		}
+		changes = append(changes, c)
+	}
+	return changes, nil
+}
+
+// Check the project stays within its quotas once the changes are made, with the quotas as they are
+// after the changes. Creations and resizes which would exceed a quota get a problem.
+func (pl *planner) checkQuotas(pid uint, mp *Project, changes []*Change) error {
+	usage, err := pl.api.ProjectUsage(pl.ctx, pid)
+	if err != nil {
+		return err
+	}
+	used := map[string]int{}
+	limits := map[string]*int{}
+	for _, u := range usage {
+		used[u.Type] = u.Used
+		limits[u.Type] = u.Limit
+	}
+	if mp.Quotas != nil {
+		limits = map[string]*int{}
+		for tp, v := range mp.Quotas {
+			v := v
+			limits[tp] = &v
+		}
+	}
+
+	// resources going away free up quota before others are added, as they are applied in that order
+	var adding []*Change
+	for _, c := range changes {
+		if c.Kind != KindResource {
+			continue
+		}
+		switch c.Action {
+		case Delete:
+			if pl.freeing[c] {
+				used[c.From]--
+			}
+		case Update:
+			used[c.From]--
+			adding = append(adding, c)
+		case Create:
+			adding = append(adding, c)
+		}
+	}
+	for _, c := range adding {
+		used[c.To]++
+		if l := limits[c.To]; l != nil && used[c.To] > *l && c.Problem == "" {
+			c.Problem = fmt.Sprintf("quota exceeded, %d of %d %s would be used", used[c.To], *l, c.To)
+		}
+	}
+	return nil
+}
+
+// Whether a resource counts against its quota, like the server decides: all resources do unless they
+// are stopped and their catalog item is free when stopped
+func (pl *planner) counts(r *model.Resource) bool {
+	ci, ok := pl.catalog[r.Type]
+	return !(r.Status == model.ResourceStopped && ok && ci.FreeWhenStopped)
+}
+
+// Fetch all pages of a list
+func listAll(page func(opts *client.ListOptions) (*client.Page, error)) error {
+	opts := &client.ListOptions{}
+	for {
+		p, err := page(opts)
+		if err != nil {
+			return err
+		}
+		if p.Next == "" {
+			return nil
+		}
+		opts.Cursor = p.Next
+	}
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
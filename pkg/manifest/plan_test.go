@@ -0,0 +1,309 @@
+package manifest_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/client"
+	"github.com/yodo-io/ycp/pkg/client/clienttest"
+	"github.com/yodo-io/ycp/pkg/manifest"
+	"github.com/yodo-io/ycp/pkg/model"
+	"github.com/yodo-io/ycp/pkg/provision"
+)
+
+// Start a server with sample data and a client logged in to it. Operations are provisioned in the
+// background until teardown.
+func mustInit(t *testing.T, email string) (*client.Client, *gorm.DB, func()) {
+	db := model.MustInitTestDB(true)
+	srv := clienttest.NewServer(db)
+	done := make(chan struct{})
+	go func() {
+		w := provision.NewWorker(db, provision.Nop())
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(5 * time.Millisecond):
+				w.ProcessPending()
+			}
+		}
+	}()
+	teardown := func() {
+		close(done)
+		srv.Close()
+		db.Close()
+	}
+
+	api, err := client.New(srv.URL)
+	if err != nil {
+		teardown()
+		t.Fatal(err)
+	}
+	if err := api.Login(context.Background(), email, "secret"); err != nil {
+		teardown()
+		t.Fatal(err)
+	}
+	return api, db, teardown
+}
+
+func mustParse(t *testing.T, doc string) *manifest.Manifest {
+	m, err := manifest.Parse(strings.NewReader(doc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// Changes without IDs, which depend on the sample data
+func changes(p *manifest.Plan) []manifest.Change {
+	res := make([]manifest.Change, len(p.Changes))
+	for i, c := range p.Changes {
+		res[i] = *c
+		res[i].ID = 0
+	}
+	return res
+}
+
+func TestPlanApply(t *testing.T) {
+	api, _, teardown := mustInit(t, "joe@example.org")
+	defer teardown()
+	ctx := context.Background()
+
+	// joe's project has a pasta pot and a rice pot
+	m := mustParse(t, `
+projects:
+- user: 1
+  resources:
+  - name: soup
+    type: pot.instance.small
+  - name: rice pot
+    type: pot.instance.large
+  - name: pasta pot
+    type: pot.instance.large
+`)
+	p, err := manifest.NewPlan(ctx, api, m)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []manifest.Change{
+		{Action: manifest.Update, Kind: manifest.KindResource, ProjectID: 1, Name: "rice pot", From: "pot.instance.xlarge", To: "pot.instance.large"},
+		{Action: manifest.Create, Kind: manifest.KindResource, ProjectID: 1, Name: "soup", To: "pot.instance.small"},
+	}, changes(p))
+	assert.Empty(t, p.Problems())
+
+	var done []string
+	err = p.Apply(ctx, api, 5*time.Millisecond, func(c *manifest.Change) { done = append(done, c.String()) })
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []string{
+		`update resource "rice pot" in project 1 from pot.instance.xlarge to pot.instance.large`,
+		`create resource "soup" in project 1 with pot.instance.small`,
+	}, done)
+	rs, _, err := api.ListResources(ctx, 1, &client.ListOptions{Filter: map[string]string{"name": "soup"}})
+	if assert.NoError(t, err) && assert.Len(t, rs, 1) {
+		assert.Equal(t, model.ResourceRunning, rs[0].Status)
+	}
+
+	// applying again changes nothing
+	p, err = manifest.NewPlan(ctx, api, m)
+	if assert.NoError(t, err) {
+		assert.Empty(t, p.Changes)
+	}
+
+	// resources not in the manifest are deleted
+	m = mustParse(t, `projects: [{project: 1, resources: [{name: soup, type: pot.instance.small}]}]`)
+	p, err = manifest.NewPlan(ctx, api, m)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []manifest.Change{
+			{Action: manifest.Delete, Kind: manifest.KindResource, ProjectID: 1, Name: "pasta pot", From: "pot.instance.large"},
+			{Action: manifest.Delete, Kind: manifest.KindResource, ProjectID: 1, Name: "rice pot", From: "pot.instance.large"},
+		}, changes(p))
+		assert.NoError(t, p.Apply(ctx, api, 5*time.Millisecond, nil))
+	}
+	p, err = manifest.NewPlan(ctx, api, m)
+	if assert.NoError(t, err) {
+		assert.Empty(t, p.Changes)
+	}
+
+	// joe can't set quotas
+	m = mustParse(t, `projects: [{project: 1, quotas: {pot.instance.small: 20}}]`)
+	p, err = manifest.NewPlan(ctx, api, m)
+	if assert.NoError(t, err) {
+		err = p.Apply(ctx, api, 5*time.Millisecond, nil)
+		assert.True(t, strings.Contains(err.Error(), "FORBIDDEN"), "%v", err)
+	}
+
+	// nor look up users by email, which fails before planning anything
+	m = mustParse(t, `projects: [{project: 1, resources: []}, {user: joe@example.org, resources: []}]`)
+	_, err = manifest.NewPlan(ctx, api, m)
+	if assert.Error(t, err) {
+		assert.Equal(t, "Only admins can give users by email, give joe@example.org by ID instead", err.Error())
+	}
+}
+
+func TestPlanReplacesFailed(t *testing.T) {
+	api, db, teardown := mustInit(t, "joe@example.org")
+	defer teardown()
+	ctx := context.Background()
+	err := db.Model(&model.Resource{}).Where("project_id = ? and name = ?", 1, "rice pot").Update("status", model.ResourceFailed).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := mustParse(t, `
+projects:
+- project: 1
+  resources:
+  - name: pasta pot
+    type: pot.instance.large
+  - name: rice pot
+    type: pot.instance.xlarge
+`)
+	p, err := manifest.NewPlan(ctx, api, m)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, []manifest.Change{
+		{Action: manifest.Delete, Kind: manifest.KindResource, ProjectID: 1, Name: "rice pot", From: "pot.instance.xlarge"},
+		{Action: manifest.Create, Kind: manifest.KindResource, ProjectID: 1, Name: "rice pot", To: "pot.instance.xlarge"},
+	}, changes(p))
+	if !assert.NoError(t, p.Apply(ctx, api, 5*time.Millisecond, nil)) {
+		return
+	}
+
+	rs, _, err := api.ListResources(ctx, 1, &client.ListOptions{Filter: map[string]string{"name": "rice pot"}})
+	if assert.NoError(t, err) && assert.Len(t, rs, 1) {
+		assert.Equal(t, model.ResourceRunning, rs[0].Status)
+	}
+	p, err = manifest.NewPlan(ctx, api, m)
+	if assert.NoError(t, err) {
+		assert.Empty(t, p.Changes)
+	}
+}
+
+func TestPlanQuotas(t *testing.T) {
+	api, db, teardown := mustInit(t, "admin@example.org")
+	defer teardown()
+	ctx := context.Background()
+	if err := db.Model(&model.Catalog{}).Where("name = ?", "pan.instance.xl").Update("status", model.CatalogRetired).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		doc     string
+		changes []manifest.Change
+	}{
+		{
+			// deleting the pasta pot frees up quota for the new one
+			doc: `
+projects:
+- user: joe@example.org
+  quotas:
+    pot.instance.large: 1
+  resources:
+  - name: rice pot
+    type: pot.instance.xlarge
+  - name: new pasta pot
+    type: pot.instance.large
+`,
+			changes: []manifest.Change{
+				{Action: manifest.Delete, Kind: manifest.KindQuota, ProjectID: 1, Name: "pot.instance.small", From: "10"},
+				{Action: manifest.Create, Kind: manifest.KindQuota, ProjectID: 1, Name: "pot.instance.large", To: "1"},
+				{Action: manifest.Delete, Kind: manifest.KindResource, ProjectID: 1, Name: "pasta pot", From: "pot.instance.large"},
+				{Action: manifest.Create, Kind: manifest.KindResource, ProjectID: 1, Name: "new pasta pot", To: "pot.instance.large"},
+			},
+		},
+		{
+			// only the resource going over the quota has a problem
+			doc: `
+projects:
+- project: 1
+  quotas:
+    pot.instance.small: 1
+    pot.instance.large: 1
+  resources:
+  - name: pasta pot
+    type: pot.instance.large
+  - name: rice pot
+    type: pot.instance.large
+  - name: soup
+    type: pot.instance.small
+  - name: more soup
+    type: pot.instance.small
+`,
+			changes: []manifest.Change{
+				{Action: manifest.Create, Kind: manifest.KindQuota, ProjectID: 1, Name: "pot.instance.large", To: "1"},
+				{Action: manifest.Update, Kind: manifest.KindQuota, ProjectID: 1, Name: "pot.instance.small", From: "10", To: "1"},
+				{Action: manifest.Update, Kind: manifest.KindResource, ProjectID: 1, Name: "rice pot", From: "pot.instance.xlarge", To: "pot.instance.large",
+					Problem: "quota exceeded, 2 of 1 pot.instance.large would be used"},
+				{Action: manifest.Create, Kind: manifest.KindResource, ProjectID: 1, Name: "soup", To: "pot.instance.small"},
+				{Action: manifest.Create, Kind: manifest.KindResource, ProjectID: 1, Name: "more soup", To: "pot.instance.small",
+					Problem: "quota exceeded, 2 of 1 pot.instance.small would be used"},
+			},
+		},
+		{
+			// current quotas apply if they aren't managed
+			doc: `
+projects:
+- project: 2
+  resources:
+  - name: stir fry pan
+    type: pan.instance.wok
+  - name: skillet for eggs
+    type: pan.instance.s
+  - name: paella pan
+    type: pan.instance.xl
+  - name: kettle
+    type: kettle.instance.s
+- project: 1
+  quotas:
+    kettle.instance.s: 1
+`,
+			changes: []manifest.Change{
+				{Action: manifest.Create, Kind: manifest.KindResource, ProjectID: 2, Name: "paella pan", To: "pan.instance.xl",
+					Problem: "pan.instance.xl is retired"},
+				{Action: manifest.Create, Kind: manifest.KindResource, ProjectID: 2, Name: "kettle", To: "kettle.instance.s",
+					Problem: "kettle.instance.s is not in the catalog"},
+				{Action: manifest.Delete, Kind: manifest.KindQuota, ProjectID: 1, Name: "pot.instance.small", From: "10"},
+				{Action: manifest.Create, Kind: manifest.KindQuota, ProjectID: 1, Name: "kettle.instance.s", To: "1",
+					Problem: "kettle.instance.s is not in the catalog"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		p, err := manifest.NewPlan(ctx, api, mustParse(t, tt.doc))
+		if assert.NoError(t, err, tt.doc) {
+			assert.Equal(t, tt.changes, changes(p), tt.doc)
+		}
+	}
+
+	// plans with problems aren't applied
+	p, err := manifest.NewPlan(ctx, api, mustParse(t, tests[1].doc))
+	if assert.NoError(t, err) {
+		assert.Len(t, p.Problems(), 2)
+		assert.Error(t, p.Apply(ctx, api, 5*time.Millisecond, nil))
+	}
+	qs, _, err := api.ListQuotas(ctx, 1, nil)
+	if assert.NoError(t, err) && assert.Len(t, qs, 1) {
+		assert.Equal(t, 10, qs[0].Value)
+	}
+
+	p, err = manifest.NewPlan(ctx, api, mustParse(t, tests[0].doc))
+	if assert.NoError(t, err) && assert.NoError(t, p.Apply(ctx, api, 5*time.Millisecond, nil)) {
+		p, err = manifest.NewPlan(ctx, api, mustParse(t, tests[0].doc))
+		if assert.NoError(t, err) {
+			assert.Empty(t, p.Changes)
+		}
+	}
+
+	// several entries for the same project are ambiguous
+	_, err = manifest.NewPlan(ctx, api, mustParse(t, `projects: [{project: 1, resources: []}, {user: "1", quotas: {}}]`))
+	assert.Error(t, err)
+	_, err = manifest.NewPlan(ctx, api, mustParse(t, `projects: [{user: nobody@example.org, resources: []}]`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Route label of requests which didn't match any route, so random paths can't create new series
+const unmatched = "unmatched"
+
+// Context key of the route template of a request
+const routeKey = "metrics.route"
+
+var (
+	requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ycp_http_requests_total",
+		Help: "HTTP requests handled, by method, route template and status code",
+	}, []string{"method", "route", "code"})
+	durations = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ycp_http_request_duration_seconds",
+		Help:    "Latency of HTTP requests, by method, route template and status code",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "code"})
+)
+
+// Middleware counts requests to the engine's routes and observes their latency. It must be used
+// with the engine routes are registered with, and before other handlers using Route.
+func Middleware(g *gin.Engine) gin.HandlerFunc {
+	var (
+		once   sync.Once
+		routes map[string][]string
+	)
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		// routes are only complete once the server runs
+		once.Do(func() {
+			routes = map[string][]string{}
+			for _, ri := range g.Routes() {
+				routes[ri.Method] = append(routes[ri.Method], ri.Path)
+			}
+		})
+		route := match(routes[c.Request.Method], c)
+		c.Set(routeKey, route)
+		c.Next()
+
+		code := strconv.Itoa(c.Writer.Status())
+		requests.WithLabelValues(c.Request.Method, route, code).Inc()
+		durations.WithLabelValues(c.Request.Method, route, code).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Route returns the template of the route matched by a request, e.g. /v1/users/:id for
+// /v1/users/1, as recorded by Middleware
+func Route(c *gin.Context) string {
+	if route, ok := c.Get(routeKey); ok {
+		return route.(string)
+	}
+	return unmatched
+}
+
+// The registered template a request was routed by: the one which, with the request's parameters
+// filled in, is the request path. Routes can't conflict, so at most one template matches.
+func match(templates []string, c *gin.Context) string {
+	for _, tmpl := range templates {
+		if expand(tmpl, c.Params) == c.Request.URL.Path {
+			return tmpl
+		}
+	}
+	return unmatched
+}
+
+// Fill in the parameters of a template, e.g. /v1/users/1 for /v1/users/:id
+func expand(tmpl string, params gin.Params) string {
+	segs := strings.Split(tmpl, "/")
+	for i, s := range segs {
+		if s == "" || (s[0] != ':' && s[0] != '*') {
+			continue
+		}
+		v, ok := params.Get(s[1:])
+		if !ok {
+			return ""
+		}
+		// catch-all parameters include the leading slash
+		if s[0] == '*' {
+			return strings.Join(segs[:i], "/") + v
+		}
+		segs[i] = v
+	}
+	return strings.Join(segs, "/")
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	g.Use(Middleware(g))
+	var route string
+	handler := func(c *gin.Context) {
+		route = Route(c)
+		c.Status(http.StatusNoContent)
+	}
+	g.GET("/resources/:uid/:rid", handler)
+	g.GET("/catalog", handler)
+	g.GET("/catalog/:name", handler)
+	g.GET("/files/*path", handler)
+
+	tests := []struct {
+		path  string
+		route string
+		code  string
+	}{
+		{path: "/resources/1/1", route: "/resources/:uid/:rid", code: "204"},
+		{path: "/resources/1/2", route: "/resources/:uid/:rid", code: "204"},
+		{path: "/catalog", route: "/catalog", code: "204"},
+		// parameter values equal to segments of the template don't confuse it
+		{path: "/catalog/catalog", route: "/catalog/:name", code: "204"},
+		{path: "/files/a/b", route: "/files/*path", code: "204"},
+		{path: "/resources/1", route: unmatched, code: "404"},
+		{path: "/random/path", route: unmatched, code: "404"},
+	}
+	for _, tt := range tests {
+		route = ""
+		before := testutil.ToFloat64(requests.WithLabelValues(http.MethodGet, tt.route, tt.code))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, tt.path, nil)
+		g.ServeHTTP(w, req)
+
+		assert.Equal(t, before+1, testutil.ToFloat64(requests.WithLabelValues(http.MethodGet, tt.route, tt.code)), tt.path)
+		if tt.code == "204" {
+			assert.Equal(t, tt.route, route, tt.path)
+		}
+	}
+}
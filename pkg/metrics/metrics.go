@@ -0,0 +1,76 @@
+/*
+Package metrics exposes Prometheus metrics of the server, using the official client library, see
+https://github.com/prometheus/client_golang
+
+Packages define the metrics they report as package variables, registered with the default registry:
+
+	var denials = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ycp_rbac_denials_total",
+		Help: "Requests denied by RBAC",
+	}, []string{"route"})
+
+	// when a request is denied
+	denials.WithLabelValues(metrics.Route(c)).Inc()
+
+Request counts and latencies are recorded by Middleware. Gauges are read when metrics are scraped,
+e.g. from the database, see NewGaugeFunc.
+*/
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Handler serves the metrics of the default registry. Gauges failing to collect their samples are
+// skipped, so the other metrics are still served.
+func Handler() http.Handler {
+	return handlerFor(prometheus.DefaultGatherer)
+}
+
+func handlerFor(g prometheus.Gatherer) http.Handler {
+	return promhttp.HandlerFor(g, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError})
+}
+
+// Sample is the value of a gauge for one set of label values
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+// GaugeFunc is a gauge whose samples are collected on every scrape
+type GaugeFunc struct {
+	desc *prometheus.Desc
+	fn   func() ([]Sample, error)
+}
+
+// NewGaugeFunc creates a gauge collected by calling fn on every scrape, registered with the default
+// registry. Label values of samples are given in the order of the label names.
+func NewGaugeFunc(name, help string, fn func() ([]Sample, error), labels ...string) *GaugeFunc {
+	g := newGaugeFunc(name, help, fn, labels...)
+	prometheus.MustRegister(g)
+	return g
+}
+
+func newGaugeFunc(name, help string, fn func() ([]Sample, error), labels ...string) *GaugeFunc {
+	return &GaugeFunc{desc: prometheus.NewDesc(name, help, labels, nil), fn: fn}
+}
+
+// Describe implements prometheus.Collector
+func (g *GaugeFunc) Describe(ch chan<- *prometheus.Desc) {
+	ch <- g.desc
+}
+
+// Collect implements prometheus.Collector
+func (g *GaugeFunc) Collect(ch chan<- prometheus.Metric) {
+	samples, err := g.fn()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(g.desc, err)
+		return
+	}
+	for _, s := range samples {
+		ch <- prometheus.MustNewConstMetric(g.desc, prometheus.GaugeValue, s.Value, s.Labels...)
+	}
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGaugeFunc(t *testing.T) {
+	g := newGaugeFunc("test_items", "Items by kind", func() ([]Sample, error) {
+		return []Sample{{Labels: []string{`b"`}, Value: 2}, {Labels: []string{"a"}, Value: 1.5}}, nil
+	}, "kind")
+
+	err := testutil.CollectAndCompare(g, strings.NewReader(`# HELP test_items Items by kind
+# TYPE test_items gauge
+test_items{kind="a"} 1.5
+test_items{kind="b\""} 2
+`))
+	assert.NoError(t, err)
+}
+
+func TestFailingGauge(t *testing.T) {
+	r := prometheus.NewRegistry()
+	r.MustRegister(newGaugeFunc("test_broken", "Broken", func() ([]Sample, error) {
+		return nil, errors.New("no db")
+	}))
+	c := prometheus.NewCounter(prometheus.CounterOpts{Name: "test_total", Help: "Total"})
+	c.Inc()
+	r.MustRegister(c)
+
+	_, err := r.Gather()
+	assert.Error(t, err)
+	// other metrics are still served
+	h := handlerFor(r)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/metrics", nil)
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "test_total 1\n")
+	assert.NotContains(t, w.Body.String(), "test_broken")
+}
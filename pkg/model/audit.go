@@ -0,0 +1,80 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Outcome of an audited request
+const (
+	AuditSucceeded AuditOutcome = "succeeded"
+	AuditFailed    AuditOutcome = "failed"
+	// AuditDenied requests were rejected by access control
+	AuditDenied AuditOutcome = "denied"
+)
+
+// AuditOutcome is the outcome of an audited request
+type AuditOutcome string
+
+// AuditRecord records a mutating API request: who did what to which object, and how it went.
+// Records are only ever added, never changed.
+type AuditRecord struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	CreatedAt time.Time `                   json:"createdAt"`
+	// ActorID is the user making the request according to their token, 0 if unknown
+	ActorID    uint   `json:"actorId"`
+	ActorEmail string `json:"actorEmail"`
+	// Action is create, update or delete, or the name of a resource action such as stop
+	Action string `json:"action"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	// TargetType is the table of the object acted on, TargetID its primary key. Keys with several
+	// columns are joined by slashes, e.g. "3/1" for member 1 of project 3.
+	TargetType string `json:"targetType"`
+	TargetID   string `json:"targetId"`
+	// Diff holds the fields of the target which were changed
+	Diff      AuditDiff    `gorm:"type:text" json:"diff"`
+	SourceIP  string       `json:"sourceIp"`
+	RequestID string       `json:"requestId"`
+	Status    int          `json:"status"`
+	Outcome   AuditOutcome `json:"outcome"`
+	// Error is set if the request didn't succeed
+	Error string `json:"error,omitempty"`
+}
+
+// AuditChange is the value of a field before and after a request, nil if the field didn't exist
+type AuditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// AuditDiff maps changed fields to their change. It is stored as JSON.
+type AuditDiff map[string]AuditChange
+
+// Value implements driver.Valuer
+func (d AuditDiff) Value() (driver.Value, error) {
+	if d == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(d)
+	return string(b), err
+}
+
+// Scan implements sql.Scanner
+func (d *AuditDiff) Scan(v interface{}) error {
+	var b []byte
+	switch s := v.(type) {
+	case nil:
+		*d = nil
+		return nil
+	case string:
+		b = []byte(s)
+	case []byte:
+		b = s
+	default:
+		return fmt.Errorf("Cannot scan %T into AuditDiff", v)
+	}
+	return json.Unmarshal(b, d)
+}
@@ -0,0 +1,35 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanInsertAuditRecord(t *testing.T) {
+	db := MustInitTestDB(false)
+	defer db.Close()
+
+	tests := []AuditDiff{
+		{"name": {From: "pot", To: "pan"}, "value": {From: nil, To: float64(5)}},
+		{},
+		nil,
+	}
+	for _, diff := range tests {
+		ar := AuditRecord{ActorID: 1, Action: "update", Method: "PATCH", Path: "/v1/resources/1/1", Diff: diff, Status: 200, Outcome: AuditSucceeded}
+		if err := db.Create(&ar).Error; err != nil {
+			t.Fatal(err)
+		}
+
+		var res AuditRecord
+		if err := db.First(&res, ar.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, res.Diff, len(diff))
+		for k, v := range diff {
+			assert.Equal(t, v, res.Diff[k])
+		}
+		assert.Equal(t, AuditSucceeded, res.Outcome)
+		assert.False(t, res.CreatedAt.IsZero())
+	}
+}
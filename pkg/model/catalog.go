@@ -2,13 +2,75 @@ package model
 
 import (
 	"fmt"
+	"strings"
 )
 
+// Lifecycle status of a catalog item
+const (
+	// CatalogAvailable items can be used without restrictions
+	CatalogAvailable CatalogStatus = "available"
+	// CatalogDeprecated items can still be used, but are going to be retired
+	CatalogDeprecated CatalogStatus = "deprecated"
+	// CatalogRetired items can no longer be used for new resources
+	CatalogRetired CatalogStatus = "retired"
+)
+
+// CatalogStatus is the lifecycle status of a catalog item
+type CatalogStatus string
+
+// Valid checks if the status is one of the known statuses
+func (s CatalogStatus) Valid() bool {
+	switch s {
+	case CatalogAvailable, CatalogDeprecated, CatalogRetired:
+		return true
+	}
+	return false
+}
+
 // Catalog is an item in the catalog of available resources
 type Catalog struct {
-	Name string `gorm:"not null;primary_key"`
+	Name        string `gorm:"not null;primary_key"`
+	DisplayName string `json:"displayName"`
+	Description string `json:"description"`
+	// Category groups similar items, e.g. "pot" for "pot.instance.small"
+	Category string `json:"category"`
+	Specs    Specs  `gorm:"embedded;embedded_prefix:spec_" json:"specs"`
+	// HourlyPrice is given in the smallest unit of Currency, e.g. cents
+	HourlyPrice int64         `json:"hourlyPrice"`
+	Currency    string        `json:"currency"`
+	Status      CatalogStatus `json:"status"`
+	// FreeWhenStopped items don't count against quotas and aren't billed while stopped, so
+	// users can park resources without losing them
+	FreeWhenStopped bool `json:"freeWhenStopped"`
+}
+
+// Specs describes what a catalog item provides
+type Specs struct {
+	// Capacity in litres
+	Capacity float64 `json:"capacity"`
+	// Size, i.e. diameter, in centimetres
+	Size int `json:"size"`
 }
 
 func (c Catalog) String() string {
 	return fmt.Sprintf(`Catalog{Name:"%s"}`, c.Name)
 }
+
+// BeforeCreate fills in defaults for fields left empty
+func (c *Catalog) BeforeCreate() error {
+	if c.DisplayName == "" {
+		c.DisplayName = c.Name
+	}
+	if c.Category == "" {
+		c.Category = CategoryOf(c.Name)
+	}
+	if c.Status == "" {
+		c.Status = CatalogAvailable
+	}
+	return nil
+}
+
+// CategoryOf derives the category from the dotted prefix of a catalog item name
+func CategoryOf(name string) string {
+	return strings.SplitN(name, ".", 2)[0]
+}
@@ -50,3 +50,109 @@ func TestListCatalog(t *testing.T) {
 
 	assert.Len(t, result, len(sampleCatalog))
 }
+
+func TestCatalogDefaults(t *testing.T) {
+	db := MustInitTestDB(false)
+	defer db.Close()
+
+	item := Catalog{Name: "db.instance.small"}
+	if err := db.Create(&item).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var res Catalog
+	if err := db.First(&res, "name = ?", item.Name).Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "db.instance.small", res.DisplayName)
+	assert.Equal(t, "db", res.Category)
+	assert.Equal(t, CatalogAvailable, res.Status)
+}
+
+func TestCategoryOf(t *testing.T) {
+	tests := []struct {
+		name     string
+		category string
+	}{
+		{name: "pot.instance.small", category: "pot"},
+		{name: "pan.instance.wok", category: "pan"},
+		{name: "teapot", category: "teapot"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.category, CategoryOf(tt.name))
+	}
+}
+
+func TestCatalogStatusValid(t *testing.T) {
+	for _, s := range []CatalogStatus{CatalogAvailable, CatalogDeprecated, CatalogRetired} {
+		assert.True(t, s.Valid(), string(s))
+	}
+	for _, s := range []CatalogStatus{"", "gone", "Available"} {
+		assert.False(t, s.Valid(), string(s))
+	}
+}
+
+func TestMigrateCatalogDetails(t *testing.T) {
+	db, _, td := mustOpenFileDB(t)
+	defer td()
+
+	// schema before catalog details, items only have a name
+	if err := db.AutoMigrate(&schemaMigration{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations {
+		if m.Version >= 6 {
+			break
+		}
+		if err := apply(db, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Exec(`INSERT INTO catalogs (name) VALUES ('pot.instance.small'), ('teapot')`).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	var res []Catalog
+	if err := db.Order("name").Find(&res).Error; err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, res, 2) {
+		assert.Equal(t, "pot", res[0].Category)
+		assert.Equal(t, "pot.instance.small", res[0].DisplayName)
+		assert.Equal(t, CatalogAvailable, res[0].Status)
+		assert.Equal(t, "teapot", res[1].Category)
+	}
+}
+
+func TestMigrateCatalogFreeWhenStopped(t *testing.T) {
+	db, _, td := mustOpenFileDB(t)
+	defer td()
+
+	if err := db.AutoMigrate(&schemaMigration{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations {
+		if m.Version >= 8 {
+			break
+		}
+		if err := apply(db, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Exec(`INSERT INTO catalogs (name) VALUES ('pot.instance.small')`).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// existing items keep counting against quotas when stopped
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	var c Catalog
+	if err := db.First(&c, "name = ?", "pot.instance.small").Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.False(t, c.FreeWhenStopped)
+}
@@ -8,11 +8,11 @@ import (
 	_ "github.com/jinzhu/gorm/dialects/sqlite"
 )
 
-// MustInitTestDB Initialises a DB instance for testing only. As of now, this is an SQlite in-memory DB
+// MustInitTestDB Initialises a DB instance for testing only. As of now, this is an SQlite in-memory DB, migrated to the latest version
 // so it will loose state upon calling `DB.disconnect()`
 // This function is for testing purpose only, it will panic if it encounters any errors.
 func MustInitTestDB(sampleData bool) *gorm.DB {
-	db, err := gorm.Open("sqlite3", ":memory:")
+	db, err := Open("sqlite3", ":memory:")
 	if err != nil {
 		panic(fmt.Sprintf("Error setting up db - %v", err))
 	}
@@ -1,29 +1,69 @@
 package model
 
 import (
+	"database/sql"
+	"strings"
+
 	"github.com/jinzhu/gorm"
 )
 
-// migrateAll migrates the given database's schema for all model types
-// auto migration will only add missing fields, won't delete/change current data
-func migrateAll(db *gorm.DB) error {
-	return db.AutoMigrate(
-		&Catalog{},
-		&User{},
-		&Resource{},
-		&Quota{},
-	).Error
+// Open opens a database connection. SQLite databases are limited to a single connection: in-memory
+// databases exist per connection, and SQLite only allows a single writer at a time anyway.
+func Open(driver, conn string) (*gorm.DB, error) {
+	db, err := gorm.Open(driver, conn)
+	if err != nil {
+		return nil, err
+	}
+	if driver == "sqlite3" {
+		db.DB().SetMaxOpenConns(1)
+	}
+	return db, nil
 }
 
-// Setup migrates the DB and optionally loads sample data
+// Setup migrates the DB to the latest schema version and optionally loads sample data.
+// Sample data is only loaded into an empty database, so it is safe to call this on every start.
 func Setup(db *gorm.DB, sampleData bool) error {
-	if err := migrateAll(db); err != nil {
+	if _, err := MigrateUp(db); err != nil {
+		return err
+	}
+	if !sampleData {
+		return nil
+	}
+	var n int
+	if err := db.Model(&User{}).Count(&n).Error; err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	return loadSampleData(db)
+}
+
+// Transaction runs fn in a transaction, commits if it succeeds and rolls back otherwise.
+// If db is a transaction already, fn becomes part of it.
+func Transaction(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	if _, ok := db.CommonDB().(*sql.Tx); ok {
+		return fn(db)
+	}
+	tx := db.Begin()
+	if err := tx.Error; err != nil {
 		return err
 	}
-	if sampleData {
-		if err := loadSampleData(db); err != nil {
-			return err
-		}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+// IsUniqueViolation tells whether err is from an insert or update violating a unique index. Drivers
+// don't share error types, so their messages are matched: SQLite, PostgreSQL and MySQL are known.
+func IsUniqueViolation(err error) bool {
+	if err == nil {
+		return false
 	}
-	return nil
+	msg := err.Error()
+	return strings.Contains(msg, "UNIQUE constraint failed") ||
+		strings.Contains(msg, "violates unique constraint") ||
+		strings.Contains(msg, "Duplicate entry")
 }
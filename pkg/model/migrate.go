@@ -0,0 +1,422 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Migration is a versioned, reversible change to the database schema.
+// Migrations must never be changed once released, add a new one instead.
+type Migration struct {
+	Version uint
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// MigrationState describes a migration and whether it has been applied
+type MigrationState struct {
+	Version   uint
+	Name      string
+	AppliedAt *time.Time
+}
+
+// Pending is true if the migration has not been applied yet
+func (s MigrationState) Pending() bool {
+	return s.AppliedAt == nil
+}
+
+// Book-keeping of applied migrations
+type schemaMigration struct {
+	Version   uint `gorm:"primary_key;auto_increment:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrations are applied in order of their version, versions must be unique and ascending.
+// Statements are written for SQLite, which is the only supported driver for now.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "initial schema",
+		Up: exec(
+			`CREATE TABLE "catalogs" ("name" varchar(255) NOT NULL, PRIMARY KEY ("name"))`,
+			`CREATE TABLE "users" ("id" integer primary key autoincrement, "email" varchar(255) NOT NULL, "password" varchar(255) NOT NULL, "role" varchar(255) NOT NULL)`,
+			`CREATE UNIQUE INDEX uix_users_email ON "users"("email")`,
+			`CREATE TABLE "resources" ("id" integer primary key autoincrement, "name" varchar(255) NOT NULL, "user_id" integer, "type" varchar(255))`,
+			// gorm's inflection treats "quota" as plural already
+			`CREATE TABLE "quota" ("id" integer primary key autoincrement, "type" varchar(255), "user_id" integer, "value" integer)`,
+		),
+		Down: exec(
+			`DROP TABLE "quota"`,
+			`DROP TABLE "resources"`,
+			`DROP TABLE "users"`,
+			`DROP TABLE "catalogs"`,
+		),
+	},
+	{
+		Version: 2,
+		Name:    "refresh and revoked tokens",
+		Up: exec(
+			`CREATE TABLE "refresh_tokens" ("id" integer primary key autoincrement, "hash" varchar(255) NOT NULL, "family" varchar(255) NOT NULL, "user_id" integer NOT NULL, "access_jti" varchar(255) NOT NULL, "expires_at" datetime NOT NULL, "used_at" datetime, "revoked_at" datetime)`,
+			`CREATE UNIQUE INDEX uix_refresh_tokens_hash ON "refresh_tokens"("hash")`,
+			`CREATE INDEX idx_refresh_tokens_family ON "refresh_tokens"("family")`,
+			`CREATE INDEX idx_refresh_tokens_user_id ON "refresh_tokens"("user_id")`,
+			`CREATE TABLE "revoked_tokens" ("jti" varchar(255) NOT NULL, "expires_at" datetime NOT NULL, PRIMARY KEY ("jti"))`,
+		),
+		Down: exec(
+			`DROP TABLE "revoked_tokens"`,
+			`DROP TABLE "refresh_tokens"`,
+		),
+	},
+	{
+		Version: 3,
+		Name:    "rbac rules",
+		Up: exec(
+			`CREATE TABLE "rbac_rules" ("id" integer primary key autoincrement, "role" varchar(255) NOT NULL, "path" varchar(255) NOT NULL, "action" varchar(255) NOT NULL)`,
+			`CREATE INDEX idx_rbac_rules_role ON "rbac_rules"("role")`,
+			// rules previously hardcoded in package rbac, admins used to bypass all rules
+			`INSERT INTO "rbac_rules" ("role", "path", "action") VALUES
+				('admin', '.*', '.*'),
+				('user', '/v\d+/catalog', 'GET'),
+				('user', '/v\d+/resources/{{.UserID}}', '.*'),
+				('user', '/v\d+/quotas/{{.UserID}}', 'GET'),
+				('user', '/v\d+/users/{{.UserID}}', '.*')`,
+		),
+		Down: exec(
+			`DROP TABLE "rbac_rules"`,
+		),
+	},
+	{
+		Version: 4,
+		Name:    "custom roles",
+		Up: exec(
+			`CREATE TABLE "roles" ("name" varchar(255) NOT NULL, "description" varchar(255), PRIMARY KEY ("name"))`,
+			`INSERT INTO "roles" ("name", "description") VALUES
+				('admin', 'Full access'),
+				('user', 'Access to own resources'),
+				('auditor', 'Read-only access to everything')`,
+			`INSERT INTO "rbac_rules" ("role", "path", "action") VALUES ('auditor', '/v\d+/.*', 'GET')`,
+			// roles only referenced by rules or users need to exist as well
+			`INSERT OR IGNORE INTO "roles" ("name") SELECT DISTINCT "role" FROM "rbac_rules"`,
+			`INSERT OR IGNORE INTO "roles" ("name") SELECT DISTINCT "role" FROM "users"`,
+			`CREATE TABLE "user_roles" ("user_id" integer NOT NULL, "role" varchar(255) NOT NULL, PRIMARY KEY ("user_id", "role"))`,
+			`INSERT INTO "user_roles" ("user_id", "role") SELECT "id", "role" FROM "users"`,
+			// SQLite can't drop columns, so copy users into a new table without role
+			`CREATE TABLE "users_new" ("id" integer primary key autoincrement, "email" varchar(255) NOT NULL, "password" varchar(255) NOT NULL)`,
+			`INSERT INTO "users_new" ("id", "email", "password") SELECT "id", "email", "password" FROM "users"`,
+			`DROP TABLE "users"`,
+			`ALTER TABLE "users_new" RENAME TO "users"`,
+			`CREATE UNIQUE INDEX uix_users_email ON "users"("email")`,
+		),
+		Down: exec(
+			`CREATE TABLE "users_old" ("id" integer primary key autoincrement, "email" varchar(255) NOT NULL, "password" varchar(255) NOT NULL, "role" varchar(255) NOT NULL)`,
+			// users with several roles keep the alphabetically first one
+			`INSERT INTO "users_old" ("id", "email", "password", "role")
+				SELECT "id", "email", "password", COALESCE((SELECT MIN("role") FROM "user_roles" WHERE "user_id" = "users"."id"), 'user') FROM "users"`,
+			`DROP TABLE "users"`,
+			`ALTER TABLE "users_old" RENAME TO "users"`,
+			`CREATE UNIQUE INDEX uix_users_email ON "users"("email")`,
+			`DROP TABLE "user_roles"`,
+			`DELETE FROM "rbac_rules" WHERE "role" = 'auditor' AND "path" = '/v\d+/.*' AND "action" = 'GET'`,
+			`DROP TABLE "roles"`,
+		),
+	},
+	{
+		Version: 5,
+		Name:    "unique quota per user and type",
+		Up: exec(
+			// duplicates used to be possible, keep the oldest one, which is the one that was enforced
+			`DELETE FROM "quota" WHERE "id" NOT IN (SELECT MIN("id") FROM "quota" GROUP BY "user_id", "type")`,
+			`CREATE UNIQUE INDEX uix_quota_user_id_type ON "quota"("user_id", "type")`,
+		),
+		Down: exec(
+			`DROP INDEX uix_quota_user_id_type`,
+		),
+	},
+	{
+		Version: 6,
+		Name:    "catalog details",
+		Up: exec(
+			`ALTER TABLE "catalogs" ADD COLUMN "display_name" varchar(255) NOT NULL DEFAULT ''`,
+			`ALTER TABLE "catalogs" ADD COLUMN "description" varchar(255) NOT NULL DEFAULT ''`,
+			`ALTER TABLE "catalogs" ADD COLUMN "category" varchar(255) NOT NULL DEFAULT ''`,
+			`ALTER TABLE "catalogs" ADD COLUMN "spec_capacity" real NOT NULL DEFAULT 0`,
+			`ALTER TABLE "catalogs" ADD COLUMN "spec_size" integer NOT NULL DEFAULT 0`,
+			`ALTER TABLE "catalogs" ADD COLUMN "hourly_price" bigint NOT NULL DEFAULT 0`,
+			`ALTER TABLE "catalogs" ADD COLUMN "currency" varchar(255) NOT NULL DEFAULT ''`,
+			`ALTER TABLE "catalogs" ADD COLUMN "status" varchar(255) NOT NULL DEFAULT 'available'`,
+			// existing items only have a name to derive the rest from, see CategoryOf
+			`UPDATE "catalogs" SET "display_name" = "name", "category" = CASE
+				WHEN instr("name", '.') > 0 THEN substr("name", 1, instr("name", '.') - 1) ELSE "name" END`,
+		),
+		// SQLite can't drop columns, so copy catalogs into a new table with just the name
+		Down: exec(
+			`CREATE TABLE "catalogs_old" ("name" varchar(255) NOT NULL, PRIMARY KEY ("name"))`,
+			`INSERT INTO "catalogs_old" ("name") SELECT "name" FROM "catalogs"`,
+			`DROP TABLE "catalogs"`,
+			`ALTER TABLE "catalogs_old" RENAME TO "catalogs"`,
+		),
+	},
+	{
+		Version: 7,
+		Name:    "resource status and operations",
+		Up: exec(
+			// existing resources were usable as soon as they were created
+			`ALTER TABLE "resources" ADD COLUMN "status" varchar(255) NOT NULL DEFAULT 'running'`,
+			`ALTER TABLE "resources" ADD COLUMN "status_message" varchar(255) NOT NULL DEFAULT ''`,
+			`CREATE TABLE "operations" ("id" integer primary key autoincrement, "resource_id" integer NOT NULL, "action" varchar(255) NOT NULL, "status" varchar(255) NOT NULL, "error" varchar(255) NOT NULL DEFAULT '', "created_at" datetime, "updated_at" datetime)`,
+			`CREATE INDEX idx_operations_resource_id ON "operations"("resource_id")`,
+			`CREATE INDEX idx_operations_status ON "operations"("status")`,
+		),
+		Down: exec(
+			`DROP TABLE "operations"`,
+			// SQLite can't drop columns, so copy resources into a new table without status
+			`CREATE TABLE "resources_old" ("id" integer primary key autoincrement, "name" varchar(255) NOT NULL, "user_id" integer, "type" varchar(255))`,
+			`INSERT INTO "resources_old" ("id", "name", "user_id", "type") SELECT "id", "name", "user_id", "type" FROM "resources" WHERE "status" <> 'deleted'`,
+			`DROP TABLE "resources"`,
+			`ALTER TABLE "resources_old" RENAME TO "resources"`,
+		),
+	},
+	{
+		Version: 8,
+		Name:    "catalog free when stopped",
+		Up: exec(
+			`ALTER TABLE "catalogs" ADD COLUMN "free_when_stopped" boolean NOT NULL DEFAULT 0`,
+		),
+		// SQLite can't drop columns, so copy catalogs into a new table without the flag
+		Down: exec(
+			`CREATE TABLE "catalogs_old" ("name" varchar(255) NOT NULL, "display_name" varchar(255) NOT NULL DEFAULT '', "description" varchar(255) NOT NULL DEFAULT '', "category" varchar(255) NOT NULL DEFAULT '', "spec_capacity" real NOT NULL DEFAULT 0, "spec_size" integer NOT NULL DEFAULT 0, "hourly_price" bigint NOT NULL DEFAULT 0, "currency" varchar(255) NOT NULL DEFAULT '', "status" varchar(255) NOT NULL DEFAULT 'available', PRIMARY KEY ("name"))`,
+			`INSERT INTO "catalogs_old" SELECT "name", "display_name", "description", "category", "spec_capacity", "spec_size", "hourly_price", "currency", "status" FROM "catalogs"`,
+			`DROP TABLE "catalogs"`,
+			`ALTER TABLE "catalogs_old" RENAME TO "catalogs"`,
+		),
+	},
+	{
+		Version: 9,
+		Name:    "organizations and projects",
+		Up: exec(
+			`CREATE TABLE "organizations" ("id" integer primary key autoincrement, "name" varchar(255) NOT NULL, "description" varchar(255) NOT NULL DEFAULT '', "created_at" datetime)`,
+			`CREATE TABLE "organization_members" ("organization_id" integer NOT NULL, "user_id" integer NOT NULL, "role" varchar(255) NOT NULL, PRIMARY KEY ("organization_id", "user_id"))`,
+			`CREATE INDEX idx_organization_members_user_id ON "organization_members"("user_id")`,
+			`CREATE TABLE "projects" ("id" integer primary key autoincrement, "organization_id" integer NOT NULL, "name" varchar(255) NOT NULL, "description" varchar(255) NOT NULL DEFAULT '', "created_at" datetime)`,
+			`CREATE UNIQUE INDEX uix_projects_organization_id_name ON "projects"("organization_id", "name")`,
+			`CREATE TABLE "project_members" ("project_id" integer NOT NULL, "user_id" integer NOT NULL, "role" varchar(255) NOT NULL, PRIMARY KEY ("project_id", "user_id"))`,
+			`CREATE INDEX idx_project_members_user_id ON "project_members"("user_id")`,
+			// every user gets a personal organization and project, reusing the user's id for both
+			`ALTER TABLE "users" ADD COLUMN "project_id" integer NOT NULL DEFAULT 0`,
+			`INSERT INTO "organizations" ("id", "name", "description", "created_at")
+				SELECT "id", "email", 'Personal organization of ' || "email", CURRENT_TIMESTAMP FROM "users"`,
+			`INSERT INTO "organization_members" ("organization_id", "user_id", "role") SELECT "id", "id", 'owner' FROM "users"`,
+			`INSERT INTO "projects" ("id", "organization_id", "name", "created_at") SELECT "id", "id", 'default', CURRENT_TIMESTAMP FROM "users"`,
+			`UPDATE "users" SET "project_id" = "id"`,
+			// resources and quotas move to their user's personal project
+			`ALTER TABLE "resources" ADD COLUMN "project_id" integer NOT NULL DEFAULT 0`,
+			`UPDATE "resources" SET "project_id" = "user_id"`,
+			`CREATE INDEX idx_resources_project_id ON "resources"("project_id")`,
+			`CREATE TABLE "quota_new" ("id" integer primary key autoincrement, "type" varchar(255), "project_id" integer, "value" integer)`,
+			`INSERT INTO "quota_new" ("id", "type", "project_id", "value") SELECT "id", "type", "user_id", "value" FROM "quota"`,
+			`DROP TABLE "quota"`,
+			`ALTER TABLE "quota_new" RENAME TO "quota"`,
+			`CREATE UNIQUE INDEX uix_quota_project_id_type ON "quota"("project_id", "type")`,
+			// access to projects is decided by membership, see package rbac
+			`INSERT INTO "rbac_rules" ("role", "path", "action") VALUES
+				('user', '^/v\d+/orgs$', 'POST'),
+				('user', '^/v\d+/orgs/{{.Orgs "viewer"}}(/|$)', 'GET'),
+				('user', '^/v\d+/orgs/{{.Orgs "owner"}}(/members(/\d+)?|/projects)?$', '.*'),
+				('user', '^/v\d+/projects/{{.Projects "viewer"}}(/|$)', 'GET'),
+				('user', '^/v\d+/projects/{{.Projects "editor"}}/resources(/|$)', '.*'),
+				('user', '^/v\d+/projects/{{.Projects "owner"}}(/members(/\d+)?)?$', '.*')`,
+		),
+		Down: exec(
+			`DELETE FROM "rbac_rules" WHERE "path" LIKE '^/v\d+/orgs%' OR "path" LIKE '^/v\d+/projects%'`,
+			// SQLite can't drop columns, so copy tables without project ids. Only personal projects
+			// can be mapped back to users, quotas of other projects are dropped.
+			`CREATE TABLE "quota_old" ("id" integer primary key autoincrement, "type" varchar(255), "user_id" integer, "value" integer)`,
+			`INSERT INTO "quota_old" ("id", "type", "user_id", "value")
+				SELECT "quota"."id", "quota"."type", "users"."id", "quota"."value" FROM "quota" JOIN "users" ON "users"."project_id" = "quota"."project_id"`,
+			`DROP TABLE "quota"`,
+			`ALTER TABLE "quota_old" RENAME TO "quota"`,
+			`CREATE UNIQUE INDEX uix_quota_user_id_type ON "quota"("user_id", "type")`,
+			`CREATE TABLE "resources_old" ("id" integer primary key autoincrement, "name" varchar(255) NOT NULL, "user_id" integer, "type" varchar(255), "status" varchar(255) NOT NULL DEFAULT 'running', "status_message" varchar(255) NOT NULL DEFAULT '')`,
+			`INSERT INTO "resources_old" ("id", "name", "user_id", "type", "status", "status_message")
+				SELECT "id", "name", "user_id", "type", "status", "status_message" FROM "resources"`,
+			`DROP TABLE "resources"`,
+			`ALTER TABLE "resources_old" RENAME TO "resources"`,
+			`CREATE TABLE "users_old" ("id" integer primary key autoincrement, "email" varchar(255) NOT NULL, "password" varchar(255) NOT NULL)`,
+			`INSERT INTO "users_old" ("id", "email", "password") SELECT "id", "email", "password" FROM "users"`,
+			`DROP TABLE "users"`,
+			`ALTER TABLE "users_old" RENAME TO "users"`,
+			`CREATE UNIQUE INDEX uix_users_email ON "users"("email")`,
+			`DROP TABLE "project_members"`,
+			`DROP TABLE "projects"`,
+			`DROP TABLE "organization_members"`,
+			`DROP TABLE "organizations"`,
+		),
+	},
+	{
+		Version: 10,
+		Name:    "audit log",
+		Up: exec(
+			`CREATE TABLE "audit_records" ("id" integer primary key autoincrement, "created_at" datetime, "actor_id" integer NOT NULL DEFAULT 0, "actor_email" varchar(255) NOT NULL DEFAULT '', "action" varchar(255) NOT NULL, "method" varchar(255) NOT NULL, "path" varchar(255) NOT NULL, "target_type" varchar(255) NOT NULL DEFAULT '', "target_id" varchar(255) NOT NULL DEFAULT '', "diff" text NOT NULL DEFAULT '{}', "source_ip" varchar(255) NOT NULL DEFAULT '', "request_id" varchar(255) NOT NULL DEFAULT '', "status" integer NOT NULL, "outcome" varchar(255) NOT NULL, "error" varchar(255) NOT NULL DEFAULT '')`,
+			`CREATE INDEX idx_audit_records_created_at ON "audit_records"("created_at")`,
+			`CREATE INDEX idx_audit_records_actor_id ON "audit_records"("actor_id")`,
+			`CREATE INDEX idx_audit_records_target ON "audit_records"("target_type", "target_id")`,
+		),
+		Down: exec(
+			`DROP TABLE "audit_records"`,
+		),
+	},
+	{
+		Version: 11,
+		Name:    "admins create organizations and projects",
+		Up: exec(
+			// projects without quotas are unlimited, so users creating their own would bypass their quotas
+			`DELETE FROM "rbac_rules" WHERE "role" = 'user' AND "path" = '^/v\d+/orgs$' AND "action" = 'POST'`,
+			`UPDATE "rbac_rules" SET "path" = '^/v\d+/orgs/{{.Orgs "owner"}}(/members(/\d+)?)?$'
+				WHERE "role" = 'user' AND "path" = '^/v\d+/orgs/{{.Orgs "owner"}}(/members(/\d+)?|/projects)?$'`,
+		),
+		Down: exec(
+			`UPDATE "rbac_rules" SET "path" = '^/v\d+/orgs/{{.Orgs "owner"}}(/members(/\d+)?|/projects)?$'
+				WHERE "role" = 'user' AND "path" = '^/v\d+/orgs/{{.Orgs "owner"}}(/members(/\d+)?)?$'`,
+			`INSERT INTO "rbac_rules" ("role", "path", "action") VALUES ('user', '^/v\d+/orgs$', 'POST')`,
+		),
+	},
+	{
+		Version: 12,
+		Name:    "anchor rules of users",
+		Up: exec(
+			// unanchored, the rules of user 1 matched the paths of users 10, 11, 100 and so on
+			`UPDATE "rbac_rules" SET "path" = '^/v\d+/users/{{.UserID}}(/|$)' WHERE "path" = '/v\d+/users/{{.UserID}}'`,
+			`UPDATE "rbac_rules" SET "path" = '^/v\d+/resources/{{.UserID}}(/|$)' WHERE "path" = '/v\d+/resources/{{.UserID}}'`,
+			`UPDATE "rbac_rules" SET "path" = '^/v\d+/quotas/{{.UserID}}(/|$)' WHERE "path" = '/v\d+/quotas/{{.UserID}}'`,
+		),
+		Down: exec(
+			`UPDATE "rbac_rules" SET "path" = '/v\d+/users/{{.UserID}}' WHERE "path" = '^/v\d+/users/{{.UserID}}(/|$)'`,
+			`UPDATE "rbac_rules" SET "path" = '/v\d+/resources/{{.UserID}}' WHERE "path" = '^/v\d+/resources/{{.UserID}}(/|$)'`,
+			`UPDATE "rbac_rules" SET "path" = '/v\d+/quotas/{{.UserID}}' WHERE "path" = '^/v\d+/quotas/{{.UserID}}(/|$)'`,
+		),
+	},
+}
+
+// Helper to create a migration func from a list of SQL statements
+func exec(stmts ...string) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		for _, s := range stmts {
+			if err := tx.Exec(s).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// MigrateUp applies all pending migrations and returns the number of migrations applied
+func MigrateUp(db *gorm.DB) (int, error) {
+	if err := db.AutoMigrate(&schemaMigration{}).Error; err != nil {
+		return 0, err
+	}
+	states, err := MigrationStatus(db)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for i, s := range states {
+		if !s.Pending() {
+			continue
+		}
+		if err := apply(db, migrations[i]); err != nil {
+			return n, fmt.Errorf("Migration %d (%s) failed: %v", s.Version, s.Name, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// MigrateDown reverts the most recently applied migration and returns it
+func MigrateDown(db *gorm.DB) (*MigrationState, error) {
+	states, err := MigrationStatus(db)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(states) - 1; i >= 0; i-- {
+		if states[i].Pending() {
+			continue
+		}
+		if err := revert(db, migrations[i]); err != nil {
+			return nil, fmt.Errorf("Migration %d (%s) failed: %v", states[i].Version, states[i].Name, err)
+		}
+		return &states[i], nil
+	}
+	return nil, errors.New("No migrations to revert")
+}
+
+// MigrationStatus returns the state of all known migrations, ordered by version. It doesn't change
+// the database: without a migrations table, all migrations are pending.
+func MigrationStatus(db *gorm.DB) ([]MigrationState, error) {
+	var applied []*schemaMigration
+	if db.HasTable(&schemaMigration{}) {
+		if err := db.Find(&applied).Error; err != nil {
+			return nil, err
+		}
+	}
+	byVersion := map[uint]*schemaMigration{}
+	for _, m := range applied {
+		byVersion[m.Version] = m
+	}
+
+	states := make([]MigrationState, len(migrations))
+	for i, m := range migrations {
+		states[i] = MigrationState{Version: m.Version, Name: m.Name}
+		if a, ok := byVersion[m.Version]; ok {
+			states[i].AppliedAt = &a.AppliedAt
+		}
+	}
+	return states, nil
+}
+
+// PendingMigrations returns the migrations which haven't been applied yet, ordered by version. It only
+// reads the applied versions, so it is cheap enough for probes, and fails if the migrations table
+// doesn't exist yet.
+func PendingMigrations(db *gorm.DB) ([]MigrationState, error) {
+	var versions []uint
+	if err := db.Model(&schemaMigration{}).Pluck("version", &versions).Error; err != nil {
+		return nil, err
+	}
+	applied := map[uint]bool{}
+	for _, v := range versions {
+		applied[v] = true
+	}
+	var res []MigrationState
+	for _, m := range migrations {
+		if !applied[m.Version] {
+			res = append(res, MigrationState{Version: m.Version, Name: m.Name})
+		}
+	}
+	return res, nil
+}
+
+func apply(db *gorm.DB, m Migration) error {
+	return Transaction(db, func(tx *gorm.DB) error {
+		if err := m.Up(tx); err != nil {
+			return err
+		}
+		return tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+	})
+}
+
+func revert(db *gorm.DB, m Migration) error {
+	return Transaction(db, func(tx *gorm.DB) error {
+		if err := m.Down(tx); err != nil {
+			return err
+		}
+		return tx.Delete(&schemaMigration{}, "version = ?", m.Version).Error
+	})
+}
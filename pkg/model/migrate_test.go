@@ -0,0 +1,124 @@
+package model
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+)
+
+func mustOpenFileDB(t *testing.T) (*gorm.DB, string, func()) {
+	dir, err := ioutil.TempDir("", "ycp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "ycp.db")
+	db, err := Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.LogMode(false)
+	return db, path, func() {
+		db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	db, _, td := mustOpenFileDB(t)
+	defer td()
+
+	// nothing applied yet
+	states, err := MigrationStatus(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, states, len(migrations))
+	for _, s := range states {
+		assert.True(t, s.Pending())
+	}
+	assert.False(t, db.HasTable(&schemaMigration{}), "status must not create the migrations table")
+
+	// apply all
+	n, err := MigrateUp(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(migrations), n)
+	assert.True(t, db.HasTable(&User{}))
+
+	// idempotent
+	n, err = MigrateUp(db)
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+
+	states, err = MigrationStatus(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range states {
+		assert.False(t, s.Pending())
+	}
+	pending, err := PendingMigrations(db)
+	assert.NoError(t, err)
+	assert.Empty(t, pending)
+
+	// revert all, one by one
+	for i := len(migrations) - 1; i >= 0; i-- {
+		s, err := MigrateDown(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, migrations[i].Version, s.Version)
+	}
+	assert.False(t, db.HasTable(&User{}))
+	pending, err = PendingMigrations(db)
+	assert.NoError(t, err)
+	assert.Len(t, pending, len(migrations))
+	if assert.NoError(t, db.DropTable(&schemaMigration{}).Error) {
+		_, err = PendingMigrations(db)
+		assert.Error(t, err)
+		assert.False(t, db.HasTable(&schemaMigration{}))
+	}
+	states, err = MigrationStatus(db)
+	assert.NoError(t, err)
+	assert.Len(t, states, len(migrations))
+	_, err = MigrateDown(db)
+	assert.Error(t, err)
+	assert.False(t, db.HasTable(&schemaMigration{}))
+}
+
+func TestMigrationVersionsAscending(t *testing.T) {
+	for i := 1; i < len(migrations); i++ {
+		assert.True(t, migrations[i].Version > migrations[i-1].Version, "version %d out of order", migrations[i].Version)
+	}
+}
+
+func TestFileDBPersists(t *testing.T) {
+	db, path, td := mustOpenFileDB(t)
+	defer td()
+
+	if err := Setup(db, true); err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// reopen, setup must neither fail nor load sample data twice
+	db, err := Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if err := Setup(db, true); err != nil {
+		t.Fatal(err)
+	}
+
+	var n int
+	if err := db.Model(&User{}).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, len(sampleUsers), n)
+}
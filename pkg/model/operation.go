@@ -0,0 +1,51 @@
+package model
+
+import "time"
+
+// Actions performed by operations
+const (
+	OperationCreate  = "create"
+	OperationDelete  = "delete"
+	OperationResize  = "resize"
+	OperationStart   = "start"
+	OperationStop    = "stop"
+	OperationRestart = "restart"
+)
+
+// Status of an operation, succeeded and failed are final
+const (
+	OperationPending   OperationStatus = "pending"
+	OperationRunning   OperationStatus = "running"
+	OperationSucceeded OperationStatus = "succeeded"
+	OperationFailed    OperationStatus = "failed"
+)
+
+// OperationStatus is the status of an operation
+type OperationStatus string
+
+// Done is true if the operation has finished, successfully or not
+func (s OperationStatus) Done() bool {
+	return s == OperationSucceeded || s == OperationFailed
+}
+
+// Operation is an asynchronous action on a resource, performed by the provisioning worker.
+// Clients can poll it until it is done.
+type Operation struct {
+	ID         uint `gorm:"primary_key"`
+	ResourceID uint
+	Action     string
+	Status     OperationStatus
+	// Error is set if the operation failed
+	Error     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// NewOperation creates a pending operation for a resource
+func NewOperation(resourceID uint, action string) Operation {
+	return Operation{
+		ResourceID: resourceID,
+		Action:     action,
+		Status:     OperationPending,
+	}
+}
@@ -0,0 +1,41 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanInsertOperation(t *testing.T) {
+	db := MustInitTestDB(true)
+	defer db.Close()
+
+	op := NewOperation(1, OperationCreate)
+	if err := db.Create(&op).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var res Operation
+	if err := db.First(&res, op.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint(1), res.ResourceID)
+	assert.Equal(t, OperationCreate, res.Action)
+	assert.Equal(t, OperationPending, res.Status)
+	assert.False(t, res.CreatedAt.IsZero())
+}
+
+func TestOperationStatusDone(t *testing.T) {
+	tests := []struct {
+		status OperationStatus
+		done   bool
+	}{
+		{status: OperationPending, done: false},
+		{status: OperationRunning, done: false},
+		{status: OperationSucceeded, done: true},
+		{status: OperationFailed, done: true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.done, tt.status.Done(), string(tt.status))
+	}
+}
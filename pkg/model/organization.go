@@ -0,0 +1,83 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Roles of organization and project members. Each role includes the ones below it.
+const (
+	// MemberOwner can manage members, besides everything editors can do
+	MemberOwner MemberRole = "owner"
+	// MemberEditor can create and change resources
+	MemberEditor MemberRole = "editor"
+	// MemberViewer has read-only access
+	MemberViewer MemberRole = "viewer"
+)
+
+var memberRoleRanks = map[MemberRole]int{MemberViewer: 1, MemberEditor: 2, MemberOwner: 3}
+
+// MemberRole is the role of a member of an organization or project
+type MemberRole string
+
+// Valid checks if the role is one of the known roles
+func (r MemberRole) Valid() bool {
+	return memberRoleRanks[r] > 0
+}
+
+// Includes checks if r grants at least what o grants, e.g. owners include editors
+func (r MemberRole) Includes(o MemberRole) bool {
+	return o.Valid() && memberRoleRanks[r] >= memberRoleRanks[o]
+}
+
+// Roles including r, i.e. r and those above it
+func (r MemberRole) andAbove() []MemberRole {
+	var res []MemberRole
+	for mr := range memberRoleRanks {
+		if mr.Includes(r) {
+			res = append(res, mr)
+		}
+	}
+	return res
+}
+
+// Organization groups projects and the users working on them. Members of an organization hold their
+// role in all of its projects.
+type Organization struct {
+	ID          uint      `gorm:"primary_key" json:"id"`
+	Name        string    `gorm:"not null"    json:"name"        binding:"required"`
+	Description string    `                   json:"description"`
+	CreatedAt   time.Time `                   json:"createdAt"`
+}
+
+// OrganizationMember assigns a user a role in an organization
+type OrganizationMember struct {
+	OrganizationID uint       `gorm:"primary_key;auto_increment:false" json:"organizationId"`
+	UserID         uint       `gorm:"primary_key;auto_increment:false" json:"userId"`
+	Role           MemberRole `gorm:"not null"                         json:"role"`
+}
+
+// OrgsOf returns the IDs of organizations in which the user holds at least the given role
+func OrgsOf(db *gorm.DB, userID uint, min MemberRole) ([]uint, error) {
+	return pluckIDs(db, `SELECT "organization_id" FROM "organization_members" WHERE "user_id" = ? AND "role" IN (?)
+		ORDER BY "organization_id"`, userID, min.andAbove())
+}
+
+// Run a query selecting a single ID column
+func pluckIDs(db *gorm.DB, sql string, args ...interface{}) ([]uint, error) {
+	rows, err := db.Raw(sql, args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ids := []uint{}
+	for rows.Next() {
+		var id uint
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
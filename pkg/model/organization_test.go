@@ -0,0 +1,66 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemberRoleIncludes(t *testing.T) {
+	assert.True(t, MemberOwner.Includes(MemberEditor))
+	assert.True(t, MemberOwner.Includes(MemberViewer))
+	assert.True(t, MemberEditor.Includes(MemberEditor))
+	assert.False(t, MemberEditor.Includes(MemberOwner))
+	assert.False(t, MemberViewer.Includes(MemberEditor))
+	assert.False(t, MemberOwner.Includes("admin"))
+
+	assert.True(t, MemberViewer.Valid())
+	assert.False(t, MemberRole("admin").Valid())
+	assert.False(t, MemberRole("").Valid())
+}
+
+func TestPersonalOrganization(t *testing.T) {
+	db := MustInitTestDB(false)
+	defer db.Close()
+
+	u := User{Email: "john@acme.org", Password: "t0ps3cr3t", Roles: []Role{RoleUser}}
+	if err := db.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.NotZero(t, u.ProjectID)
+
+	var p Project
+	if err := db.First(&p, u.ProjectID).Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, DefaultProjectName, p.Name)
+	var o Organization
+	if err := db.First(&o, p.OrganizationID).Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "john@acme.org", o.Name)
+
+	orgs, err := OrgsOf(db, u.ID, MemberOwner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []uint{o.ID}, orgs)
+
+	// memberships and the personal organization are removed along with the user, other organizations are kept
+	team := Organization{Name: "Acme"}
+	if err := db.Create(&team).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&OrganizationMember{OrganizationID: team.ID, UserID: u.ID, Role: MemberOwner}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Delete(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+	orgs, err = OrgsOf(db, u.ID, MemberViewer)
+	assert.NoError(t, err)
+	assert.Empty(t, orgs)
+	assert.True(t, db.First(&p, p.ID).RecordNotFound())
+	assert.True(t, db.First(&o, o.ID).RecordNotFound())
+	assert.NoError(t, db.First(&team, team.ID).Error)
+}
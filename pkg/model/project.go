@@ -0,0 +1,88 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// DefaultProjectName is the name of the project every user gets in their personal organization
+const DefaultProjectName = "default"
+
+// Project owns resources and quotas. Project names are unique within their organization.
+type Project struct {
+	ID             uint      `gorm:"primary_key"                                  json:"id"`
+	OrganizationID uint      `gorm:"unique_index:uix_projects_organization_id_name" json:"organizationId"`
+	Name           string    `gorm:"unique_index:uix_projects_organization_id_name" json:"name"        binding:"required"`
+	Description    string    `                                                    json:"description"`
+	CreatedAt      time.Time `                                                    json:"createdAt"`
+}
+
+// ProjectMember assigns a user a role in a single project
+type ProjectMember struct {
+	ProjectID uint       `gorm:"primary_key;auto_increment:false" json:"projectId"`
+	UserID    uint       `gorm:"primary_key;auto_increment:false" json:"userId"`
+	Role      MemberRole `gorm:"not null"                         json:"role"`
+}
+
+// ProjectsOf returns the IDs of projects in which the user holds at least the given role, either as a
+// member of the project or of its organization
+func ProjectsOf(db *gorm.DB, userID uint, min MemberRole) ([]uint, error) {
+	roles := min.andAbove()
+	return pluckIDs(db, `SELECT "project_id" FROM "project_members" WHERE "user_id" = ? AND "role" IN (?)
+		UNION SELECT "projects"."id" FROM "projects" JOIN "organization_members"
+			ON "organization_members"."organization_id" = "projects"."organization_id"
+			WHERE "organization_members"."user_id" = ? AND "organization_members"."role" IN (?)
+		ORDER BY 1`, userID, roles, userID, roles)
+}
+
+// Create a personal organization for a user, owned by them, with a default project
+func createPersonalProject(tx *gorm.DB, u *User) error {
+	org := Organization{Name: u.Email, Description: "Personal organization of " + u.Email}
+	if err := tx.Create(&org).Error; err != nil {
+		return err
+	}
+	if err := tx.Create(&OrganizationMember{OrganizationID: org.ID, UserID: u.ID, Role: MemberOwner}).Error; err != nil {
+		return err
+	}
+	p := Project{OrganizationID: org.ID, Name: DefaultProjectName}
+	if err := tx.Create(&p).Error; err != nil {
+		return err
+	}
+	u.ProjectID = p.ID
+	return tx.Model(&User{}).Where("id = ?", u.ID).Update("project_id", p.ID).Error
+}
+
+// Delete a user's personal project with its quotas and members, and their personal organization unless
+// it has other projects by now
+func deletePersonalProject(tx *gorm.DB, u *User) error {
+	var ps []*Project
+	if err := tx.Find(&ps, "id = ?", u.ProjectID).Error; err != nil {
+		return err
+	}
+	if len(ps) == 0 {
+		return nil
+	}
+	p := ps[0]
+	if err := tx.Delete(&Quota{}, "project_id = ?", p.ID).Error; err != nil {
+		return err
+	}
+	if err := tx.Delete(&ProjectMember{}, "project_id = ?", p.ID).Error; err != nil {
+		return err
+	}
+	if err := tx.Delete(p).Error; err != nil {
+		return err
+	}
+
+	var n int
+	if err := tx.Model(&Project{}).Where("organization_id = ?", p.OrganizationID).Count(&n).Error; err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+	if err := tx.Delete(&OrganizationMember{}, "organization_id = ?", p.OrganizationID).Error; err != nil {
+		return err
+	}
+	return tx.Delete(&Organization{}, "id = ?", p.OrganizationID).Error
+}
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProjectNamesUniquePerOrganization(t *testing.T) {
+	db := MustInitTestDB(true)
+	defer db.Close()
+
+	assert.Error(t, db.Create(&Project{OrganizationID: 1, Name: DefaultProjectName}).Error)
+	assert.NoError(t, db.Create(&Project{OrganizationID: 1, Name: "staging"}).Error)
+}
+
+func TestProjectsOf(t *testing.T) {
+	db := MustInitTestDB(true)
+	defer db.Close()
+
+	// joe (1) owns personal project 1 and edits the canteen (3), admin (2) owns personal project 2
+	// and the canteen's organization
+	tests := []struct {
+		userID uint
+		min    MemberRole
+		ids    []uint
+	}{
+		{userID: 1, min: MemberViewer, ids: []uint{1, 3}},
+		{userID: 1, min: MemberEditor, ids: []uint{1, 3}},
+		{userID: 1, min: MemberOwner, ids: []uint{1}},
+		{userID: 2, min: MemberOwner, ids: []uint{2, 3}},
+		{userID: 3, min: MemberViewer, ids: []uint{}},
+	}
+
+	for _, tt := range tests {
+		ids, err := ProjectsOf(db, tt.userID, tt.min)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, tt.ids, ids, "user %d, %s", tt.userID, tt.min)
+	}
+}
+
+func TestMigrateProjects(t *testing.T) {
+	db, _, td := mustOpenFileDB(t)
+	defer td()
+
+	// schema before projects, resources and quotas belong to users
+	if err := db.AutoMigrate(&schemaMigration{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations {
+		if m.Version >= 9 {
+			break
+		}
+		if err := apply(db, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stmts := []string{
+		`INSERT INTO users (id, email, password) VALUES (7, 'joe@example.org', 'secret')`,
+		`INSERT INTO resources (name, user_id, type) VALUES ('pasta pot', 7, 'pot.instance.large')`,
+		`INSERT INTO quota (type, user_id, value) VALUES ('pot.instance.large', 7, 3)`,
+	}
+	for _, s := range stmts {
+		if err := db.Exec(s).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	var u User
+	if err := db.First(&u, 7).Error; err != nil {
+		t.Fatal(err)
+	}
+	var rc Resource
+	if err := db.First(&rc, "name = ?", "pasta pot").Error; err != nil {
+		t.Fatal(err)
+	}
+	var q Quota
+	if err := db.First(&q, "type = ?", "pot.instance.large").Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.NotZero(t, u.ProjectID)
+	assert.Equal(t, u.ProjectID, rc.ProjectID)
+	assert.Equal(t, u.ProjectID, q.ProjectID)
+
+	ids, err := ProjectsOf(db, u.ID, MemberOwner)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{u.ProjectID}, ids)
+}
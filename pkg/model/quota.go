@@ -1,19 +1,20 @@
 package model
 
-// Quota represents a quota of how many instances of a given resource a user can have
+// Quota represents a quota of how many instances of a given resource a project can have.
+// There can only be one quota per project and type.
 type Quota struct {
-	ID      uint   `gorm:"primary_key"`
-	Type    string `                             binding:"required"`
-	UserID  uint
-	Value   int     `                            binding:"required"`
-	Catalog Catalog `gorm:"foreignkey:Type"`
+	ID        uint    `gorm:"primary_key"`
+	Type      string  `gorm:"unique_index:uix_quota_project_id_type" binding:"required"`
+	ProjectID uint    `gorm:"unique_index:uix_quota_project_id_type"`
+	Value     int     `                                              binding:"required,min=0"`
+	Catalog   Catalog `gorm:"foreignkey:Type"`
 }
 
 // NewQuota creates and initialises a new quota object
-func NewQuota(userID uint, tp string, val int) Quota {
+func NewQuota(projectID uint, tp string, val int) Quota {
 	return Quota{
-		UserID: userID,
-		Type:   tp,
-		Value:  val,
+		ProjectID: projectID,
+		Type:      tp,
+		Value:     val,
 	}
 }
@@ -10,7 +10,7 @@ func TestCanInsertQuota(t *testing.T) {
 	db := MustInitTestDB(true)
 	defer db.Close()
 
-	q := NewQuota(1, "pot.instance.small", 10)
+	q := NewQuota(2, "pot.instance.small", 10)
 	if err := db.Create(&q).Error; err != nil {
 		t.Fatal(err)
 	}
@@ -21,26 +21,73 @@ func TestCanInsertQuota(t *testing.T) {
 	assert.NotZero(t, q.ID)
 	assert.Equal(t, q.Type, res.Type)
 	assert.Equal(t, q.Value, res.Value)
-	assert.NotEmpty(t, res.UserID)
+	assert.NotEmpty(t, res.ProjectID)
 	assert.NotEmpty(t, res.Catalog)
 }
 
-func TestQuotaBelongsToUser(t *testing.T) {
+func TestQuotaBelongsToProject(t *testing.T) {
 	db := MustInitTestDB(true)
 	defer db.Close()
 
 	var q Quota
-	var u User
+	var p Project
 
-	if err := db.First(&q).Related(&u).Error; err != nil {
+	if err := db.First(&q).Related(&p).Error; err != nil {
 		t.Fatal(err)
 	}
 
-	assert.NotEmpty(t, u)
-	assert.NotEmpty(t, u.ID)
-	assert.Equal(t, q.UserID, u.ID)
+	assert.NotEmpty(t, p.ID)
+	assert.Equal(t, q.ProjectID, p.ID)
+	assert.Equal(t, DefaultProjectName, p.Name)
 }
 
 func TestCannotInsertDuplicateQuota(t *testing.T) {
-	t.Skip("TODO")
+	db := MustInitTestDB(true)
+	defer db.Close()
+
+	// sample data has a quota for project 1 already
+	q := NewQuota(1, "pot.instance.small", 20)
+	err := db.Create(&q).Error
+	assert.Error(t, err)
+	assert.True(t, IsUniqueViolation(err), "%v", err)
+	assert.False(t, IsUniqueViolation(db.Exec("SELECT * FROM nowhere").Error))
+
+	// same type for other projects is fine
+	q = NewQuota(2, "pot.instance.small", 20)
+	assert.NoError(t, db.Create(&q).Error)
+}
+
+func TestMigrateDuplicateQuotas(t *testing.T) {
+	db, _, td := mustOpenFileDB(t)
+	defer td()
+
+	// schema before unique quotas, with duplicate quotas for a user
+	if err := db.AutoMigrate(&schemaMigration{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations {
+		if m.Version >= 5 {
+			break
+		}
+		if err := apply(db, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, v := range []int{10, 20, 30} {
+		if err := db.Exec(`INSERT INTO quota (type, user_id, value) VALUES ('pot.instance.small', 1, ?)`, v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	// quotas have since moved to the user's personal project, which has the user's id
+	var qs []Quota
+	if err := db.Find(&qs, "project_id = ?", 1).Error; err != nil {
+		t.Fatal(err)
+	}
+	if assert.Len(t, qs, 1) {
+		assert.Equal(t, 10, qs[0].Value)
+	}
 }
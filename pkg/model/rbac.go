@@ -0,0 +1,14 @@
+package model
+
+// RBACRule grants a role access to request paths and methods, see package rbac for details
+type RBACRule struct {
+	ID     uint   `gorm:"primary_key"  json:"id"`
+	Role   Role   `gorm:"not null;index" json:"role"`
+	Path   string `gorm:"not null"     json:"path"`
+	Action string `gorm:"not null"     json:"action"`
+}
+
+// TableName overrides the table name gorm would derive from the type name
+func (RBACRule) TableName() string {
+	return "rbac_rules"
+}
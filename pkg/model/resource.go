@@ -1,10 +1,118 @@
 package model
 
-// Resource is resourced owned by a user, based on a catalog item
+import (
+	"fmt"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Lifecycle status of a resource. Resources are created as pending and moved through the other
+// states by a provisioning worker. Running resources are provisioned again when resized or started
+// after being stopped.
+const (
+	ResourcePending      ResourceStatus = "pending"
+	ResourceProvisioning ResourceStatus = "provisioning"
+	ResourceRunning      ResourceStatus = "running"
+	ResourceStopping     ResourceStatus = "stopping"
+	ResourceStopped      ResourceStatus = "stopped"
+	ResourceDeleting     ResourceStatus = "deleting"
+	ResourceDeleted      ResourceStatus = "deleted"
+	ResourceFailed       ResourceStatus = "failed"
+)
+
+// ResourceStatus is the lifecycle status of a resource
+type ResourceStatus string
+
+// Valid transitions between resource statuses, deleted is final
+var resourceTransitions = map[ResourceStatus][]ResourceStatus{
+	ResourcePending:      {ResourceProvisioning, ResourceDeleting, ResourceFailed},
+	ResourceProvisioning: {ResourceRunning, ResourceFailed},
+	ResourceRunning:      {ResourceProvisioning, ResourceStopping, ResourceDeleting, ResourceFailed},
+	ResourceStopping:     {ResourceStopped, ResourceFailed},
+	ResourceStopped:      {ResourceProvisioning, ResourceDeleting, ResourceFailed},
+	ResourceDeleting:     {ResourceDeleted, ResourceFailed},
+	ResourceFailed:       {ResourceProvisioning, ResourceDeleting},
+}
+
+// CanTransition checks if a resource can move from status s to status to
+func (s ResourceStatus) CanTransition(to ResourceStatus) bool {
+	for _, t := range resourceTransitions[s] {
+		if t == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Resource is a resource owned by a project, based on a catalog item
 type Resource struct {
-	ID      uint   `gorm:"primary_key"`
-	Name    string `gorm:"not null"               binding:"required"`
+	ID        uint   `gorm:"primary_key"`
+	Name      string `gorm:"not null"               binding:"required"`
+	ProjectID uint
+	// UserID is the user who created the resource, if known
 	UserID  uint
 	Type    string  `                             binding:"required"`
 	Catalog Catalog `gorm:"foreignkey:Type"`
+	Status  ResourceStatus
+	// StatusMessage explains the status, e.g. why provisioning failed
+	StatusMessage string
+}
+
+// BeforeCreate makes new resources pending unless a status is given
+func (r *Resource) BeforeCreate() error {
+	if r.Status == "" {
+		r.Status = ResourcePending
+	}
+	return nil
+}
+
+// SetStatus moves the resource to a new status. Fails with an InvalidTransitionError if the
+// transition is not allowed, or the resource's status was changed concurrently.
+func (r *Resource) SetStatus(db *gorm.DB, to ResourceStatus, msg string) error {
+	if !r.Status.CanTransition(to) {
+		return &InvalidTransitionError{From: r.Status, To: to}
+	}
+	res := db.Model(&Resource{}).
+		Where("id = ? and status = ?", r.ID, r.Status).
+		Updates(map[string]interface{}{"status": to, "status_message": msg})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return &InvalidTransitionError{From: r.Status, To: to}
+	}
+	r.Status, r.StatusMessage = to, msg
+	return nil
+}
+
+// InvalidTransitionError is returned if a resource can't be moved to a status
+type InvalidTransitionError struct {
+	From ResourceStatus
+	To   ResourceStatus
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("Resource can't change from %s to %s", e.From, e.To)
+}
+
+// CountResourcesByType returns the number of resources of each catalog type, including types without
+// resources. Deleted resources are not counted.
+func CountResourcesByType(db *gorm.DB) (map[string]int, error) {
+	rows, err := db.Raw(`SELECT "catalogs"."name", COUNT("resources"."id") FROM "catalogs"
+		LEFT JOIN "resources" ON "resources"."type" = "catalogs"."name" AND "resources"."status" <> ?
+		GROUP BY "catalogs"."name"`, ResourceDeleted).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := map[string]int{}
+	for rows.Next() {
+		var tp string
+		var n int
+		if err := rows.Scan(&tp, &n); err != nil {
+			return nil, err
+		}
+		res[tp] = n
+	}
+	return res, rows.Err()
 }
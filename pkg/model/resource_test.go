@@ -62,3 +62,123 @@ func TestResourceBelongsToCatalog(t *testing.T) {
 	assert.NotEmpty(t, rc.Catalog)
 	assert.Equal(t, rc.Catalog.Name, rc.Type)
 }
+
+func TestNewResourceIsPending(t *testing.T) {
+	db := MustInitTestDB(true)
+	defer db.Close()
+
+	rc := Resource{Name: "my database server", UserID: 1, Type: "pot.instance.small"}
+	if err := db.Create(&rc).Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ResourcePending, rc.Status)
+}
+
+func TestResourceStatusTransitions(t *testing.T) {
+	tests := []struct {
+		from ResourceStatus
+		to   ResourceStatus
+		ok   bool
+	}{
+		{from: ResourcePending, to: ResourceProvisioning, ok: true},
+		{from: ResourceProvisioning, to: ResourceRunning, ok: true},
+		{from: ResourceRunning, to: ResourceStopping, ok: true},
+		{from: ResourceStopping, to: ResourceStopped, ok: true},
+		{from: ResourceStopped, to: ResourceProvisioning, ok: true},
+		{from: ResourceRunning, to: ResourceDeleting, ok: true},
+		{from: ResourceDeleting, to: ResourceDeleted, ok: true},
+		{from: ResourceFailed, to: ResourceDeleting, ok: true},
+		{from: ResourcePending, to: ResourceRunning, ok: false},
+		{from: ResourceProvisioning, to: ResourceDeleting, ok: false},
+		{from: ResourceStopped, to: ResourceRunning, ok: false},
+		{from: ResourceRunning, to: ResourceProvisioning, ok: true},
+		{from: ResourceDeleted, to: ResourceProvisioning, ok: false},
+		{from: ResourceDeleted, to: ResourceDeleting, ok: false},
+		{from: ResourceRunning, to: "exploded", ok: false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.ok, tt.from.CanTransition(tt.to), "%s -> %s", tt.from, tt.to)
+	}
+}
+
+func TestSetResourceStatus(t *testing.T) {
+	db := MustInitTestDB(true)
+	defer db.Close()
+
+	var rc Resource
+	if err := db.First(&rc).Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ResourceRunning, rc.Status)
+
+	// invalid transition
+	err := rc.SetStatus(db, ResourceStopped, "")
+	assert.IsType(t, &InvalidTransitionError{}, err)
+
+	// stale status, another request moved it on already
+	stale := rc
+	if err := rc.SetStatus(db, ResourceDeleting, ""); err != nil {
+		t.Fatal(err)
+	}
+	err = stale.SetStatus(db, ResourceStopping, "")
+	assert.IsType(t, &InvalidTransitionError{}, err)
+
+	if err := rc.SetStatus(db, ResourceFailed, "out of pots"); err != nil {
+		t.Fatal(err)
+	}
+	var res Resource
+	if err := db.First(&res, rc.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ResourceFailed, res.Status)
+	assert.Equal(t, "out of pots", res.StatusMessage)
+}
+
+func TestMigrateResourceStatus(t *testing.T) {
+	db, _, td := mustOpenFileDB(t)
+	defer td()
+
+	// schema before resource status
+	if err := db.AutoMigrate(&schemaMigration{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations {
+		if m.Version >= 7 {
+			break
+		}
+		if err := apply(db, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Exec(`INSERT INTO resources (name, user_id, type) VALUES ('pasta pot', 1, 'pot.instance.large')`).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	var rc Resource
+	if err := db.First(&rc, "name = ?", "pasta pot").Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, ResourceRunning, rc.Status)
+}
+
+func TestCountResourcesByType(t *testing.T) {
+	db := MustInitTestDB(true)
+	defer db.Close()
+
+	if err := db.Model(&Resource{}).Where("id = ?", 3).Update("status", ResourceDeleted).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := CountResourcesByType(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, res, 7) // one per catalog item
+	assert.Equal(t, 1, res["pot.instance.large"])
+	assert.Equal(t, 1, res["pan.instance.s"])
+	assert.Equal(t, 0, res["pan.instance.wok"])
+	assert.Equal(t, 0, res["pot.instance.small"])
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Built-in roles, these are created by migrations and used as defaults
+const (
+	RoleAdmin   Role = "admin"
+	RoleUser    Role = "user"
+	RoleAuditor Role = "auditor"
+)
+
+var roleNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// Role is the name of a role, users can hold any number of roles
+type Role string
+
+// Valid checks if the role name is well-formed, it does not check whether the role exists
+func (r Role) Valid() bool {
+	return roleNameRegexp.MatchString(string(r))
+}
+
+// RoleSpec is a named role along with the RBAC rules granted to it
+type RoleSpec struct {
+	Name        Role       `gorm:"primary_key"                                json:"name"         binding:"required"`
+	Description string     `                                                  json:"description"`
+	Rules       []RBACRule `gorm:"foreignkey:Role;association_foreignkey:Name" json:"rules"`
+}
+
+// TableName overrides the table name gorm would derive from the type name
+func (RoleSpec) TableName() string {
+	return "roles"
+}
+
+// UserRole assigns a role to a user
+type UserRole struct {
+	UserID uint `gorm:"primary_key;auto_increment:false"`
+	Role   Role `gorm:"primary_key"`
+}
+
+// CheckRolesExist returns an error naming the first of the given roles not found in the DB
+func CheckRolesExist(db *gorm.DB, roles []Role) error {
+	for _, r := range roles {
+		var n int
+		if err := db.Model(&RoleSpec{}).Where("name = ?", r).Count(&n).Error; err != nil {
+			return err
+		}
+		if n == 0 {
+			return &UnknownRoleError{r}
+		}
+	}
+	return nil
+}
+
+// UnknownRoleError is returned if a role does not exist
+type UnknownRoleError struct {
+	Role Role
+}
+
+func (e *UnknownRoleError) Error() string {
+	return fmt.Sprintf("Unknown role: %s", e.Role)
+}
+
+// CountUsersByRole returns the number of users holding each role, including roles nobody holds
+func CountUsersByRole(db *gorm.DB) (map[Role]int, error) {
+	rows, err := db.Raw(`SELECT "roles"."name", COUNT("user_roles"."user_id") FROM "roles"
+		LEFT JOIN "user_roles" ON "user_roles"."role" = "roles"."name" GROUP BY "roles"."name"`).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	res := map[Role]int{}
+	for rows.Next() {
+		var r Role
+		var n int
+		if err := rows.Scan(&r, &n); err != nil {
+			return nil, err
+		}
+		res[r] = n
+	}
+	return res, rows.Err()
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserRoles(t *testing.T) {
+	db := MustInitTestDB(false)
+	defer db.Close()
+
+	u := User{Email: "john@acme.org", Password: "t0ps3cr3t", Roles: []Role{RoleUser, RoleAuditor}}
+	if err := db.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var res User
+	if err := db.First(&res, u.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []Role{RoleAuditor, RoleUser}, res.Roles)
+	assert.True(t, res.HasRole(RoleAuditor))
+	assert.False(t, res.HasRole(RoleAdmin))
+
+	if err := res.SetRoles(db, []Role{RoleAdmin}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.First(&res, u.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []Role{RoleAdmin}, res.Roles)
+
+	// roles are removed along with the user
+	if err := db.Delete(&res).Error; err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	db.Model(&UserRole{}).Where("user_id = ?", u.ID).Count(&n)
+	assert.Zero(t, n)
+}
+
+func TestCheckRolesExist(t *testing.T) {
+	db := MustInitTestDB(false)
+	defer db.Close()
+
+	assert.NoError(t, CheckRolesExist(db, []Role{RoleAdmin, RoleUser, RoleAuditor}))
+
+	err := CheckRolesExist(db, []Role{RoleUser, "superuser"})
+	if assert.IsType(t, &UnknownRoleError{}, err) {
+		assert.Equal(t, Role("superuser"), err.(*UnknownRoleError).Role)
+	}
+}
+
+func TestRoleNames(t *testing.T) {
+	for _, r := range []Role{"admin", "billing", "support-2", "read_only"} {
+		assert.True(t, r.Valid(), string(r))
+	}
+	for _, r := range []Role{"", "Admin", "2nd", "foo bar"} {
+		assert.False(t, r.Valid(), string(r))
+	}
+}
+
+func TestMigrateSingleRoleToRoles(t *testing.T) {
+	db, _, td := mustOpenFileDB(t)
+	defer td()
+
+	// schema before custom roles, with a user holding a single role
+	if err := db.AutoMigrate(&schemaMigration{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations {
+		if m.Version >= 4 {
+			break
+		}
+		if err := apply(db, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Exec(`INSERT INTO users (email, password, role) VALUES ('joe@example.org', 'secret', 'admin')`).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := MigrateUp(db); err != nil {
+		t.Fatal(err)
+	}
+	var u User
+	if err := db.First(&u, "email = ?", "joe@example.org").Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []Role{RoleAdmin}, u.Roles)
+
+	// and back again
+	for {
+		s, err := MigrateDown(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.Version == 4 {
+			break
+		}
+	}
+	var role string
+	if err := db.Raw(`SELECT role FROM users WHERE email = ?`, "joe@example.org").Row().Scan(&role); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "admin", role)
+}
+
+func TestCountUsersByRole(t *testing.T) {
+	db := MustInitTestDB(true)
+	defer db.Close()
+
+	res, err := CountUsersByRole(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, map[Role]int{RoleAdmin: 1, RoleUser: 1, RoleAuditor: 0}, res)
+}
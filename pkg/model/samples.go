@@ -2,38 +2,118 @@ package model
 
 import "github.com/jinzhu/gorm"
 
+// Plaintext passwords are hashed on first load, the hash is kept to speed up subsequent loads
 var sampleUsers = []*User{
-	{Email: "joe@example.org", Password: "secret", Role: RoleUser},
-	{Email: "admin@example.org", Password: "secret", Role: RoleAdmin},
+	{Email: "joe@example.org", Password: "secret", Roles: []Role{RoleUser}},
+	{Email: "admin@example.org", Password: "secret", Roles: []Role{RoleAdmin}},
 }
 
 var sampleCatalog = []*Catalog{
-	{Name: "pot.instance.small"},  // 0
-	{Name: "pot.instance.large"},  // 1
-	{Name: "pot.instance.xlarge"}, // 2
-	{Name: "pan.instance.wok"},    // 3
-	{Name: "pan.instance.s"},      // 4
-	{Name: "pan.instance.m"},      // 5
-	{Name: "pan.instance.xl"},     // 6
+	{ // 0
+		Name:            "pot.instance.small",
+		DisplayName:     "Small pot",
+		Description:     "For sauces and a portion of soup",
+		Specs:           Specs{Capacity: 1.5, Size: 16},
+		HourlyPrice:     2,
+		Currency:        "USD",
+		FreeWhenStopped: true,
+	},
+	{ // 1
+		Name:            "pot.instance.large",
+		DisplayName:     "Large pot",
+		Description:     "For pasta, potatoes and stews",
+		Specs:           Specs{Capacity: 5, Size: 24},
+		HourlyPrice:     5,
+		Currency:        "USD",
+		FreeWhenStopped: true,
+	},
+	{ // 2
+		Name:            "pot.instance.xlarge",
+		DisplayName:     "Stock pot",
+		Description:     "For stocks and cooking for a crowd",
+		Specs:           Specs{Capacity: 12, Size: 32},
+		HourlyPrice:     9,
+		Currency:        "USD",
+		FreeWhenStopped: true,
+	},
+	{ // 3
+		Name:        "pan.instance.wok",
+		DisplayName: "Wok",
+		Description: "For stir fries at high heat",
+		Specs:       Specs{Capacity: 4, Size: 36},
+		HourlyPrice: 4,
+		Currency:    "USD",
+	},
+	{ // 4
+		Name:        "pan.instance.s",
+		DisplayName: "Small frying pan",
+		Description: "For eggs and pancakes",
+		Specs:       Specs{Capacity: 0.5, Size: 20},
+		HourlyPrice: 2,
+		Currency:    "USD",
+	},
+	{ // 5
+		Name:        "pan.instance.m",
+		DisplayName: "Medium frying pan",
+		Description: "For steaks and vegetables",
+		Specs:       Specs{Capacity: 1, Size: 26},
+		HourlyPrice: 3,
+		Currency:    "USD",
+	},
+	{ // 6
+		Name:        "pan.instance.xl",
+		DisplayName: "Large frying pan",
+		Description: "For paella and family breakfasts",
+		Specs:       Specs{Capacity: 2.5, Size: 32},
+		HourlyPrice: 5,
+		Currency:    "USD",
+	},
 }
 
-// UserID and CatalogID refer to idx in sample slices, will be replaced before insert
+// UserID and CatalogID refer to idx in sample slices, will be replaced before insert.
+// Resources are owned by the user's personal project.
 var sampleResources = []Resource{
-	{Name: "pasta pot", UserID: 0, Type: "pot.instance.large"},
-	{Name: "rice pot", UserID: 0, Type: "pot.instance.xlarge"},
-	{Name: "stir fry pan", UserID: 1, Type: "pan.instance.wok"},
-	{Name: "skillet for eggs", UserID: 1, Type: "pan.instance.s"},
+	{Name: "pasta pot", UserID: 0, Type: "pot.instance.large", Status: ResourceRunning},
+	{Name: "rice pot", UserID: 0, Type: "pot.instance.xlarge", Status: ResourceRunning},
+	{Name: "stir fry pan", UserID: 1, Type: "pan.instance.wok", Status: ResourceRunning},
+	{Name: "skillet for eggs", UserID: 1, Type: "pan.instance.s", Status: ResourceRunning},
 }
 
+// ProjectID refers to the idx of the user owning the personal project, will be replaced before insert
 var sampleQuotas = []Quota{
-	{Type: "pot.instance.small", UserID: 0, Value: 10},
+	{Type: "pot.instance.small", ProjectID: 0, Value: 10},
 }
 
+// A team organization, in addition to the personal ones every user gets
+var sampleOrganizations = []Organization{
+	{Name: "Yodo Kitchen", Description: "The team running the canteen"},
+}
+
+// OrganizationID refers to idx in sampleOrganizations, will be replaced before insert
+var sampleProjects = []Project{
+	{OrganizationID: 0, Name: "canteen", Description: "Pots and pans for lunch service"},
+}
+
+// IDs refer to idx in sample slices, will be replaced before insert
+var (
+	sampleOrganizationMembers = []OrganizationMember{
+		{OrganizationID: 0, UserID: 1, Role: MemberOwner},
+	}
+	sampleProjectMembers = []ProjectMember{
+		{ProjectID: 0, UserID: 0, Role: MemberEditor},
+	}
+)
+
 func loadSampleData(db *gorm.DB) error {
 	// Need to repeat this 3 times because we don't have a generic list type :(
 	// Using []interface{} won't work: https://github.com/golang/go/wiki/InterfaceSlice
 
 	for _, u := range sampleUsers {
+		if !isHashed(u.Password) {
+			if err := u.SetPassword(u.Password); err != nil {
+				return err
+			}
+		}
 		if err := db.Create(u).Error; err != nil {
 			return err
 		}
@@ -45,7 +125,7 @@ func loadSampleData(db *gorm.DB) error {
 	}
 	for _, rc := range sampleResources {
 		// replace references with generated database IDs
-		rc.UserID = sampleUsers[rc.UserID].ID
+		rc.UserID, rc.ProjectID = sampleUsers[rc.UserID].ID, sampleUsers[rc.UserID].ProjectID
 		// insert
 		if err := db.Create(&rc).Error; err != nil {
 			return err
@@ -53,11 +133,39 @@ func loadSampleData(db *gorm.DB) error {
 	}
 	for _, q := range sampleQuotas {
 		// replace references with generated database IDs
-		q.UserID = sampleUsers[q.UserID].ID
+		q.ProjectID = sampleUsers[q.ProjectID].ProjectID
 		// insert
 		if err := db.Create(&q).Error; err != nil {
 			return err
 		}
 	}
+	// samples are copied on insert, so keep track of generated IDs
+	orgIDs := make([]uint, len(sampleOrganizations))
+	for i, o := range sampleOrganizations {
+		if err := db.Create(&o).Error; err != nil {
+			return err
+		}
+		orgIDs[i] = o.ID
+	}
+	projectIDs := make([]uint, len(sampleProjects))
+	for i, p := range sampleProjects {
+		p.OrganizationID = orgIDs[p.OrganizationID]
+		if err := db.Create(&p).Error; err != nil {
+			return err
+		}
+		projectIDs[i] = p.ID
+	}
+	for _, m := range sampleOrganizationMembers {
+		m.OrganizationID, m.UserID = orgIDs[m.OrganizationID], sampleUsers[m.UserID].ID
+		if err := db.Create(&m).Error; err != nil {
+			return err
+		}
+	}
+	for _, m := range sampleProjectMembers {
+		m.ProjectID, m.UserID = projectIDs[m.ProjectID], sampleUsers[m.UserID].ID
+		if err := db.Create(&m).Error; err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,71 @@
+package model
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// RefreshToken is a long-lived, single use token to obtain a new access token. Only a hash of
+// the token is stored. All tokens issued from the same login share a Family, so the whole chain
+// can be revoked at once, e.g. if a used token is presented again.
+type RefreshToken struct {
+	ID        uint      `gorm:"primary_key"`
+	Hash      string    `gorm:"not null;unique_index"`
+	Family    string    `gorm:"not null;index"`
+	UserID    uint      `gorm:"not null;index"`
+	AccessJTI string    `gorm:"not null"` // ID of the access token issued along with this token
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+}
+
+// RevokedToken is an access token which was revoked before it expired, identified by its JWT ID
+type RevokedToken struct {
+	JTI       string    `gorm:"primary_key"`
+	ExpiresAt time.Time `gorm:"not null"`
+}
+
+// RevokeUserTokens revokes all refresh and access tokens issued to a user
+func RevokeUserTokens(db *gorm.DB, userID uint) error {
+	return revokeTokens(db, "user_id = ?", userID)
+}
+
+// RevokeTokenFamily revokes all refresh and access tokens issued from the same login
+func RevokeTokenFamily(db *gorm.DB, family string) error {
+	return revokeTokens(db, "family = ?", family)
+}
+
+// IsTokenRevoked checks if the access token with given JWT ID has been revoked
+func IsTokenRevoked(db *gorm.DB, jti string) (bool, error) {
+	var n int
+	if err := db.Model(&RevokedToken{}).Where("jti = ?", jti).Count(&n).Error; err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// Revoke all refresh tokens matching the given condition, along with their access tokens. Expired
+// tokens of all users are pruned on the way, as they are rejected anyway.
+func revokeTokens(db *gorm.DB, where string, args ...interface{}) error {
+	return Transaction(db, func(tx *gorm.DB) error {
+		var rts []*RefreshToken
+		if err := tx.Where(where, args...).Where("revoked_at is null").Find(&rts).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, rt := range rts {
+			// refresh tokens outlive their access tokens, so this is a safe upper bound
+			if err := tx.Create(&RevokedToken{JTI: rt.AccessJTI, ExpiresAt: rt.ExpiresAt}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(rt).Update("revoked_at", now).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("expires_at < ?", now).Delete(&RevokedToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("expires_at < ?", now).Delete(&RefreshToken{}).Error
+	})
+}
@@ -0,0 +1,47 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRevokeTokensPrunesExpired(t *testing.T) {
+	db := MustInitTestDB(false)
+	defer db.Close()
+
+	now := time.Now()
+	for _, rt := range []*RefreshToken{
+		{Hash: "h1", Family: "f1", UserID: 1, AccessJTI: "j1", ExpiresAt: now.Add(time.Hour)},
+		{Hash: "h2", Family: "f2", UserID: 1, AccessJTI: "j2", ExpiresAt: now.Add(time.Hour)},
+		{Hash: "h3", Family: "f3", UserID: 2, AccessJTI: "j3", ExpiresAt: now.Add(-time.Hour)},
+	} {
+		if err := db.Create(rt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Create(&RevokedToken{JTI: "j4", ExpiresAt: now.Add(-time.Hour)}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := RevokeTokenFamily(db, "f1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var hashes []string
+	if err := db.Model(&RefreshToken{}).Order("hash").Pluck("hash", &hashes).Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"h1", "h2"}, hashes)
+
+	var jtis []string
+	if err := db.Model(&RevokedToken{}).Order("jti").Pluck("jti", &jtis).Error; err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{"j1"}, jtis)
+
+	revoked, err := IsTokenRevoked(db, "j1")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
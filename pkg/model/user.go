@@ -1,46 +1,133 @@
 package model
 
 import (
+	"crypto/subtle"
 	"fmt"
-	"reflect"
 
-	"github.com/gin-gonic/gin/binding"
-	validator "gopkg.in/go-playground/validator.v8"
+	"github.com/jinzhu/gorm"
+	"golang.org/x/crypto/bcrypt"
 )
 
-func init() {
-	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
-		v.RegisterValidation("userrole", validateRole)
-	}
-}
-
-// Role constants
-const (
-	RoleAdmin Role = "admin"
-	RoleUser  Role = "user"
-)
-
-// Role for implementing a simple RBAC model
-type Role string
+// PasswordCost is the bcrypt cost used when hashing new passwords
+var PasswordCost = bcrypt.DefaultCost
 
-// User is a user in the system
-// FIXME: password should be hashed
+// User is a user in the system. Password holds a bcrypt hash, use SetPassword to change it.
+// Rows created before passwords were hashed may still hold plaintext, see PasswordNeedsRehash.
+// Every user owns a personal organization with a default project, ProjectID refers to that project.
 type User struct {
 	ID        uint       `gorm:"primary_key"            json:"id"`
 	Email     string     `gorm:"not null;unique_index"  json:"email"               binding:"required,email"`
 	Password  string     `gorm:"not null"               json:"password,omitempty"  binding:"required"`
-	Role      Role       `gorm:"not null"               json:"role,omitempty"      binding:"omitempty,userrole"`
+	Roles     []Role     `gorm:"-"                      json:"roles,omitempty"`
+	ProjectID uint       `                              json:"projectId"`
 	Resources []Resource `json:",omitempty"`
 }
 
 func (u User) String() string {
-	return fmt.Sprintf(`User{Email:"%s", Role:"%s"}`, u.Email, u.Role)
+	return fmt.Sprintf(`User{Email:"%s", Roles:%v}`, u.Email, u.Roles)
+}
+
+// HasRole checks if the user holds the given role
+func (u *User) HasRole(r Role) bool {
+	for _, ur := range u.Roles {
+		if ur == r {
+			return true
+		}
+	}
+	return false
+}
+
+// SetRoles replaces the user's roles, both on the user and in the user_roles table
+func (u *User) SetRoles(db *gorm.DB, roles []Role) error {
+	return Transaction(db, func(tx *gorm.DB) error {
+		if err := tx.Delete(&UserRole{}, "user_id = ?", u.ID).Error; err != nil {
+			return err
+		}
+		u.Roles = roles
+		return u.saveRoles(tx)
+	})
 }
 
-func validateRole(v *validator.Validate, ts reflect.Value, cs reflect.Value, f reflect.Value, ft reflect.Type, fk reflect.Kind, param string) bool {
-	if val, ok := f.Interface().(Role); ok {
-		// somewhat dirty, but OK for only 2 roles
-		return val == RoleAdmin || val == RoleUser
+// AfterCreate hook, stores the user's roles along with the user and creates their personal project
+func (u *User) AfterCreate(tx *gorm.DB) error {
+	if err := u.saveRoles(tx); err != nil {
+		return err
+	}
+	return createPersonalProject(tx, u)
+}
+
+// AfterFind hook, loads the user's roles
+func (u *User) AfterFind(tx *gorm.DB) error {
+	var urs []*UserRole
+	if err := tx.Order("role").Find(&urs, "user_id = ?", u.ID).Error; err != nil {
+		return err
+	}
+	u.Roles = make([]Role, len(urs))
+	for i, ur := range urs {
+		u.Roles[i] = ur.Role
+	}
+	return nil
+}
+
+// AfterDelete hook, removes the user's roles, memberships and personal project. Projects of other
+// organizations are kept, their owners can still manage them. Resources of the personal project must
+// have been deleted before, nobody could reach them afterwards.
+func (u *User) AfterDelete(tx *gorm.DB) error {
+	if err := tx.Delete(&UserRole{}, "user_id = ?", u.ID).Error; err != nil {
+		return err
+	}
+	if err := tx.Delete(&OrganizationMember{}, "user_id = ?", u.ID).Error; err != nil {
+		return err
 	}
-	return true
+	if err := tx.Delete(&ProjectMember{}, "user_id = ?", u.ID).Error; err != nil {
+		return err
+	}
+	return deletePersonalProject(tx, u)
+}
+
+func (u *User) saveRoles(tx *gorm.DB) error {
+	for _, r := range u.Roles {
+		if err := tx.Create(&UserRole{UserID: u.ID, Role: r}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HashPassword returns the bcrypt hash for the given plaintext password
+func HashPassword(pw string) (string, error) {
+	h, err := bcrypt.GenerateFromPassword([]byte(pw), PasswordCost)
+	if err != nil {
+		return "", err
+	}
+	return string(h), nil
+}
+
+// SetPassword hashes the given plaintext password and stores the hash on the user
+func (u *User) SetPassword(pw string) error {
+	h, err := HashPassword(pw)
+	if err != nil {
+		return err
+	}
+	u.Password = h
+	return nil
+}
+
+// CheckPassword reports whether pw matches the user's password. Legacy plaintext
+// passwords are compared as-is.
+func (u *User) CheckPassword(pw string) bool {
+	if u.PasswordNeedsRehash() {
+		return subtle.ConstantTimeCompare([]byte(u.Password), []byte(pw)) == 1
+	}
+	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(pw)) == nil
+}
+
+// PasswordNeedsRehash is true if the stored password is not a bcrypt hash (i.e. plaintext)
+func (u *User) PasswordNeedsRehash() bool {
+	return !isHashed(u.Password)
+}
+
+func isHashed(pw string) bool {
+	_, err := bcrypt.Cost([]byte(pw))
+	return err == nil
 }
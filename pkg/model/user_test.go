@@ -10,7 +10,7 @@ func TestCreateUser(t *testing.T) {
 	db := MustInitTestDB(false)
 	defer db.Close()
 
-	user := User{Email: "john@acme.org", Password: "t0ps3cr3t", Role: RoleUser}
+	user := User{Email: "john@acme.org", Password: "t0ps3cr3t", Roles: []Role{RoleUser}}
 	if err := db.Create(&user).Error; err != nil {
 		t.Errorf("Expected to create user, but failed with %v", err)
 	}
@@ -30,8 +30,8 @@ func TestEmailMustBeUnique(t *testing.T) {
 	db := MustInitTestDB(false)
 	defer db.Close()
 
-	user1 := User{Email: "john@acme.org", Password: "t0ps3cr3t", Role: RoleUser}
-	user2 := User{Email: "john@acme.org", Password: "gu3st", Role: RoleAdmin}
+	user1 := User{Email: "john@acme.org", Password: "t0ps3cr3t", Roles: []Role{RoleUser}}
+	user2 := User{Email: "john@acme.org", Password: "gu3st", Roles: []Role{RoleAdmin}}
 
 	if err := db.Create(&user1).Error; err != nil {
 		t.Fatal(err)
@@ -67,3 +67,38 @@ func TestUserHasResources(t *testing.T) {
 	assert.NotEmpty(t, rcs)
 	assert.Len(t, rcs, 2)
 }
+
+func TestUserPassword(t *testing.T) {
+	var u User
+	if err := u.SetPassword("t0ps3cr3t"); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEqual(t, "t0ps3cr3t", u.Password)
+	assert.False(t, u.PasswordNeedsRehash())
+	assert.True(t, u.CheckPassword("t0ps3cr3t"))
+	assert.False(t, u.CheckPassword("gu3st"))
+}
+
+func TestLegacyPlaintextPassword(t *testing.T) {
+	u := User{Password: "t0ps3cr3t"}
+
+	assert.True(t, u.PasswordNeedsRehash())
+	assert.True(t, u.CheckPassword("t0ps3cr3t"))
+	assert.False(t, u.CheckPassword("gu3st"))
+}
+
+func TestSampleUsersAreHashed(t *testing.T) {
+	db := MustInitTestDB(true)
+	defer db.Close()
+
+	var result []*User
+	if err := db.Find(&result).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, u := range result {
+		assert.False(t, u.PasswordNeedsRehash())
+		assert.True(t, u.CheckPassword("secret"))
+	}
+}
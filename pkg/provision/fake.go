@@ -0,0 +1,179 @@
+package provision
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+// Nop returns a provider that succeeds immediately without doing anything
+func Nop() Provider {
+	return nop{}
+}
+
+type nop struct{}
+
+func (nop) Create(r *model.Resource) error { return nil }
+func (nop) Delete(r *model.Resource) error { return nil }
+func (nop) Resize(r *model.Resource) error { return nil }
+func (nop) Start(r *model.Resource) error  { return nil }
+func (nop) Stop(r *model.Resource) error   { return nil }
+
+func (nop) Describe(r *model.Resource) (*Description, error) {
+	return &Description{Type: r.Type, Running: true}, nil
+}
+
+// Fake is an in-process provider keeping resources in memory, with configurable latency and
+// failures. It is safe for concurrent use, but fields must not be changed while it is in use.
+type Fake struct {
+	// Latency is added to every call
+	Latency time.Duration
+	// FailureRate is the probability of a call failing, between 0 and 1
+	FailureRate float64
+	// FailWith, if set, is called before every call with the name of the method. If it returns an
+	// error, the call fails with that error. It is used instead of FailureRate.
+	FailWith func(method string, r *model.Resource) error
+
+	mu        sync.Mutex
+	rand      *rand.Rand
+	resources map[uint]*Description
+}
+
+// NewFake creates a fake provider without latency or failures
+func NewFake() *Fake {
+	return &Fake{
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+		resources: map[uint]*Description{},
+	}
+}
+
+// Create implements Provider, creating a running resource. Creating it again is fine.
+func (f *Fake) Create(r *model.Resource) error {
+	return f.call("Create", r, func() error {
+		if _, ok := f.resources[r.ID]; !ok {
+			f.resources[r.ID] = &Description{
+				Type:    r.Type,
+				Running: true,
+				Details: map[string]string{"id": "fake-" + strconv.Itoa(int(r.ID))},
+			}
+		}
+		return nil
+	})
+}
+
+// Load adds the resources of db which exist at the provider, i.e. all but pending and deleted ones,
+// so a fake started on an existing database knows them. Resources known already are kept.
+func (f *Fake) Load(db *gorm.DB) error {
+	var rs []*model.Resource
+	err := db.Find(&rs, "status not in (?)", []model.ResourceStatus{model.ResourcePending, model.ResourceDeleted}).Error
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, r := range rs {
+		if _, ok := f.resources[r.ID]; !ok {
+			f.resources[r.ID] = &Description{
+				Type:    r.Type,
+				Running: r.Status != model.ResourceStopped,
+				Details: map[string]string{"id": "fake-" + strconv.Itoa(int(r.ID))},
+			}
+		}
+	}
+	return nil
+}
+
+// Delete implements Provider
+func (f *Fake) Delete(r *model.Resource) error {
+	return f.call("Delete", r, func() error {
+		delete(f.resources, r.ID)
+		return nil
+	})
+}
+
+// Resize implements Provider
+func (f *Fake) Resize(r *model.Resource) error {
+	return f.call("Resize", r, func() error {
+		d, err := f.find(r)
+		if err != nil {
+			return err
+		}
+		d.Type = r.Type
+		return nil
+	})
+}
+
+// Start implements Provider
+func (f *Fake) Start(r *model.Resource) error {
+	return f.call("Start", r, func() error {
+		d, err := f.find(r)
+		if err != nil {
+			return err
+		}
+		d.Running = true
+		return nil
+	})
+}
+
+// Stop implements Provider
+func (f *Fake) Stop(r *model.Resource) error {
+	return f.call("Stop", r, func() error {
+		d, err := f.find(r)
+		if err != nil {
+			return err
+		}
+		d.Running = false
+		return nil
+	})
+}
+
+// Describe implements Provider, it returns a copy of the fake's state
+func (f *Fake) Describe(r *model.Resource) (*Description, error) {
+	var res Description
+	err := f.call("Describe", r, func() error {
+		d, err := f.find(r)
+		if err != nil {
+			return err
+		}
+		res = *d
+		res.Details = map[string]string{}
+		for k, v := range d.Details {
+			res.Details[k] = v
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// Wait for the configured latency, then inject a failure or run fn with the lock held
+func (f *Fake) call(method string, r *model.Resource, fn func() error) error {
+	time.Sleep(f.Latency)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.FailWith != nil {
+		if err := f.FailWith(method, r); err != nil {
+			return err
+		}
+	} else if f.FailureRate > 0 && f.rand.Float64() < f.FailureRate {
+		return fmt.Errorf("Simulated failure of %s for resource %d", method, r.ID)
+	}
+	return fn()
+}
+
+// Find a resource, must be called with the lock held
+func (f *Fake) find(r *model.Resource) (*Description, error) {
+	d, ok := f.resources[r.ID]
+	if !ok {
+		return nil, fmt.Errorf("Resource %d not found", r.ID)
+	}
+	return d, nil
+}
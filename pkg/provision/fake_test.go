@@ -0,0 +1,124 @@
+package provision
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+func TestFakeLifecycle(t *testing.T) {
+	f := NewFake()
+	r := &model.Resource{ID: 1, Type: "pot.instance.small"}
+
+	_, err := f.Describe(r)
+	assert.Error(t, err)
+
+	// create is idempotent
+	assert.NoError(t, f.Create(r))
+	assert.NoError(t, f.Create(r))
+	d, err := f.Describe(r)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "pot.instance.small", d.Type)
+		assert.True(t, d.Running)
+		assert.Equal(t, "fake-1", d.Details["id"])
+	}
+
+	assert.NoError(t, f.Stop(r))
+	d, _ = f.Describe(r)
+	assert.False(t, d.Running)
+	assert.NoError(t, f.Start(r))
+	d, _ = f.Describe(r)
+	assert.True(t, d.Running)
+
+	r.Type = "pot.instance.large"
+	assert.NoError(t, f.Resize(r))
+	d, _ = f.Describe(r)
+	assert.Equal(t, "pot.instance.large", d.Type)
+
+	// delete is idempotent as well, the resource is gone afterwards
+	assert.NoError(t, f.Delete(r))
+	assert.NoError(t, f.Delete(r))
+	_, err = f.Describe(r)
+	assert.Error(t, err)
+	assert.Error(t, f.Start(r))
+	assert.Error(t, f.Resize(r))
+}
+
+func TestFakeFailures(t *testing.T) {
+	r := &model.Resource{ID: 1, Type: "pot.instance.small"}
+
+	f := NewFake()
+	f.FailureRate = 1
+	assert.Error(t, f.Create(r))
+
+	f = NewFake()
+	f.FailWith = func(method string, r *model.Resource) error {
+		if method == "Stop" {
+			return errors.New("stuck")
+		}
+		return nil
+	}
+	assert.NoError(t, f.Create(r))
+	assert.EqualError(t, f.Stop(r), "stuck")
+	d, _ := f.Describe(r)
+	assert.True(t, d.Running)
+}
+
+func TestFakeLatency(t *testing.T) {
+	f := NewFake()
+	f.Latency = 20 * time.Millisecond
+
+	start := time.Now()
+	assert.NoError(t, f.Create(&model.Resource{ID: 1}))
+	assert.True(t, time.Since(start) >= f.Latency)
+}
+
+func TestFakeLoad(t *testing.T) {
+	db := model.MustInitTestDB(true)
+	defer db.Close()
+	stopped := model.Resource{Name: "pot", UserID: 1, Type: "pot.instance.small", Status: model.ResourceStopped}
+	deleted := model.Resource{Name: "old pot", UserID: 1, Type: "pot.instance.small", Status: model.ResourceDeleted}
+	for _, r := range []*model.Resource{&stopped, &deleted} {
+		if err := db.Create(r).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f := NewFake()
+	if err := f.Load(db); err != nil {
+		t.Fatal(err)
+	}
+	d, err := f.Describe(&stopped)
+	if assert.NoError(t, err) {
+		assert.False(t, d.Running)
+	}
+	_, err = f.Describe(&deleted)
+	assert.Error(t, err)
+
+	// sample resources were never created by the fake, but can be resized
+	var r model.Resource
+	if err := db.First(&r, 1).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetStatus(db, model.ResourceProvisioning, ""); err != nil {
+		t.Fatal(err)
+	}
+	r.Type = "pot.instance.small"
+	if err := db.Model(&r).Update("type", r.Type).Error; err != nil {
+		t.Fatal(err)
+	}
+	op := mustQueue(t, db, &r, model.OperationResize)
+	if _, err := NewWorker(db, f).ProcessPending(); err != nil {
+		t.Fatal(err)
+	}
+	mustReload(t, db, &r, op)
+	assert.Equal(t, model.OperationSucceeded, op.Status, op.Error)
+	assert.Equal(t, model.ResourceRunning, r.Status)
+	d, err = f.Describe(&r)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "pot.instance.small", d.Type)
+	}
+}
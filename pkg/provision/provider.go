@@ -0,0 +1,143 @@
+/*
+Package provision moves resources through their lifecycle.
+
+API handlers only record what should happen to a resource: they set its status and queue an
+operation, see model.Operation. A Worker picks up pending operations in the background and calls a
+Provider, which does the actual work on some backend, then records the outcome on the resource and
+the operation.
+
+Providers are registered per catalog category in a Registry, so e.g. pots and pans can be
+provisioned by different backends. Fake is an in-process provider for tests and demos.
+
+Operations are stored in the database, so they survive restarts. Operations interrupted by a restart
+are run again, so providers must be able to repeat a call for the same resource.
+*/
+package provision
+
+import (
+	"fmt"
+
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+// Provider manages resources on a backend. Calls may block until the backend is done.
+type Provider interface {
+	// Create provisions a new resource of type r.Type
+	Create(r *model.Resource) error
+	// Delete removes a resource, it must succeed for resources that were never created
+	Delete(r *model.Resource) error
+	// Resize changes an existing resource to match r.Type
+	Resize(r *model.Resource) error
+	// Start powers on a stopped resource
+	Start(r *model.Resource) error
+	// Stop powers off a running resource, without deleting it
+	Stop(r *model.Resource) error
+	// Describe reports the backend's view of a resource
+	Describe(r *model.Resource) (*Description, error)
+}
+
+// Description is what a provider knows about a resource
+type Description struct {
+	Type    string
+	Running bool
+	// Details are provider specific, e.g. the backend's ID for the resource
+	Details map[string]string
+}
+
+// Registry dispatches calls to the provider registered for the resource's catalog category.
+// It implements Provider itself, so it can be passed to a Worker.
+type Registry struct {
+	providers map[string]Provider
+	fallback  Provider
+}
+
+// NewRegistry creates a registry using fallback for categories without a provider.
+// If fallback is nil, calls for those categories fail.
+func NewRegistry(fallback Provider) *Registry {
+	return &Registry{providers: map[string]Provider{}, fallback: fallback}
+}
+
+// Register sets the provider for a catalog category, e.g. "pot"
+func (reg *Registry) Register(category string, p Provider) {
+	reg.providers[category] = p
+}
+
+// Lookup finds the provider for a catalog type, see model.Catalog for how categories are derived
+func (reg *Registry) Lookup(cat *model.Catalog) (Provider, error) {
+	category := cat.Category
+	if category == "" {
+		category = model.CategoryOf(cat.Name)
+	}
+	if p, ok := reg.providers[category]; ok {
+		return p, nil
+	}
+	if reg.fallback != nil {
+		return reg.fallback, nil
+	}
+	return nil, fmt.Errorf("No provider for category %s", category)
+}
+
+// Find the provider for a resource. The resource's catalog item should be loaded, if it isn't
+// the category is derived from the type.
+func (reg *Registry) lookup(r *model.Resource) (Provider, error) {
+	cat := r.Catalog
+	if cat.Name != r.Type {
+		cat = model.Catalog{Name: r.Type}
+	}
+	return reg.Lookup(&cat)
+}
+
+// Create implements Provider
+func (reg *Registry) Create(r *model.Resource) error {
+	p, err := reg.lookup(r)
+	if err != nil {
+		return err
+	}
+	return p.Create(r)
+}
+
+// Delete implements Provider
+func (reg *Registry) Delete(r *model.Resource) error {
+	p, err := reg.lookup(r)
+	if err != nil {
+		return err
+	}
+	return p.Delete(r)
+}
+
+// Resize implements Provider. The call goes to the provider of the new type, resources can't be
+// moved between providers.
+func (reg *Registry) Resize(r *model.Resource) error {
+	p, err := reg.lookup(r)
+	if err != nil {
+		return err
+	}
+	return p.Resize(r)
+}
+
+// Start implements Provider
+func (reg *Registry) Start(r *model.Resource) error {
+	p, err := reg.lookup(r)
+	if err != nil {
+		return err
+	}
+	return p.Start(r)
+}
+
+// Stop implements Provider
+func (reg *Registry) Stop(r *model.Resource) error {
+	p, err := reg.lookup(r)
+	if err != nil {
+		return err
+	}
+	return p.Stop(r)
+}
+
+// Describe implements Provider
+func (reg *Registry) Describe(r *model.Resource) (*Description, error) {
+	p, err := reg.lookup(r)
+	if err != nil {
+		return nil, err
+	}
+	return p.Describe(r)
+}
@@ -0,0 +1,47 @@
+package provision
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+func TestRegistry(t *testing.T) {
+	pots, pans, other := NewFake(), NewFake(), NewFake()
+	reg := NewRegistry(other)
+	reg.Register("pot", pots)
+	reg.Register("pan", pans)
+
+	tests := []struct {
+		r    model.Resource
+		want *Fake
+	}{
+		{r: model.Resource{ID: 1, Type: "pot.instance.small"}, want: pots},
+		{r: model.Resource{ID: 2, Type: "pan.instance.wok"}, want: pans},
+		{r: model.Resource{ID: 3, Type: "kettle.instance.s"}, want: other},
+		// category of the catalog item wins over the type's prefix
+		{
+			r:    model.Resource{ID: 4, Type: "wok.instance.xl", Catalog: model.Catalog{Name: "wok.instance.xl", Category: "pan"}},
+			want: pans,
+		},
+	}
+
+	for _, tt := range tests {
+		if err := reg.Create(&tt.r); err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range []*Fake{pots, pans, other} {
+			_, err := f.Describe(&tt.r)
+			assert.Equal(t, f == tt.want, err == nil, tt.r.Type)
+		}
+	}
+}
+
+func TestRegistryWithoutFallback(t *testing.T) {
+	reg := NewRegistry(nil)
+	reg.Register("pot", Nop())
+
+	assert.NoError(t, reg.Create(&model.Resource{Type: "pot.instance.small"}))
+	assert.Error(t, reg.Create(&model.Resource{Type: "pan.instance.wok"}))
+}
@@ -0,0 +1,213 @@
+package provision
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/yodo-io/ycp/pkg/logging"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+// DefaultInterval is how often the worker looks for pending operations
+const DefaultInterval = time.Second
+
+// Worker runs pending operations using a provider
+type Worker struct {
+	db       *gorm.DB
+	provider Provider
+	// Interval between looking for pending operations, DefaultInterval by default
+	Interval time.Duration
+	// Log receives failed operations, logging.Default by default
+	Log *logging.Logger
+}
+
+// NewWorker creates a worker running operations stored in db with the given provider
+func NewWorker(db *gorm.DB, p Provider) *Worker {
+	return &Worker{db: db, provider: p, Interval: DefaultInterval, Log: logging.Default}
+}
+
+// Run processes pending operations until the context is cancelled. Operations left running by a
+// previous run are started again.
+func (w *Worker) Run(ctx context.Context) error {
+	err := w.db.Model(&model.Operation{}).
+		Where("status = ?", model.OperationRunning).
+		Update("status", model.OperationPending).Error
+	if err != nil {
+		return err
+	}
+
+	t := time.NewTicker(w.Interval)
+	defer t.Stop()
+	for {
+		if _, err := w.ProcessPending(); err != nil {
+			w.Log.Error("Failed to process operations", logging.Fields{"error": err})
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.C:
+		}
+	}
+}
+
+// ProcessPending runs all pending operations in the order they were created and returns the number
+// of operations run. Failing operations are recorded as failed, an error is only returned if the
+// database can't be accessed.
+func (w *Worker) ProcessPending() (int, error) {
+	n := 0
+	for {
+		op, err := w.claim()
+		if err != nil {
+			return n, err
+		}
+		if op == nil {
+			return n, nil
+		}
+		if err := w.process(op); err != nil {
+			return n, err
+		}
+		n++
+	}
+}
+
+// Find the oldest pending operation and mark it as running, returns nil if there is none
+func (w *Worker) claim() (*model.Operation, error) {
+	for {
+		var ops []*model.Operation
+		if err := w.db.Order("id").Limit(1).Find(&ops, "status = ?", model.OperationPending).Error; err != nil {
+			return nil, err
+		}
+		if len(ops) == 0 {
+			return nil, nil
+		}
+		// conditional update guards against other workers claiming the same operation
+		res := w.db.Model(&model.Operation{}).
+			Where("id = ? and status = ?", ops[0].ID, model.OperationPending).
+			Update("status", model.OperationRunning)
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		if res.RowsAffected == 1 {
+			ops[0].Status = model.OperationRunning
+			return ops[0], nil
+		}
+	}
+}
+
+// Run a claimed operation and record the outcome
+func (w *Worker) process(op *model.Operation) error {
+	var rs []*model.Resource
+	if err := w.db.Preload("Catalog").Find(&rs, "id = ?", op.ResourceID).Error; err != nil {
+		return err
+	}
+	if len(rs) == 0 {
+		return w.finish(op, fmt.Errorf("Resource %d not found", op.ResourceID))
+	}
+	r := rs[0]
+
+	switch op.Action {
+	case model.OperationCreate:
+		// fails if the resource was deleted before it was provisioned, is provisioning already if an
+		// earlier run was interrupted
+		if r.Status != model.ResourceRunning {
+			if err := w.advance(r, model.ResourceProvisioning); err != nil {
+				return w.abort(op, err)
+			}
+		}
+		return w.apply(op, r, w.provider.Create, model.ResourceRunning)
+	case model.OperationResize:
+		// resource was moved to provisioning when the resize was requested
+		return w.apply(op, r, w.provider.Resize, model.ResourceRunning)
+	case model.OperationDelete:
+		return w.apply(op, r, w.provider.Delete, model.ResourceDeleted)
+	case model.OperationStart:
+		// resource was moved to provisioning when the start was requested
+		return w.apply(op, r, w.provider.Start, model.ResourceRunning)
+	case model.OperationStop:
+		// resource was moved to stopping when the stop was requested
+		return w.apply(op, r, w.provider.Stop, model.ResourceStopped)
+	case model.OperationRestart:
+		return w.restart(op, r)
+	default:
+		return w.finish(op, fmt.Errorf("Unknown action %s", op.Action))
+	}
+}
+
+// Call the provider and move the resource to status done if it succeeds, or to failed if it doesn't.
+// Resources in status done already got there in an interrupted run, the provider isn't called again.
+func (w *Worker) apply(op *model.Operation, r *model.Resource, fn func(*model.Resource) error, done model.ResourceStatus) error {
+	if r.Status == done {
+		return w.finish(op, nil)
+	}
+	if perr := fn(r); perr != nil {
+		return w.fail(op, r, perr)
+	}
+	if err := w.advance(r, done); err != nil {
+		return w.abort(op, err)
+	}
+	return w.finish(op, nil)
+}
+
+// Stop the resource, then start it again. The resource was moved to stopping when the restart was
+// requested and goes through stopped and provisioning, like a stop followed by a start. Steps an
+// interrupted run got through already are skipped.
+func (w *Worker) restart(op *model.Operation, r *model.Resource) error {
+	if r.Status == model.ResourceStopping {
+		if perr := w.provider.Stop(r); perr != nil {
+			return w.fail(op, r, perr)
+		}
+		if err := w.advance(r, model.ResourceStopped); err != nil {
+			return w.abort(op, err)
+		}
+	}
+	if r.Status == model.ResourceStopped {
+		if err := w.advance(r, model.ResourceProvisioning); err != nil {
+			return w.abort(op, err)
+		}
+	}
+	return w.apply(op, r, w.provider.Start, model.ResourceRunning)
+}
+
+// Move the resource to a status, unless an interrupted run of the operation got it there already
+func (w *Worker) advance(r *model.Resource, to model.ResourceStatus) error {
+	if r.Status == to {
+		return nil
+	}
+	return r.SetStatus(w.db, to, "")
+}
+
+// Fail the operation if the resource can't move to the next status, e.g. because it was changed
+// concurrently. Other errors are from the database and returned, leaving the operation to be retried.
+func (w *Worker) abort(op *model.Operation, err error) error {
+	if _, ok := err.(*model.InvalidTransitionError); ok {
+		return w.finish(op, err)
+	}
+	return err
+}
+
+// Record a provider error on the resource and the operation
+func (w *Worker) fail(op *model.Operation, r *model.Resource, perr error) error {
+	w.Log.Warn("Operation failed", logging.Fields{
+		"operationId": op.ID,
+		"action":      op.Action,
+		"resourceId":  r.ID,
+		"error":       perr,
+	})
+	if err := r.SetStatus(w.db, model.ResourceFailed, perr.Error()); err != nil {
+		if _, ok := err.(*model.InvalidTransitionError); !ok {
+			return err
+		}
+	}
+	return w.finish(op, perr)
+}
+
+// Mark operation as succeeded, or failed if err is set
+func (w *Worker) finish(op *model.Operation, err error) error {
+	up := map[string]interface{}{"status": model.OperationSucceeded, "error": ""}
+	if err != nil {
+		up = map[string]interface{}{"status": model.OperationFailed, "error": err.Error()}
+	}
+	return w.db.Model(op).Updates(up).Error
+}
@@ -0,0 +1,216 @@
+package provision
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/stretchr/testify/assert"
+	"github.com/yodo-io/ycp/pkg/model"
+)
+
+// Provider failing calls for resources named "broken"
+type testProvider struct{}
+
+func (testProvider) Create(r *model.Resource) error { return fail(r) }
+func (testProvider) Delete(r *model.Resource) error { return fail(r) }
+func (testProvider) Resize(r *model.Resource) error { return fail(r) }
+func (testProvider) Start(r *model.Resource) error  { return fail(r) }
+func (testProvider) Stop(r *model.Resource) error   { return fail(r) }
+
+func (testProvider) Describe(r *model.Resource) (*Description, error) {
+	return &Description{Type: r.Type}, fail(r)
+}
+
+func fail(r *model.Resource) error {
+	if r.Name == "broken" {
+		return errors.New("out of pots")
+	}
+	return nil
+}
+
+// Create a resource along with an operation, like the API does
+func mustQueue(t *testing.T, db *gorm.DB, r *model.Resource, action string) *model.Operation {
+	if r.ID == 0 {
+		if err := db.Create(r).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	op := model.NewOperation(r.ID, action)
+	if err := db.Create(&op).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &op
+}
+
+func mustReload(t *testing.T, db *gorm.DB, r *model.Resource, op *model.Operation) {
+	if err := db.First(r, r.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.First(op, op.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProcessPending(t *testing.T) {
+	tests := []struct {
+		name   string
+		status model.ResourceStatus
+		action string
+		rs     model.ResourceStatus
+		os     model.OperationStatus
+	}{
+		{name: "pot", status: model.ResourcePending, action: model.OperationCreate, rs: model.ResourceRunning, os: model.OperationSucceeded},
+		{name: "broken", status: model.ResourcePending, action: model.OperationCreate, rs: model.ResourceFailed, os: model.OperationFailed},
+		{name: "pot", status: model.ResourceDeleting, action: model.OperationDelete, rs: model.ResourceDeleted, os: model.OperationSucceeded},
+		{name: "broken", status: model.ResourceDeleting, action: model.OperationDelete, rs: model.ResourceFailed, os: model.OperationFailed},
+		{name: "pot", status: model.ResourceProvisioning, action: model.OperationResize, rs: model.ResourceRunning, os: model.OperationSucceeded},
+		{name: "broken", status: model.ResourceProvisioning, action: model.OperationResize, rs: model.ResourceFailed, os: model.OperationFailed},
+		{name: "pot", status: model.ResourceStopping, action: model.OperationStop, rs: model.ResourceStopped, os: model.OperationSucceeded},
+		{name: "broken", status: model.ResourceStopping, action: model.OperationStop, rs: model.ResourceFailed, os: model.OperationFailed},
+		{name: "pot", status: model.ResourceProvisioning, action: model.OperationStart, rs: model.ResourceRunning, os: model.OperationSucceeded},
+		{name: "broken", status: model.ResourceProvisioning, action: model.OperationStart, rs: model.ResourceFailed, os: model.OperationFailed},
+		{name: "pot", status: model.ResourceStopping, action: model.OperationRestart, rs: model.ResourceRunning, os: model.OperationSucceeded},
+		{name: "broken", status: model.ResourceStopping, action: model.OperationRestart, rs: model.ResourceFailed, os: model.OperationFailed},
+		// interrupted after the resource was moved to provisioning
+		{name: "pot", status: model.ResourceProvisioning, action: model.OperationCreate, rs: model.ResourceRunning, os: model.OperationSucceeded},
+		// interrupted after the resource reached its status, or a step of a restart
+		{name: "pot", status: model.ResourceRunning, action: model.OperationCreate, rs: model.ResourceRunning, os: model.OperationSucceeded},
+		{name: "broken", status: model.ResourceRunning, action: model.OperationResize, rs: model.ResourceRunning, os: model.OperationSucceeded},
+		{name: "broken", status: model.ResourceDeleted, action: model.OperationDelete, rs: model.ResourceDeleted, os: model.OperationSucceeded},
+		{name: "broken", status: model.ResourceStopped, action: model.OperationStop, rs: model.ResourceStopped, os: model.OperationSucceeded},
+		{name: "pot", status: model.ResourceStopped, action: model.OperationRestart, rs: model.ResourceRunning, os: model.OperationSucceeded},
+		{name: "pot", status: model.ResourceProvisioning, action: model.OperationRestart, rs: model.ResourceRunning, os: model.OperationSucceeded},
+		{name: "broken", status: model.ResourceStopped, action: model.OperationRestart, rs: model.ResourceFailed, os: model.OperationFailed},
+		// changed concurrently, so it can't get to the status of the operation
+		{name: "pot", status: model.ResourceStopped, action: model.OperationStart, rs: model.ResourceStopped, os: model.OperationFailed},
+		{name: "pot", status: model.ResourceDeleting, action: model.OperationRestart, rs: model.ResourceDeleting, os: model.OperationFailed},
+		// deleted before it was provisioned
+		{name: "pot", status: model.ResourceDeleting, action: model.OperationCreate, rs: model.ResourceDeleting, os: model.OperationFailed},
+		{name: "pot", status: model.ResourceRunning, action: "explode", rs: model.ResourceRunning, os: model.OperationFailed},
+	}
+
+	for _, tt := range tests {
+		func() {
+			db := model.MustInitTestDB(true)
+			defer db.Close()
+
+			r := model.Resource{Name: tt.name, UserID: 1, Type: "pot.instance.small", Status: tt.status}
+			op := mustQueue(t, db, &r, tt.action)
+
+			n, err := NewWorker(db, testProvider{}).ProcessPending()
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, 1, n)
+
+			mustReload(t, db, &r, op)
+			assert.Equal(t, tt.rs, r.Status, tt.name+" "+tt.action)
+			assert.Equal(t, tt.os, op.Status, tt.name+" "+tt.action)
+			if tt.os == model.OperationFailed {
+				assert.NotEmpty(t, op.Error)
+			}
+			if tt.rs == model.ResourceFailed {
+				assert.Equal(t, "out of pots", r.StatusMessage)
+			}
+		}()
+	}
+}
+
+func TestProcessInOrder(t *testing.T) {
+	db := model.MustInitTestDB(true)
+	defer db.Close()
+
+	// create and delete queued back to back
+	r := model.Resource{Name: "pot", UserID: 1, Type: "pot.instance.small"}
+	create := mustQueue(t, db, &r, model.OperationCreate)
+	if err := r.SetStatus(db, model.ResourceDeleting, ""); err != nil {
+		t.Fatal(err)
+	}
+	del := mustQueue(t, db, &r, model.OperationDelete)
+
+	n, err := NewWorker(db, Nop()).ProcessPending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 2, n)
+
+	mustReload(t, db, &r, create)
+	assert.Equal(t, model.OperationFailed, create.Status)
+	mustReload(t, db, &r, del)
+	assert.Equal(t, model.OperationSucceeded, del.Status)
+	assert.Equal(t, model.ResourceDeleted, r.Status)
+
+	// nothing left to do
+	n, err = NewWorker(db, Nop()).ProcessPending()
+	assert.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+func TestRunResumesInterruptedOperations(t *testing.T) {
+	// interrupted before or after the resource was moved to provisioning
+	for _, status := range []model.ResourceStatus{model.ResourcePending, model.ResourceProvisioning} {
+		func() {
+			db := model.MustInitTestDB(true)
+			defer db.Close()
+
+			// operation was claimed by a worker that didn't finish it
+			r := model.Resource{Name: "pot", UserID: 1, Type: "pot.instance.small", Status: status}
+			op := mustQueue(t, db, &r, model.OperationCreate)
+			if err := db.Model(op).Update("status", model.OperationRunning).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			w := NewWorker(db, Nop())
+			w.Interval = 10 * time.Millisecond
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() {
+				done <- w.Run(ctx)
+			}()
+
+			// poll until done
+			deadline := time.Now().Add(5 * time.Second)
+			for time.Now().Before(deadline) {
+				mustReload(t, db, &r, op)
+				if op.Status.Done() {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			cancel()
+			assert.NoError(t, <-done)
+
+			assert.Equal(t, model.OperationSucceeded, op.Status, status)
+			assert.Equal(t, model.ResourceRunning, r.Status, status)
+		}()
+	}
+}
+
+func TestRestartFailingToStart(t *testing.T) {
+	db := model.MustInitTestDB(true)
+	defer db.Close()
+
+	f := NewFake()
+	f.FailWith = func(method string, r *model.Resource) error {
+		if method == "Start" {
+			return errors.New("lid stuck")
+		}
+		return nil
+	}
+	r := model.Resource{Name: "pot", UserID: 1, Type: "pot.instance.small", Status: model.ResourceStopping}
+	op := mustQueue(t, db, &r, model.OperationRestart)
+	if err := f.Create(&r); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewWorker(db, f).ProcessPending(); err != nil {
+		t.Fatal(err)
+	}
+	mustReload(t, db, &r, op)
+	assert.Equal(t, model.ResourceFailed, r.Status)
+	assert.Equal(t, "lid stuck", r.StatusMessage)
+	assert.Equal(t, model.OperationFailed, op.Status)
+}
@@ -0,0 +1,28 @@
+/*
+Package version holds build information, injected at link time:
+
+	go build -ldflags "-X github.com/yodo-io/ycp/pkg/version.Version=v1.2.0 -X github.com/yodo-io/ycp/pkg/version.Commit=$(git rev-parse HEAD)"
+
+See the Makefile, which sets both from git.
+*/
+package version
+
+import "runtime"
+
+// Set by the linker, see package docs
+var (
+	Version = "dev"
+	Commit  = "unknown"
+)
+
+// Info describes the running build
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	GoVersion string `json:"goVersion"`
+}
+
+// Get returns the build info of the running binary
+func Get() Info {
+	return Info{Version: Version, Commit: Commit, GoVersion: runtime.Version()}
+}